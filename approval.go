@@ -0,0 +1,178 @@
+package disbursement
+
+import (
+	"context"
+	"time"
+)
+
+// ApprovalState is the progress of a transfer held for approval.
+type ApprovalState string
+
+const (
+	// ApprovalPending means the transfer is waiting for approvers.
+	ApprovalPending ApprovalState = "pending"
+
+	// ApprovalApproved means enough approvers approved and the transfer
+	// was sent. Its outcome is in the Result or Error of the approval.
+	ApprovalApproved ApprovalState = "approved"
+
+	// ApprovalRejected means an approver rejected the transfer, which was
+	// never sent.
+	ApprovalRejected ApprovalState = "rejected"
+
+	// ApprovalExpired means the transfer was not approved in time and was
+	// never sent.
+	ApprovalExpired ApprovalState = "expired"
+)
+
+// ApprovalAction is a step in the history of an approval.
+type ApprovalAction string
+
+const (
+	ApprovalRequested ApprovalAction = "requested"
+	ApprovalGranted   ApprovalAction = "approved"
+	ApprovalDenied    ApprovalAction = "rejected"
+	ApprovalLapsed    ApprovalAction = "expired"
+	ApprovalSubmitted ApprovalAction = "submitted"
+)
+
+// ApprovalEvent is an entry in the history of an Approval: who did what,
+// when and why.
+type ApprovalEvent struct {
+	Action ApprovalAction `json:"action"`
+
+	// ActorID is the client that acted, empty for actions taken by the
+	// service itself.
+	ActorID string `json:"actor_id,omitempty"`
+
+	// Role is the role the actor approved or rejected in.
+	Role string `json:"role,omitempty"`
+
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Approval is a transfer held until enough distinct approvers approve it.
+type Approval struct {
+	ID string `json:"id"`
+
+	// ClientID is the client that requested the transfer. It may not
+	// approve it.
+	ClientID string `json:"client_id,omitempty"`
+
+	Method       Method        `json:"method"`
+	Disbursement *Disbursement `json:"disbursement"`
+
+	// Route is why the rail was chosen, if it was chosen automatically.
+	Route *Route `json:"route,omitempty"`
+
+	State ApprovalState `json:"state"`
+
+	// RequiredApprovals is the number of distinct approvers needed, each
+	// holding one of Roles. Empty Roles means any approver.
+	RequiredApprovals int      `json:"required_approvals"`
+	Roles             []string `json:"roles,omitempty"`
+
+	History []ApprovalEvent `json:"history"`
+
+	// TransactionID and Result are set once the approved transfer was
+	// accepted; Error if sending it failed.
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Result        *TransferResult `json:"result,omitempty"`
+	Error         string          `json:"error,omitempty"`
+
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewApprovalID returns a random approval id.
+func NewApprovalID() string {
+	return newID("apr_")
+}
+
+// Approvals returns the ids of the clients that approved a.
+func (a *Approval) Approvals() []string {
+	var ids []string
+	for _, e := range a.History {
+		if e.Action == ApprovalGranted {
+			ids = append(ids, e.ActorID)
+		}
+	}
+	return ids
+}
+
+// Record appends an event to the history of a.
+func (a *Approval) Record(e ApprovalEvent) {
+	a.History = append(a.History, e)
+	a.UpdatedAt = e.At
+}
+
+// Approver is a client allowed to approve held transfers in the given roles.
+type Approver struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// ApprovalFilter selects approvals. Zero fields match every approval.
+type ApprovalFilter struct {
+	State    ApprovalState
+	ClientID string
+}
+
+// Match reports whether a is selected by f.
+func (f ApprovalFilter) Match(a *Approval) bool {
+	switch {
+	case f.State != "" && a.State != f.State:
+		return false
+	case f.ClientID != "" && a.ClientID != f.ClientID:
+		return false
+	}
+	return true
+}
+
+// ApprovalStore persists approvals.
+type ApprovalStore interface {
+	// CreateApproval stores a new approval, assigning its ID if it is
+	// empty.
+	CreateApproval(ctx context.Context, a *Approval) error
+
+	// UpdateApproval replaces a stored approval. It returns ErrNotFound if
+	// a was never created.
+	UpdateApproval(ctx context.Context, a *Approval) error
+
+	// FindApprovalByID returns ErrNotFound if there is no such approval.
+	FindApprovalByID(ctx context.Context, id string) (*Approval, error)
+
+	// FindApprovals returns the approvals selected by filter, oldest
+	// first.
+	FindApprovals(ctx context.Context, filter ApprovalFilter) ([]*Approval, error)
+}
+
+// ApprovalService holds high-value transfers until they are approved.
+type ApprovalService interface {
+	// HoldForApproval stores d as a pending approval if it needs one, and
+	// returns it. It returns nil if d may be sent at once.
+	HoldForApproval(ctx context.Context, method Method, d *Disbursement) (*Approval, error)
+
+	// RequiresApproval reports whether d would be held for approval.
+	RequiresApproval(method Method, d *Disbursement) bool
+
+	// Approve records the calling client's approval, and sends the
+	// transfer once it has enough. It returns ErrForbidden if the caller
+	// is not an approver in a required role, requested the transfer or
+	// already approved it.
+	Approve(ctx context.Context, id string) (*Approval, error)
+
+	// Reject rejects a pending approval on behalf of the calling client.
+	Reject(ctx context.Context, id, reason string) (*Approval, error)
+
+	// FindApprovalByID returns an approval the caller may see: one it
+	// requested, or any if it is an approver.
+	FindApprovalByID(ctx context.Context, id string) (*Approval, error)
+
+	// FindApprovals returns the approvals selected by filter that the
+	// caller may see.
+	FindApprovals(ctx context.Context, filter ApprovalFilter) ([]*Approval, error)
+}
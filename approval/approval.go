@@ -0,0 +1,404 @@
+// Package approval holds high-value transfers until they are approved by
+// enough distinct approvers, none of them the client that requested the
+// transfer. Which transfers are held, by how many approvers and in which
+// roles is set by rules on the amount and rail.
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Service defaults.
+const (
+	DefaultExpiresAfter   = 24 * time.Hour
+	DefaultExpireInterval = time.Minute
+)
+
+// Rule holds transfers above an amount for approval.
+type Rule struct {
+	// Method limits the rule to one rail. Empty matches every rail.
+	Method disbursement.Method `json:"method,omitempty"`
+
+	// Above is the amount a transfer must exceed to be held.
+	Above disbursement.Money `json:"above"`
+
+	// Approvals is the number of distinct approvers needed.
+	Approvals int `json:"approvals"`
+
+	// Roles are the roles any of which an approver must hold. Empty means
+	// any approver.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Config is the layout of an approval configuration file.
+type Config struct {
+	Rules     []Rule                   `json:"rules"`
+	Approvers []*disbursement.Approver `json:"approvers"`
+
+	// ExpiresAfter is how long a held transfer waits for approvers, as a
+	// duration such as "24h". Defaults to DefaultExpiresAfter.
+	ExpiresAfter string `json:"expires_after,omitempty"`
+}
+
+// LoadConfig reads the JSON configuration file at path.
+func LoadConfig(path string) (Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Service is a disbursement.ApprovalService sending approved transfers
+// through Payout.
+type Service struct {
+	Payout disbursement.DisbursementService
+	Store  disbursement.ApprovalStore
+
+	// Senders, if set, resolves the sender of each approved transfer, as
+	// held transfers are stored without it.
+	Senders disbursement.SenderService
+
+	Rules     []Rule
+	Approvers map[string]*disbursement.Approver
+
+	// ExpiresAfter is how long a held transfer waits for approvers.
+	ExpiresAfter time.Duration
+
+	// ExpireInterval is how often Run looks for expired approvals.
+	ExpireInterval time.Duration
+
+	// mu serializes decisions, so a transfer is never both rejected and
+	// sent, or sent twice.
+	mu sync.Mutex
+
+	now func() time.Time
+}
+
+// NewService returns a Service applying config.
+func NewService(payout disbursement.DisbursementService, store disbursement.ApprovalStore, config Config) (*Service, error) {
+	s := &Service{
+		Payout:         payout,
+		Store:          store,
+		Rules:          config.Rules,
+		Approvers:      make(map[string]*disbursement.Approver, len(config.Approvers)),
+		ExpiresAfter:   DefaultExpiresAfter,
+		ExpireInterval: DefaultExpireInterval,
+		now:            time.Now,
+	}
+	for i, rule := range config.Rules {
+		if rule.Approvals < 1 {
+			return nil, fmt.Errorf("%w: rule %d needs at least one approval", disbursement.ErrInvalid, i)
+		} else if rule.Method != "" {
+			if _, err := disbursement.ParseMethod(string(rule.Method)); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+		}
+	}
+	for _, a := range config.Approvers {
+		if a.ID == "" {
+			return nil, fmt.Errorf("%w: approver without an id", disbursement.ErrInvalid)
+		} else if _, ok := s.Approvers[a.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate approver %q", disbursement.ErrInvalid, a.ID)
+		}
+		s.Approvers[a.ID] = a
+	}
+	if config.ExpiresAfter != "" {
+		d, err := time.ParseDuration(config.ExpiresAfter)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: expires_after %q is not a positive duration", disbursement.ErrInvalid, config.ExpiresAfter)
+		}
+		s.ExpiresAfter = d
+	}
+	return s, nil
+}
+
+// Run expires approvals every ExpireInterval until ctx is done.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.ExpireInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.Expire(ctx); err != nil {
+			log.Printf("approval: expire: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// rule returns the strictest rule holding d, or nil if none does.
+func (s *Service) rule(method disbursement.Method, d *disbursement.Disbursement) *Rule {
+	var strictest *Rule
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.Method != "" && rule.Method != method {
+			continue
+		}
+		amount := d.Details.Amount
+		if amount.Currency != rule.Above.Currency || amount.Cmp(rule.Above) <= 0 {
+			continue
+		}
+		if strictest == nil || rule.Approvals > strictest.Approvals {
+			strictest = rule
+		}
+	}
+	return strictest
+}
+
+func (s *Service) RequiresApproval(method disbursement.Method, d *disbursement.Disbursement) bool {
+	return s.rule(method, d) != nil
+}
+
+// HoldForApproval stores d as pending on behalf of the calling client if a
+// rule holds it.
+func (s *Service) HoldForApproval(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) (*disbursement.Approval, error) {
+	rule := s.rule(method, d)
+	if rule == nil {
+		return nil, nil
+	}
+
+	now := s.now()
+	a := &disbursement.Approval{
+		ClientID:          disbursement.ClientIDFromContext(ctx),
+		Method:            method,
+		Disbursement:      d,
+		Route:             d.Route,
+		State:             disbursement.ApprovalPending,
+		RequiredApprovals: rule.Approvals,
+		Roles:             rule.Roles,
+		ExpiresAt:         now.Add(s.ExpiresAfter),
+		CreatedAt:         now,
+	}
+	a.Record(disbursement.ApprovalEvent{
+		Action:  disbursement.ApprovalRequested,
+		ActorID: a.ClientID,
+		Reason:  fmt.Sprintf("amount exceeds %s", rule.Above),
+		At:      now,
+	})
+	if err := s.Store.CreateApproval(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Approve records the caller's approval and sends the transfer, as the
+// client that requested it, once it has enough approvals.
+func (s *Service) Approve(ctx context.Context, id string) (*disbursement.Approval, error) {
+	caller := disbursement.ClientIDFromContext(ctx)
+
+	s.mu.Lock()
+	a, err := s.pending(ctx, id)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	role, err := s.authorize(caller, a)
+	if err == nil {
+		for _, approver := range a.Approvals() {
+			if approver == caller {
+				err = fmt.Errorf("%w: client %q already approved this transfer", disbursement.ErrForbidden, caller)
+			}
+		}
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	a.Record(disbursement.ApprovalEvent{Action: disbursement.ApprovalGranted, ActorID: caller, Role: role, At: s.now()})
+	ready := len(a.Approvals()) >= a.RequiredApprovals
+	if ready {
+		a.State = disbursement.ApprovalApproved
+	}
+	// Once approved is recorded no other decision can send it again.
+	err = s.Store.UpdateApproval(ctx, a)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	} else if !ready {
+		return a, nil
+	}
+
+	s.send(a)
+	return a, nil
+}
+
+// send transfers the approved a, outside the approver's request, and
+// records the outcome.
+func (s *Service) send(a *disbursement.Approval) {
+	ctx := disbursement.NewContextWithClientID(context.Background(), a.ClientID)
+	d := *a.Disbursement
+	d.Route = a.Route
+
+	e := disbursement.ApprovalEvent{Action: disbursement.ApprovalSubmitted}
+	var result *disbursement.TransferResult
+	var err error
+	if s.Senders != nil {
+		err = disbursement.ResolveSender(ctx, s.Senders, &d)
+	}
+	if err == nil {
+		result, err = s.Payout.TransferFunds(ctx, a.Method, &d)
+	}
+	if err != nil {
+		a.Error = err.Error()
+		e.Reason = err.Error()
+	} else {
+		a.TransactionID = result.TransactionID
+		a.Result = result
+	}
+	e.At = s.now()
+	a.Record(e)
+
+	// The outcome is on the transaction even if it cannot be recorded here.
+	if err := s.Store.UpdateApproval(context.Background(), a); err != nil {
+		log.Printf("approval: cannot record outcome of approval %s: %s", a.ID, err)
+	}
+}
+
+// Reject rejects a pending transfer on behalf of the calling approver.
+func (s *Service) Reject(ctx context.Context, id, reason string) (*disbursement.Approval, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, &disbursement.ValidationError{Errors: []disbursement.FieldError{{Field: "reason", Message: "is required"}}}
+	}
+	caller := disbursement.ClientIDFromContext(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.authorize(caller, a)
+	if err != nil {
+		return nil, err
+	}
+	a.State = disbursement.ApprovalRejected
+	a.Record(disbursement.ApprovalEvent{Action: disbursement.ApprovalDenied, ActorID: caller, Role: role, Reason: reason, At: s.now()})
+	if err := s.Store.UpdateApproval(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// pending returns the approval id if it is still pending, expiring it first
+// if its time is up. s.mu must be held.
+func (s *Service) pending(ctx context.Context, id string) (*disbursement.Approval, error) {
+	a, err := s.Store.FindApprovalByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.State == disbursement.ApprovalPending && !s.now().Before(a.ExpiresAt) {
+		if err := s.expire(ctx, a); err != nil {
+			return nil, err
+		}
+	}
+	if a.State != disbursement.ApprovalPending {
+		return nil, fmt.Errorf("%w: approval %s is %s", disbursement.ErrInvalid, a.ID, a.State)
+	}
+	return a, nil
+}
+
+// authorize returns the role caller may decide on a in, or an error matching
+// disbursement.ErrForbidden.
+func (s *Service) authorize(caller string, a *disbursement.Approval) (string, error) {
+	if caller == "" {
+		return "", fmt.Errorf("%w: approvals need an identified client", disbursement.ErrForbidden)
+	} else if caller == a.ClientID {
+		return "", fmt.Errorf("%w: a transfer cannot be approved or rejected by the client that requested it", disbursement.ErrForbidden)
+	}
+	approver, ok := s.Approvers[caller]
+	if !ok {
+		return "", fmt.Errorf("%w: client %q is not an approver", disbursement.ErrForbidden, caller)
+	}
+
+	if len(a.Roles) == 0 {
+		if len(approver.Roles) > 0 {
+			return approver.Roles[0], nil
+		}
+		return "", nil
+	}
+	for _, have := range approver.Roles {
+		for _, want := range a.Roles {
+			if have == want {
+				return have, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: approving this transfer requires the role %s", disbursement.ErrForbidden, strings.Join(a.Roles, " or "))
+}
+
+// Expire expires every pending approval whose time is up.
+func (s *Service) Expire(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	approvals, err := s.Store.FindApprovals(ctx, disbursement.ApprovalFilter{State: disbursement.ApprovalPending})
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, a := range approvals {
+		if now.Before(a.ExpiresAt) {
+			continue
+		}
+		if err := s.expire(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire marks a as expired. s.mu must be held.
+func (s *Service) expire(ctx context.Context, a *disbursement.Approval) error {
+	a.State = disbursement.ApprovalExpired
+	a.Record(disbursement.ApprovalEvent{
+		Action: disbursement.ApprovalLapsed,
+		Reason: fmt.Sprintf("not approved by %s", a.ExpiresAt.Format(time.RFC3339)),
+		At:     s.now(),
+	})
+	return s.Store.UpdateApproval(ctx, a)
+}
+
+// FindApprovalByID returns the approval if the caller requested it or is an
+// approver.
+func (s *Service) FindApprovalByID(ctx context.Context, id string) (*disbursement.Approval, error) {
+	a, err := s.Store.FindApprovalByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isApprover(ctx) && !disbursement.CallerOwns(ctx, a.ClientID) {
+		return nil, disbursement.ErrNotFound
+	}
+	return a, nil
+}
+
+// FindApprovals returns the approvals selected by filter. Clients other than
+// approvers only see their own.
+func (s *Service) FindApprovals(ctx context.Context, filter disbursement.ApprovalFilter) ([]*disbursement.Approval, error) {
+	if caller := disbursement.ClientIDFromContext(ctx); caller != "" && !s.isApprover(ctx) {
+		filter.ClientID = caller
+	}
+	return s.Store.FindApprovals(ctx, filter)
+}
+
+func (s *Service) isApprover(ctx context.Context) bool {
+	_, ok := s.Approvers[disbursement.ClientIDFromContext(ctx)]
+	return ok
+}
@@ -0,0 +1,183 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakePayout accepts every transfer sent with a live context, remembering
+// the client and sender that sent it.
+type fakePayout struct {
+	disbursement.DisbursementService
+
+	mu      sync.Mutex
+	clients []string
+	senders []string
+}
+
+func (p *fakePayout) TransferFunds(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) (*disbursement.TransferResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clients = append(p.clients, disbursement.ClientIDFromContext(ctx))
+	if d.Sender != nil {
+		p.senders = append(p.senders, d.Sender.ID)
+	}
+	return &disbursement.TransferResult{TransactionID: "txn_1", Method: method, State: disbursement.TransferPending}, nil
+}
+
+func TestService(t *testing.T) {
+	Convey("given a rule holding PESONet transfers above 500,000.00 for two treasury approvals", t, func() {
+		now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+		payout := &fakePayout{}
+		s, err := NewService(payout, inmem.NewApprovalStore(), Config{
+			Rules: []Rule{
+				{Above: disbursement.Money{Minor: 100000000, Currency: "PHP"}, Approvals: 1},
+				{Method: disbursement.MethodPesonet, Above: disbursement.Money{Minor: 50000000, Currency: "PHP"}, Approvals: 2, Roles: []string{"treasury"}},
+			},
+			Approvers: []*disbursement.Approver{
+				{ID: "alice", Roles: []string{"treasury"}},
+				{ID: "bob", Roles: []string{"treasury", "finance"}},
+				{ID: "carol", Roles: []string{"finance"}},
+			},
+			ExpiresAfter: "4h",
+		})
+		So(err, ShouldBeNil)
+		s.now = func() time.Time { return now }
+
+		maker := disbursement.NewContextWithClientID(context.Background(), "acme")
+		as := func(id string) context.Context {
+			return disbursement.NewContextWithClientID(context.Background(), id)
+		}
+		transfer := func(amount int64) *disbursement.Disbursement {
+			return &disbursement.Disbursement{Details: disbursement.Details{
+				Amount: disbursement.Money{Minor: amount * 100, Currency: "PHP"},
+			}}
+		}
+
+		Convey("smaller transfers are not held", func() {
+			a, err := s.HoldForApproval(maker, disbursement.MethodPesonet, transfer(500000))
+			So(err, ShouldBeNil)
+			So(a, ShouldBeNil)
+			So(s.RequiresApproval(disbursement.MethodInstapay, transfer(600000)), ShouldBeFalse)
+		})
+
+		Convey("larger transfers are held", func() {
+			a, err := s.HoldForApproval(maker, disbursement.MethodPesonet, transfer(600000))
+			So(err, ShouldBeNil)
+			So(a.State, ShouldEqual, disbursement.ApprovalPending)
+			So(a.RequiredApprovals, ShouldEqual, 2)
+			So(a.ExpiresAt, ShouldEqual, now.Add(4*time.Hour))
+
+			Convey("and sent as the requesting client once two distinct approvers approve", func() {
+				got, err := s.Approve(as("alice"), a.ID)
+				So(err, ShouldBeNil)
+				So(got.State, ShouldEqual, disbursement.ApprovalPending)
+
+				_, err = s.Approve(as("alice"), a.ID)
+				So(errors.Is(err, disbursement.ErrForbidden), ShouldBeTrue)
+				So(payout.clients, ShouldBeEmpty)
+
+				got, err = s.Approve(as("bob"), a.ID)
+				So(err, ShouldBeNil)
+				So(got.State, ShouldEqual, disbursement.ApprovalApproved)
+				So(got.TransactionID, ShouldEqual, "txn_1")
+				So(payout.clients, ShouldResemble, []string{"acme"})
+
+				var actions []disbursement.ApprovalAction
+				for _, e := range got.History {
+					actions = append(actions, e.Action)
+				}
+				So(actions, ShouldResemble, []disbursement.ApprovalAction{
+					disbursement.ApprovalRequested, disbursement.ApprovalGranted,
+					disbursement.ApprovalGranted, disbursement.ApprovalSubmitted,
+				})
+				So(got.History[2].Role, ShouldEqual, "treasury")
+			})
+
+			Convey("and sent as its resolved sender even if the approver goes away", func() {
+				senders, err := inmem.NewSenderService([]*disbursement.Sender{{
+					ID:      "palngipang",
+					Name:    "Palngipang Corp.",
+					Address: disbursement.Address{Line1: "Some Tower", City: "Some City", Province: "Metro Manila", Country: "PH"},
+					Clients: []string{"acme"},
+				}}, "palngipang")
+				So(err, ShouldBeNil)
+				s.Senders = senders
+
+				_, err = s.Approve(as("alice"), a.ID)
+				So(err, ShouldBeNil)
+				gone, cancel := context.WithCancel(as("bob"))
+				cancel()
+				got, err := s.Approve(gone, a.ID)
+				So(err, ShouldBeNil)
+				So(got.Error, ShouldBeEmpty)
+				So(got.TransactionID, ShouldEqual, "txn_1")
+				So(payout.senders, ShouldResemble, []string{"palngipang"})
+			})
+
+			Convey("but not by the requester, strangers or approvers without the role", func() {
+				for _, ctx := range []context.Context{maker, as("mallory"), as("carol"), context.Background()} {
+					_, err := s.Approve(ctx, a.ID)
+					So(errors.Is(err, disbursement.ErrForbidden), ShouldBeTrue)
+				}
+			})
+
+			Convey("rejected transfers are never sent", func() {
+				_, err := s.Reject(as("bob"), a.ID, "")
+				So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+
+				got, err := s.Reject(as("bob"), a.ID, "duplicate of INV-9")
+				So(err, ShouldBeNil)
+				So(got.State, ShouldEqual, disbursement.ApprovalRejected)
+
+				_, err = s.Approve(as("alice"), a.ID)
+				So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+				So(payout.clients, ShouldBeEmpty)
+			})
+
+			Convey("transfers not approved in time expire", func() {
+				now = now.Add(4 * time.Hour)
+				So(s.Expire(context.Background()), ShouldBeNil)
+
+				got, err := s.FindApprovalByID(maker, a.ID)
+				So(err, ShouldBeNil)
+				So(got.State, ShouldEqual, disbursement.ApprovalExpired)
+
+				_, err = s.Approve(as("alice"), a.ID)
+				So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+			})
+
+			Convey("only the requester and approvers can see them", func() {
+				_, err := s.FindApprovalByID(as("other"), a.ID)
+				So(errors.Is(err, disbursement.ErrNotFound), ShouldBeTrue)
+
+				found, err := s.FindApprovals(as("other"), disbursement.ApprovalFilter{})
+				So(err, ShouldBeNil)
+				So(found, ShouldBeEmpty)
+
+				found, err = s.FindApprovals(as("carol"), disbursement.ApprovalFilter{State: disbursement.ApprovalPending})
+				So(err, ShouldBeNil)
+				So(found, ShouldHaveLength, 1)
+			})
+		})
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	Convey("the development rules load", t, func() {
+		config, err := LoadConfig("../approvals.dev.json")
+		So(err, ShouldBeNil)
+		_, err = NewService(&fakePayout{}, inmem.NewApprovalStore(), config)
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,117 @@
+package disbursement
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAuditTampered is matched by errors reporting that an audit log was
+// altered, reordered or has entries missing.
+var ErrAuditTampered = errors.New("audit log tampered")
+
+// AuditKind classifies audit entries.
+type AuditKind string
+
+const (
+	// AuditAPICall is a request to the disbursement API.
+	AuditAPICall AuditKind = "api_call"
+
+	// AuditStateChange is an event in the history of a transaction.
+	AuditStateChange AuditKind = "state_change"
+
+	// AuditApproval is an event in the history of a held transfer.
+	AuditApproval AuditKind = "approval"
+
+	// AuditConfig is a configuration file loaded by the service.
+	AuditConfig AuditKind = "config"
+
+	// AuditProviderCall is a request to the provider's API and its
+	// response.
+	AuditProviderCall AuditKind = "provider_call"
+)
+
+// AuditEntry records who did what. Each entry carries the hash of the entry
+// before it, so altering, removing or reordering entries breaks the chain.
+type AuditEntry struct {
+	Seq  int64     `json:"seq"`
+	At   time.Time `json:"at"`
+	Kind AuditKind `json:"kind"`
+
+	// ActorID is the API client or approver responsible, or empty for the
+	// service itself.
+	ActorID string `json:"actor_id,omitempty"`
+
+	// Subject is the id of the transaction, approval, configuration file or
+	// request path the entry is about.
+	Subject string `json:"subject,omitempty"`
+
+	Action string `json:"action"`
+
+	// Details holds fields specific to the kind of entry. Secrets and
+	// personal data are redacted before they get here.
+	Details json.RawMessage `json:"details,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// AuditDetails encodes v as the details of an entry.
+func AuditDetails(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return b
+}
+
+// ComputeHash returns the hash of e, covering every field but Hash.
+func (e *AuditEntry) ComputeHash() string {
+	cp := *e
+	cp.Hash = ""
+	b, _ := json.Marshal(&cp)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Chain makes e the entry following prev, or the first entry if prev is nil,
+// and seals it with its hash.
+func (e *AuditEntry) Chain(prev *AuditEntry) {
+	e.Seq, e.PrevHash = 1, ""
+	if prev != nil {
+		e.Seq, e.PrevHash = prev.Seq+1, prev.Hash
+	}
+	e.At = e.At.UTC()
+	e.Hash = e.ComputeHash()
+}
+
+// VerifyAuditEntry checks that e is sealed by its hash and follows prev, or
+// is the first entry if prev is nil. It returns an error matching
+// ErrAuditTampered if not.
+func VerifyAuditEntry(prev, e *AuditEntry) error {
+	var want int64 = 1
+	var prevHash string
+	if prev != nil {
+		want, prevHash = prev.Seq+1, prev.Hash
+	}
+	switch {
+	case e.Hash != e.ComputeHash():
+		return fmt.Errorf("%w: entry %d does not match its hash", ErrAuditTampered, e.Seq)
+	case e.Seq != want:
+		return fmt.Errorf("%w: expected entry %d, found %d", ErrAuditTampered, want, e.Seq)
+	case e.PrevHash != prevHash:
+		return fmt.Errorf("%w: entry %d does not follow entry %d", ErrAuditTampered, e.Seq, want-1)
+	}
+	return nil
+}
+
+// AuditLog is an append-only log of audit entries.
+type AuditLog interface {
+	// Record chains e to the last entry and appends it. At is set to the
+	// current time if zero.
+	Record(ctx context.Context, e *AuditEntry) error
+}
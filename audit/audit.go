@@ -0,0 +1,210 @@
+// Package audit feeds the audit log and verifies it. Transaction and approval
+// stores are wrapped so that every state change and approval decision is
+// recorded wherever it is made, and configuration files are recorded by
+// digest as they are loaded.
+//
+// Recording is best effort: a failure to write the log is reported but does
+// not undo or block the change being recorded, since that change has
+// already happened.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sync"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// record appends e to l, logging failures.
+func record(ctx context.Context, l disbursement.AuditLog, e *disbursement.AuditEntry) {
+	if err := l.Record(ctx, e); err != nil {
+		log.Printf("audit: cannot record %s %s of %s: %s", e.Kind, e.Action, e.Subject, err)
+	}
+}
+
+// TransactionStore records the history of every transaction it stores.
+type TransactionStore struct {
+	disbursement.TransactionStore
+	Log disbursement.AuditLog
+
+	// mu serializes updates, so that each new event is recorded once.
+	mu sync.Mutex
+}
+
+func (s *TransactionStore) CreateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	if err := s.TransactionStore.CreateTransaction(ctx, tx); err != nil {
+		return err
+	}
+	details := map[string]interface{}{
+		"method":        tx.Method,
+		"sender_ref_id": tx.SenderRefID,
+		"client_id":     tx.ClientID,
+	}
+	if tx.Request != nil {
+		details["amount"] = tx.Request.Details.Amount
+		details["sender_id"] = tx.Request.SenderID
+	}
+	record(ctx, s.Log, &disbursement.AuditEntry{
+		At:      tx.CreatedAt,
+		Kind:    disbursement.AuditStateChange,
+		ActorID: disbursement.ClientIDFromContext(ctx),
+		Subject: tx.ID,
+		Action:  "created",
+		Details: disbursement.AuditDetails(details),
+	})
+	s.recordHistory(ctx, tx, 0)
+	return nil
+}
+
+func (s *TransactionStore) UpdateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, err := s.TransactionStore.FindTransactionByID(ctx, tx.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.TransactionStore.UpdateTransaction(ctx, tx); err != nil {
+		return err
+	}
+	s.recordHistory(ctx, tx, len(prev.History))
+	return nil
+}
+
+// recordHistory records the events of tx from the from-th on.
+func (s *TransactionStore) recordHistory(ctx context.Context, tx *disbursement.Transaction, from int) {
+	for _, ev := range tx.History[from:] {
+		details := map[string]string{}
+		if ev.ProviderState != "" {
+			details["provider_state"] = ev.ProviderState
+		}
+		if ev.Error != "" {
+			details["error"] = ev.Error
+		}
+		if tx.ProviderTransactionID != "" {
+			details["provider_transaction_id"] = tx.ProviderTransactionID
+		}
+		record(ctx, s.Log, &disbursement.AuditEntry{
+			At:      ev.At,
+			Kind:    disbursement.AuditStateChange,
+			ActorID: disbursement.ClientIDFromContext(ctx),
+			Subject: tx.ID,
+			Action:  string(ev.State),
+			Details: disbursement.AuditDetails(details),
+		})
+	}
+}
+
+// ApprovalStore records every request, decision and outcome of the held
+// transfers it stores.
+type ApprovalStore struct {
+	disbursement.ApprovalStore
+	Log disbursement.AuditLog
+
+	// mu serializes updates, so that each new event is recorded once.
+	mu sync.Mutex
+}
+
+func (s *ApprovalStore) CreateApproval(ctx context.Context, a *disbursement.Approval) error {
+	if err := s.ApprovalStore.CreateApproval(ctx, a); err != nil {
+		return err
+	}
+	s.recordHistory(ctx, a, 0)
+	return nil
+}
+
+func (s *ApprovalStore) UpdateApproval(ctx context.Context, a *disbursement.Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, err := s.ApprovalStore.FindApprovalByID(ctx, a.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.ApprovalStore.UpdateApproval(ctx, a); err != nil {
+		return err
+	}
+	s.recordHistory(ctx, a, len(prev.History))
+	return nil
+}
+
+// recordHistory records the events of a from the from-th on.
+func (s *ApprovalStore) recordHistory(ctx context.Context, a *disbursement.Approval, from int) {
+	for _, ev := range a.History[from:] {
+		details := map[string]interface{}{"state": a.State}
+		if ev.Role != "" {
+			details["role"] = ev.Role
+		}
+		if ev.Reason != "" {
+			details["reason"] = ev.Reason
+		}
+		if ev.Action == disbursement.ApprovalRequested {
+			details["method"] = a.Method
+			details["amount"] = a.Disbursement.Details.Amount
+		}
+		if ev.Action == disbursement.ApprovalSubmitted && a.TransactionID != "" {
+			details["transaction_id"] = a.TransactionID
+		}
+		record(ctx, s.Log, &disbursement.AuditEntry{
+			At:      ev.At,
+			Kind:    disbursement.AuditApproval,
+			ActorID: ev.ActorID,
+			Subject: a.ID,
+			Action:  string(ev.Action),
+			Details: disbursement.AuditDetails(details),
+		})
+	}
+}
+
+// RecordConfig records that the configuration file at path was loaded as
+// name, by the SHA-256 digest of its contents. Comparing digests across
+// restarts shows when a file changed without exposing its secrets.
+func RecordConfig(ctx context.Context, l disbursement.AuditLog, name, path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	return l.Record(ctx, &disbursement.AuditEntry{
+		Kind:    disbursement.AuditConfig,
+		Subject: name,
+		Action:  "loaded",
+		Details: disbursement.AuditDetails(map[string]string{
+			"path":   path,
+			"sha256": hex.EncodeToString(sum[:]),
+		}),
+	})
+}
+
+// Verify reads a journal of audit entries from r and checks that each is
+// intact and follows the one before, calling fn, if not nil, with each
+// verified entry. It returns the last entry, whose hash can be kept elsewhere
+// to later detect entries removed from the end. Errors from a broken chain
+// match disbursement.ErrAuditTampered.
+func Verify(r io.Reader, fn func(e *disbursement.AuditEntry)) (*disbursement.AuditEntry, error) {
+	var last *disbursement.AuditEntry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e disbursement.AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return last, fmt.Errorf("%w: line %d: %s", disbursement.ErrAuditTampered, line, err)
+		}
+		if err := disbursement.VerifyAuditEntry(last, &e); err != nil {
+			return last, fmt.Errorf("line %d: %w", line, err)
+		}
+		if fn != nil {
+			fn(&e)
+		}
+		last = &e
+	}
+	return last, sc.Err()
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// actions returns the kind, actor and action of each entry.
+func actions(entries []*disbursement.AuditEntry) []string {
+	var got []string
+	for _, e := range entries {
+		got = append(got, string(e.Kind)+" "+e.ActorID+" "+e.Action)
+	}
+	return got
+}
+
+// journal encodes entries as the lines of a log file.
+func journal(entries []*disbursement.AuditEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		b, _ := json.Marshal(e)
+		buf.Write(append(b, '\n'))
+	}
+	return &buf
+}
+
+func TestTransactionStore(t *testing.T) {
+	Convey("every event in a transaction's history is recorded once", t, func() {
+		log := inmem.NewAuditLog()
+		store := &TransactionStore{TransactionStore: inmem.NewTransactionStore(), Log: log}
+		ctx := disbursement.NewContextWithClientID(context.Background(), "acme")
+		now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+		tx := &disbursement.Transaction{
+			Method:    disbursement.MethodInstapay,
+			Request:   &disbursement.Disbursement{Details: disbursement.Details{Amount: disbursement.Money{Minor: 3000, Currency: "PHP"}}},
+			CreatedAt: now,
+		}
+		tx.SetState(disbursement.TransferPending, "", now)
+		So(store.CreateTransaction(ctx, tx), ShouldBeNil)
+		tx.SetState(disbursement.TransferCredited, "CREDITED", now.Add(time.Minute))
+		So(store.UpdateTransaction(context.Background(), tx), ShouldBeNil)
+		So(store.UpdateTransaction(context.Background(), tx), ShouldBeNil)
+
+		entries := log.Entries()
+		So(actions(entries), ShouldResemble, []string{
+			"state_change acme created",
+			"state_change acme pending",
+			"state_change  credited",
+		})
+		So(entries[0].Subject, ShouldEqual, tx.ID)
+		So(string(entries[0].Details), ShouldContainSubstring, `"amount":"30.00"`)
+		So(string(entries[2].Details), ShouldContainSubstring, `"provider_state":"CREDITED"`)
+	})
+}
+
+func TestApprovalStore(t *testing.T) {
+	Convey("approval decisions are recorded with their approver", t, func() {
+		log := inmem.NewAuditLog()
+		store := &ApprovalStore{ApprovalStore: inmem.NewApprovalStore(), Log: log}
+		ctx := context.Background()
+
+		a := &disbursement.Approval{
+			ClientID:     "acme",
+			Method:       disbursement.MethodPesonet,
+			Disbursement: &disbursement.Disbursement{},
+			State:        disbursement.ApprovalPending,
+		}
+		a.Record(disbursement.ApprovalEvent{Action: disbursement.ApprovalRequested, ActorID: "acme"})
+		So(store.CreateApproval(ctx, a), ShouldBeNil)
+		a.State = disbursement.ApprovalRejected
+		a.Record(disbursement.ApprovalEvent{Action: disbursement.ApprovalDenied, ActorID: "treasury-1", Role: "treasury", Reason: "duplicate"})
+		So(store.UpdateApproval(ctx, a), ShouldBeNil)
+
+		entries := log.Entries()
+		So(actions(entries), ShouldResemble, []string{"approval acme requested", "approval treasury-1 rejected"})
+		So(string(entries[1].Details), ShouldContainSubstring, `"reason":"duplicate"`)
+	})
+}
+
+func TestRecordConfig(t *testing.T) {
+	Convey("configuration files are recorded by digest", t, func() {
+		path := filepath.Join(t.TempDir(), "limits.json")
+		So(ioutil.WriteFile(path, []byte(`{"limits":[]}`), 0600), ShouldBeNil)
+		log := inmem.NewAuditLog()
+		So(RecordConfig(context.Background(), log, "limits", path), ShouldBeNil)
+
+		e := log.Entries()[0]
+		So(e.Kind, ShouldEqual, disbursement.AuditConfig)
+		So(e.Subject, ShouldEqual, "limits")
+		So(string(e.Details), ShouldContainSubstring, `"sha256":"`)
+		So(string(e.Details), ShouldNotContainSubstring, "limits\":[]")
+	})
+}
+
+func TestVerify(t *testing.T) {
+	Convey("given a log of three entries", t, func() {
+		log := inmem.NewAuditLog()
+		for _, action := range []string{"GET", "POST", "DELETE"} {
+			So(log.Record(context.Background(), &disbursement.AuditEntry{Kind: disbursement.AuditAPICall, Action: action}), ShouldBeNil)
+		}
+		entries := log.Entries()
+
+		Convey("an intact log verifies", func() {
+			var n int
+			last, err := Verify(journal(entries), func(*disbursement.AuditEntry) { n++ })
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+			So(last.Hash, ShouldEqual, entries[2].Hash)
+		})
+
+		Convey("altered entries are detected", func() {
+			entries[1].ActorID = "someone-else"
+			_, err := Verify(journal(entries), nil)
+			So(errors.Is(err, disbursement.ErrAuditTampered), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "line 2")
+		})
+
+		Convey("resealed entries are detected by the next one", func() {
+			entries[1].ActorID = "someone-else"
+			entries[1].Hash = entries[1].ComputeHash()
+			_, err := Verify(journal(entries), nil)
+			So(errors.Is(err, disbursement.ErrAuditTampered), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "line 3")
+		})
+
+		Convey("gaps are detected", func() {
+			_, err := Verify(journal([]*disbursement.AuditEntry{entries[0], entries[2]}), nil)
+			So(errors.Is(err, disbursement.ErrAuditTampered), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "expected entry 2")
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package disbursement
+
+import "context"
+
+// DirectoryBank is a bank with every rail it takes part in.
+type DirectoryBank struct {
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Methods []Method `json:"methods"`
+}
+
+// BankFilter selects banks from a directory. Zero fields match everything.
+type BankFilter struct {
+	// Query matches bank codes and names, tolerating typos.
+	Query string
+
+	// Method selects the banks taking part in a rail.
+	Method Method
+
+	// Offset skips that many matching banks. Limit caps the number of banks
+	// returned; zero means no limit.
+	Offset int
+	Limit  int
+}
+
+// BankDirectory lists the banks of every rail without asking the provider on
+// each call.
+type BankDirectory interface {
+	BankLookup
+
+	// Banks returns the banks taking part in a rail.
+	Banks(ctx context.Context, method Method) ([]Bank, error)
+
+	// FindBanks returns the page of banks matching filter, best matches
+	// first, and the number of banks matching it in all.
+	FindBanks(ctx context.Context, filter BankFilter) ([]*DirectoryBank, int, error)
+}
@@ -0,0 +1,218 @@
+// Package bankdir keeps the bank lists of every rail in memory so they can be
+// served, searched and checked without calling the provider each time. Lists
+// are refreshed in the background and served stale while the provider is
+// down.
+package bankdir
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Directory defaults.
+const (
+	DefaultTTL             = time.Hour
+	DefaultRefreshInterval = 30 * time.Minute
+)
+
+// Directory is a disbursement.BankDirectory over the bank lists of a
+// provider.
+type Directory struct {
+	Provider disbursement.DisbursementService
+
+	// Methods are the rails with bank lists, in the order their names are
+	// preferred when a bank is listed differently on each.
+	Methods []disbursement.Method
+
+	// TTL is how old a list may be before a lookup fetches it again. If
+	// that fetch fails the old list is served.
+	TTL time.Duration
+
+	// RefreshInterval is how often Run fetches every list.
+	RefreshInterval time.Duration
+
+	mu    sync.Mutex
+	lists map[disbursement.Method]*bankList
+
+	now func() time.Time
+}
+
+// bankList is the cached bank list of one rail.
+type bankList struct {
+	banks     []disbursement.Bank
+	fetchedAt time.Time
+}
+
+func NewDirectory(provider disbursement.DisbursementService) *Directory {
+	return &Directory{
+		Provider:        provider,
+		Methods:         []disbursement.Method{disbursement.MethodInstapay, disbursement.MethodPesonet},
+		TTL:             DefaultTTL,
+		RefreshInterval: DefaultRefreshInterval,
+		lists:           make(map[disbursement.Method]*bankList),
+		now:             time.Now,
+	}
+}
+
+// Run refreshes every list each RefreshInterval until ctx is done.
+func (d *Directory) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.Refresh(ctx); err != nil {
+			log.Printf("bankdir: refresh: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh fetches every list. Lists that cannot be fetched keep their
+// previous contents; the first such error is returned.
+func (d *Directory) Refresh(ctx context.Context) error {
+	var first error
+	for _, method := range d.Methods {
+		if _, err := d.fetch(ctx, method); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Banks returns the banks taking part in a rail.
+func (d *Directory) Banks(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	banks, err := d.list(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	return append([]disbursement.Bank(nil), banks...), nil
+}
+
+// HasBank reports whether the bank with the given code takes part in a rail.
+func (d *Directory) HasBank(ctx context.Context, method disbursement.Method, code string) (bool, error) {
+	banks, err := d.list(ctx, method)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range banks {
+		if b.Code == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindBanks merges the lists of every rail and returns the page of banks
+// matching filter.
+func (d *Directory) FindBanks(ctx context.Context, filter disbursement.BankFilter) ([]*disbursement.DirectoryBank, int, error) {
+	var merged []*disbursement.DirectoryBank
+	byCode := make(map[string]*disbursement.DirectoryBank)
+	for _, method := range d.Methods {
+		banks, err := d.list(ctx, method)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, b := range banks {
+			db, ok := byCode[b.Code]
+			if !ok {
+				db = &disbursement.DirectoryBank{Code: b.Code, Name: b.Name}
+				byCode[b.Code] = db
+				merged = append(merged, db)
+			}
+			db.Methods = append(db.Methods, method)
+		}
+	}
+
+	q := newQuery(filter.Query)
+	var matches []match
+	for _, b := range merged {
+		if filter.Method != "" && !hasMethod(b, filter.Method) {
+			continue
+		}
+		if score, ok := q.score(b); ok {
+			matches = append(matches, match{bank: b, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score < matches[j].score
+		}
+		return matches[i].bank.Name < matches[j].bank.Name
+	})
+
+	total := len(matches)
+	if filter.Offset > len(matches) {
+		matches = nil
+	} else {
+		matches = matches[filter.Offset:]
+	}
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+	}
+	banks := make([]*disbursement.DirectoryBank, len(matches))
+	for i, m := range matches {
+		banks[i] = m.bank
+	}
+	return banks, total, nil
+}
+
+// list returns the cached list of a rail, fetching it if it is missing or
+// older than TTL.
+func (d *Directory) list(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	if !d.supports(method) {
+		return nil, fmt.Errorf("%w: no bank list for %s", disbursement.ErrInvalid, method)
+	}
+
+	d.mu.Lock()
+	l := d.lists[method]
+	d.mu.Unlock()
+	if l != nil && d.now().Sub(l.fetchedAt) < d.TTL {
+		return l.banks, nil
+	}
+
+	banks, err := d.fetch(ctx, method)
+	if err != nil && l != nil {
+		log.Printf("bankdir: serving %s banks from %s: %s", method, l.fetchedAt.Format(time.RFC3339), err)
+		return l.banks, nil
+	}
+	return banks, err
+}
+
+// fetch replaces the list of a rail with the provider's.
+func (d *Directory) fetch(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	banks, err := d.Provider.GetBanks(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.lists[method] = &bankList{banks: banks, fetchedAt: d.now()}
+	d.mu.Unlock()
+	return banks, nil
+}
+
+func (d *Directory) supports(method disbursement.Method) bool {
+	for _, m := range d.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMethod(b *disbursement.DirectoryBank, method disbursement.Method) bool {
+	for _, m := range b.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package bankdir
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeProvider serves fixed bank lists, counting calls.
+type fakeProvider struct {
+	disbursement.DisbursementService
+
+	mu    sync.Mutex
+	banks map[disbursement.Method][]disbursement.Bank
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) GetBanks(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.banks[method], nil
+}
+
+func TestDirectory(t *testing.T) {
+	Convey("given a directory over both rails", t, func() {
+		ctx := context.Background()
+		provider := &fakeProvider{banks: map[disbursement.Method][]disbursement.Bank{
+			disbursement.MethodInstapay: {
+				{Code: "010419", Name: "BDO Unibank, Inc."},
+				{Code: "161408", Name: "Metropolitan Bank and Trust Co."},
+				{Code: "147100", Name: "GCash"},
+			},
+			disbursement.MethodPesonet: {
+				{Code: "010419", Name: "BDO Unibank Inc"},
+				{Code: "161408", Name: "Metrobank"},
+				{Code: "140013", Name: "Security Bank Corporation"},
+			},
+		}}
+		now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+		d := NewDirectory(provider)
+		d.now = func() time.Time { return now }
+
+		Convey("lists are merged with the rails of each bank", func() {
+			banks, total, err := d.FindBanks(ctx, disbursement.BankFilter{})
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 4)
+			So(banks[0].Name, ShouldEqual, "BDO Unibank, Inc.")
+			So(banks[0].Methods, ShouldResemble, []disbursement.Method{disbursement.MethodInstapay, disbursement.MethodPesonet})
+
+			pesonetOnly, _, _ := d.FindBanks(ctx, disbursement.BankFilter{Query: "security"})
+			So(pesonetOnly[0].Methods, ShouldResemble, []disbursement.Method{disbursement.MethodPesonet})
+		})
+
+		Convey("searches match codes, names and typos", func() {
+			find := func(q string) []string {
+				banks, _, err := d.FindBanks(ctx, disbursement.BankFilter{Query: q})
+				So(err, ShouldBeNil)
+				var codes []string
+				for _, b := range banks {
+					codes = append(codes, b.Code)
+				}
+				return codes
+			}
+			So(find("161408"), ShouldResemble, []string{"161408"})
+			So(find("metro"), ShouldResemble, []string{"161408"})
+			So(find("bdo unibank"), ShouldResemble, []string{"010419"})
+			So(find("securty"), ShouldResemble, []string{"140013"})
+			So(find("gcsh"), ShouldResemble, []string{"147100"})
+			So(find("bpi"), ShouldBeEmpty)
+		})
+
+		Convey("results are paginated", func() {
+			banks, total, err := d.FindBanks(ctx, disbursement.BankFilter{Method: disbursement.MethodInstapay, Offset: 1, Limit: 1})
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 3)
+			So(banks, ShouldHaveLength, 1)
+			So(banks[0].Name, ShouldEqual, "GCash")
+		})
+
+		Convey("lists are cached until they expire", func() {
+			ok, err := d.HasBank(ctx, disbursement.MethodPesonet, "140013")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			d.HasBank(ctx, disbursement.MethodPesonet, "147100")
+			So(provider.calls, ShouldEqual, 1)
+
+			now = now.Add(DefaultTTL)
+			d.HasBank(ctx, disbursement.MethodPesonet, "147100")
+			So(provider.calls, ShouldEqual, 2)
+		})
+
+		Convey("stale lists are served while the provider is down", func() {
+			So(d.Refresh(ctx), ShouldBeNil)
+			provider.err = disbursement.ErrRetryable
+			now = now.Add(2 * DefaultTTL)
+
+			banks, err := d.Banks(ctx, disbursement.MethodInstapay)
+			So(err, ShouldBeNil)
+			So(banks, ShouldHaveLength, 3)
+			So(d.Refresh(ctx), ShouldEqual, disbursement.ErrRetryable)
+
+			_, err = NewDirectory(provider).Banks(ctx, disbursement.MethodInstapay)
+			So(err, ShouldEqual, disbursement.ErrRetryable)
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package bankdir
+
+import (
+	"strings"
+	"unicode"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// match is a bank matching a query, with lower scores for better matches.
+type match struct {
+	bank  *disbursement.DirectoryBank
+	score int
+}
+
+// Scores of the kinds of match, best first. Typo matches add their edit
+// distance to scoreFuzzy.
+const (
+	scoreCode = iota
+	scoreCodePrefix
+	scoreName
+	scoreNamePrefix
+	scoreWordPrefixes
+	scoreSubstring
+	scoreFuzzy
+)
+
+// query is a normalized search query.
+type query struct {
+	text  string
+	words []string
+}
+
+func newQuery(s string) query {
+	text := normalize(s)
+	return query{text: text, words: strings.Fields(text)}
+}
+
+// score reports whether b matches q and how well. An empty query matches
+// every bank equally.
+func (q query) score(b *disbursement.DirectoryBank) (int, bool) {
+	if q.text == "" {
+		return 0, true
+	}
+
+	code := strings.ToLower(b.Code)
+	name := normalize(b.Name)
+	words := strings.Fields(name)
+	switch {
+	case code == q.text:
+		return scoreCode, true
+	case strings.HasPrefix(code, q.text):
+		return scoreCodePrefix, true
+	case name == q.text:
+		return scoreName, true
+	case strings.HasPrefix(name, q.text):
+		return scoreNamePrefix, true
+	case everyWord(q.words, words, func(qw, w string) bool { return strings.HasPrefix(w, qw) }):
+		return scoreWordPrefixes, true
+	case strings.Contains(name, q.text):
+		return scoreSubstring, true
+	}
+
+	// Otherwise each query word must be within a few typos of the start of
+	// some word of the name, so "securty" finds "Security Bank".
+	total := 0
+	for _, qw := range q.words {
+		best := -1
+		for _, w := range words {
+			if d := prefixDistance(qw, w); d <= maxEdits(qw) && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		total += best
+	}
+	return scoreFuzzy + total, true
+}
+
+// maxEdits is the number of typos tolerated in a query word. Short words
+// must match exactly so that "bdo" does not find every three-letter bank.
+func maxEdits(word string) int {
+	switch n := len(word); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func everyWord(qwords, words []string, ok func(qw, w string) bool) bool {
+	for _, qw := range qwords {
+		found := false
+		for _, w := range words {
+			if ok(qw, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize lowercases s and replaces punctuation with spaces, so "BDO
+// Unibank, Inc." becomes "bdo unibank inc".
+func normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// prefixDistance is the smallest distance between qw and a prefix of w about
+// as long as qw, allowing for a letter missed or added.
+func prefixDistance(qw, w string) int {
+	best := -1
+	for n := len(qw) - 1; n <= len(qw)+1; n++ {
+		if n < 1 || n > len(w) {
+			continue
+		}
+		if d := distance(qw, w[:n]); best < 0 || d < best {
+			best = d
+		}
+	}
+	if best < 0 {
+		return distance(qw, w)
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
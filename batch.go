@@ -0,0 +1,179 @@
+package disbursement
+
+import (
+	"context"
+	"time"
+)
+
+// BatchState is the progress of a batch as a whole.
+type BatchState string
+
+const (
+	// BatchRunning means some items are still waiting to be submitted.
+	BatchRunning BatchState = "running"
+
+	// BatchCompleted means every item was submitted, failed or cancelled.
+	BatchCompleted BatchState = "completed"
+)
+
+// BatchItemState is the progress of one item of a batch.
+type BatchItemState string
+
+const (
+	// BatchItemQueued means the item has not been sent yet and can still
+	// be cancelled.
+	BatchItemQueued BatchItemState = "queued"
+
+	// BatchItemSubmitting means the item is being sent to the provider.
+	BatchItemSubmitting BatchItemState = "submitting"
+
+	// BatchItemSubmitted means the provider accepted the item. Its
+	// transaction tracks the transfer from there.
+	BatchItemSubmitted BatchItemState = "submitted"
+
+	// BatchItemFailed means the item could not be sent.
+	BatchItemFailed BatchItemState = "failed"
+
+	// BatchItemCancelled means the item was cancelled before it was sent.
+	BatchItemCancelled BatchItemState = "cancelled"
+
+	// BatchItemUnknown means sending the item was interrupted, so it may or
+	// may not have reached the provider. Look up its transaction by
+	// reference id before sending it again.
+	BatchItemUnknown BatchItemState = "unknown"
+)
+
+// Done reports whether the item will not change any more.
+func (s BatchItemState) Done() bool {
+	return s != BatchItemQueued && s != BatchItemSubmitting
+}
+
+// BatchItem is one disbursement of a batch.
+type BatchItem struct {
+	Index        int           `json:"index"`
+	Method       Method        `json:"method"`
+	Disbursement *Disbursement `json:"disbursement"`
+
+	// Line is the line of the uploaded file the item was read from, if
+	// any.
+	Line int `json:"line,omitempty"`
+
+	State BatchItemState `json:"state"`
+
+	// SenderRefID is the provider reference id the item is sent with. It
+	// is recorded before the item is sent, so an interrupted item can be
+	// matched to its transaction.
+	SenderRefID string `json:"sender_ref_id,omitempty"`
+
+	// DeferredUntil is when the item is held until, if it missed its
+	// rail's cutoff in a batch that defers such items.
+	DeferredUntil *time.Time `json:"deferred_until,omitempty"`
+
+	// TransactionID and Result are set once the item was submitted.
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Result        *TransferResult `json:"result,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Batch is a set of disbursements requested together and executed in the
+// background.
+type Batch struct {
+	ID       string       `json:"id"`
+	ClientID string       `json:"client_id,omitempty"`
+	State    BatchState   `json:"state"`
+	Items    []*BatchItem `json:"items"`
+
+	// AfterCutoff is what to do with items that miss their rail's cutoff.
+	// Empty means CutoffSubmit.
+	AfterCutoff CutoffPolicy `json:"after_cutoff,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// NewBatchID returns a random batch id.
+func NewBatchID() string {
+	return newID("bat_")
+}
+
+// BatchTotals summarizes the items of a batch.
+type BatchTotals struct {
+	Count     int `json:"count"`
+	Queued    int `json:"queued"`
+	Submitted int `json:"submitted"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	Unknown   int `json:"unknown"`
+
+	// Amount is the sum of every item; SubmittedAmount of the items the
+	// provider accepted.
+	Amount          Money `json:"amount"`
+	SubmittedAmount Money `json:"submitted_amount"`
+}
+
+// Totals counts the items of b by state and sums their amounts. Items in
+// another currency than DefaultCurrency are left out of the sums.
+func (b *Batch) Totals() BatchTotals {
+	t := BatchTotals{
+		Count:           len(b.Items),
+		Amount:          Money{Currency: DefaultCurrency},
+		SubmittedAmount: Money{Currency: DefaultCurrency},
+	}
+
+	for _, item := range b.Items {
+		amount := item.Disbursement.Details.Amount
+		switch item.State {
+		case BatchItemQueued, BatchItemSubmitting:
+			t.Queued++
+		case BatchItemSubmitted:
+			t.Submitted++
+			if sum, err := t.SubmittedAmount.Add(amount); err == nil {
+				t.SubmittedAmount = sum
+			}
+		case BatchItemFailed:
+			t.Failed++
+		case BatchItemCancelled:
+			t.Cancelled++
+		case BatchItemUnknown:
+			t.Unknown++
+		}
+		if sum, err := t.Amount.Add(amount); err == nil {
+			t.Amount = sum
+		}
+	}
+	return t
+}
+
+// BatchStore persists batches.
+type BatchStore interface {
+	CreateBatch(ctx context.Context, b *Batch) error
+
+	// UpdateBatch replaces the state and timestamps of a stored batch,
+	// leaving its items alone. It returns ErrNotFound if b was never
+	// created.
+	UpdateBatch(ctx context.Context, b *Batch) error
+
+	// UpdateBatchItem replaces one item of a stored batch.
+	UpdateBatchItem(ctx context.Context, batchID string, item *BatchItem) error
+
+	// FindBatchByID returns ErrNotFound if there is no such batch.
+	FindBatchByID(ctx context.Context, id string) (*Batch, error)
+
+	// FindBatches returns the batches in the given state, oldest first.
+	FindBatches(ctx context.Context, state BatchState) ([]*Batch, error)
+}
+
+// BatchService executes batches.
+type BatchService interface {
+	// CreateBatch stores b and starts executing its items. Items must
+	// already be validated.
+	CreateBatch(ctx context.Context, b *Batch) error
+
+	// FindBatchByID returns the batch if the calling client owns it.
+	FindBatchByID(ctx context.Context, id string) (*Batch, error)
+
+	// CancelBatch cancels every item of the batch that was not sent yet.
+	CancelBatch(ctx context.Context, id string) (*Batch, error)
+}
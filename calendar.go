@@ -0,0 +1,47 @@
+package disbursement
+
+import "time"
+
+// Settlement is when a transfer is expected to reach the receiver.
+type Settlement struct {
+	ExpectedAt time.Time `json:"expected_at"`
+
+	// AfterCutoff means the transfer was submitted outside the rail's
+	// window for the day, so it settles on a later banking day.
+	AfterCutoff bool `json:"after_cutoff,omitempty"`
+
+	// NextWindow is the earliest time from submission that the rail takes
+	// transfers for same-day settlement. It is the submission time unless
+	// AfterCutoff is set.
+	NextWindow time.Time `json:"next_window"`
+}
+
+// Calendar knows the banking days and cutoff times of each rail.
+type Calendar interface {
+	// Settlement returns when a transfer submitted through method at t is
+	// expected to settle.
+	Settlement(method Method, t time.Time) Settlement
+}
+
+// CutoffPolicy is what to do with transfers submitted after their rail's
+// cutoff.
+type CutoffPolicy string
+
+const (
+	// CutoffSubmit sends them at once, to settle on the next banking day.
+	CutoffSubmit CutoffPolicy = "submit"
+
+	// CutoffDefer holds them until the rail's next window opens.
+	CutoffDefer CutoffPolicy = "defer"
+)
+
+// ParseCutoffPolicy returns the policy named s. An empty s is CutoffSubmit.
+func ParseCutoffPolicy(s string) (CutoffPolicy, error) {
+	switch p := CutoffPolicy(s); p {
+	case "":
+		return CutoffSubmit, nil
+	case CutoffSubmit, CutoffDefer:
+		return p, nil
+	}
+	return "", &ValidationError{Errors: []FieldError{{Field: "after_cutoff", Message: "must be submit or defer"}}}
+}
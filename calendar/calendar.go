@@ -0,0 +1,164 @@
+// Package calendar tells when transfers settle. Batch rails such as PESONet
+// settle on banking days only, and a transfer submitted after a rail's cutoff
+// waits for the next banking day. Banking days are weekdays other than the
+// Philippine banking holidays listed in the configuration.
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// manila is the zone dates and times of day are given in.
+var manila = time.FixedZone("PHT", 8*60*60)
+
+// Holiday is a day on which banks do not settle.
+type Holiday struct {
+	Date string `json:"date"` // "2006-01-02"
+	Name string `json:"name"`
+}
+
+// Rail is the settlement schedule of one rail.
+type Rail struct {
+	// RealTime means transfers settle within seconds, on any day. The
+	// other fields are ignored.
+	RealTime bool `json:"real_time"`
+
+	// Cutoff is the time of day, as "15:04", after which transfers settle
+	// on the next banking day.
+	Cutoff string `json:"cutoff,omitempty"`
+
+	// SettlesAt is the time of day, as "15:04", by which the transfers of
+	// a banking day are credited. Defaults to Cutoff.
+	SettlesAt string `json:"settles_at,omitempty"`
+}
+
+// Config is the layout of a calendar configuration file.
+type Config struct {
+	Holidays []Holiday                    `json:"holidays"`
+	Rails    map[disbursement.Method]Rail `json:"rails"`
+}
+
+// Calendar is a disbursement.Calendar. Rails it has no schedule for are
+// taken to be real-time.
+type Calendar struct {
+	holidays map[string]string
+	rails    map[disbursement.Method]schedule
+}
+
+// schedule is a Rail with its times of day in minutes.
+type schedule struct {
+	cutoff, settles int
+}
+
+// New returns the Calendar described by config.
+func New(config Config) (*Calendar, error) {
+	c := &Calendar{
+		holidays: make(map[string]string, len(config.Holidays)),
+		rails:    make(map[disbursement.Method]schedule, len(config.Rails)),
+	}
+	for _, h := range config.Holidays {
+		if _, err := time.Parse("2006-01-02", h.Date); err != nil {
+			return nil, fmt.Errorf("%w: holiday %q is not YYYY-MM-DD", disbursement.ErrInvalid, h.Date)
+		}
+		c.holidays[h.Date] = h.Name
+	}
+	for method, rail := range config.Rails {
+		if _, err := disbursement.ParseMethod(string(method)); err != nil {
+			return nil, err
+		}
+		if rail.RealTime {
+			continue
+		}
+		cutoff, err := minutes(rail.Cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s cutoff %q is not HH:MM", disbursement.ErrInvalid, method, rail.Cutoff)
+		}
+		settles := cutoff
+		if rail.SettlesAt != "" {
+			if settles, err = minutes(rail.SettlesAt); err != nil {
+				return nil, fmt.Errorf("%w: %s settlement time %q is not HH:MM", disbursement.ErrInvalid, method, rail.SettlesAt)
+			}
+		}
+		c.rails[method] = schedule{cutoff: cutoff, settles: settles}
+	}
+	return c, nil
+}
+
+// Load returns the Calendar described by the JSON configuration file at path.
+func Load(path string) (*Calendar, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c, err := New(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// BankingDay reports whether banks settle on the day t falls on in the
+// Philippines.
+func (c *Calendar) BankingDay(t time.Time) bool {
+	t = t.In(manila)
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, ok := c.holidays[t.Format("2006-01-02")]
+	return !ok
+}
+
+// Settlement returns when a transfer submitted through method at t is
+// expected to settle: on the day of t if that is a banking day and t is
+// before the cutoff, otherwise on the next banking day.
+func (c *Calendar) Settlement(method disbursement.Method, t time.Time) disbursement.Settlement {
+	s, ok := c.rails[method]
+	if !ok {
+		return disbursement.Settlement{ExpectedAt: t, NextWindow: t}
+	}
+
+	local := t.In(manila)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, manila)
+	if c.BankingDay(day) && local.Hour()*60+local.Minute() < s.cutoff {
+		return disbursement.Settlement{ExpectedAt: at(day, s.settles), NextWindow: t}
+	}
+
+	next := c.nextBankingDay(day)
+	return disbursement.Settlement{
+		ExpectedAt:  at(next, s.settles),
+		AfterCutoff: true,
+		NextWindow:  next,
+	}
+}
+
+// nextBankingDay returns the start of the first banking day after day.
+func (c *Calendar) nextBankingDay(day time.Time) time.Time {
+	next := day.AddDate(0, 0, 1)
+	for !c.BankingDay(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// at returns the time of day m minutes into day.
+func at(day time.Time, m int) time.Time {
+	return day.Add(time.Duration(m) * time.Minute)
+}
+
+// minutes parses a "15:04" time of day into minutes past midnight.
+func minutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,83 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCalendar(t *testing.T) {
+	Convey("given a calendar with a PESONet cutoff and holidays", t, func() {
+		c, err := New(Config{
+			Holidays: []Holiday{
+				{Date: "2026-11-02", Name: "All Souls' Day"},
+				{Date: "2026-11-30", Name: "Bonifacio Day"},
+			},
+			Rails: map[disbursement.Method]Rail{
+				disbursement.MethodInstapay: {RealTime: true},
+				disbursement.MethodPesonet:  {Cutoff: "15:00", SettlesAt: "17:00"},
+			},
+		})
+		So(err, ShouldBeNil)
+		date := func(month time.Month, day, hour, min int) time.Time {
+			return time.Date(2026, month, day, hour, min, 0, 0, manila)
+		}
+
+		Convey("weekends and holidays are not banking days", func() {
+			So(c.BankingDay(date(10, 30, 12, 0)), ShouldBeTrue)
+			So(c.BankingDay(date(10, 31, 12, 0)), ShouldBeFalse)
+			So(c.BankingDay(date(11, 1, 12, 0)), ShouldBeFalse)
+			So(c.BankingDay(date(11, 2, 12, 0)), ShouldBeFalse)
+			// 16:30 UTC on the 29th is already the 30th in Manila.
+			So(c.BankingDay(time.Date(2026, 11, 29, 16, 30, 0, 0, time.UTC)), ShouldBeFalse)
+		})
+
+		Convey("transfers before the cutoff settle the same day", func() {
+			at := date(10, 19, 14, 59)
+			s := c.Settlement(disbursement.MethodPesonet, at)
+			So(s.ExpectedAt, ShouldEqual, date(10, 19, 17, 0))
+			So(s.AfterCutoff, ShouldBeFalse)
+			So(s.NextWindow, ShouldEqual, at)
+		})
+
+		Convey("transfers after the cutoff settle on the next banking day", func() {
+			// Friday afternoon, before a weekend and a holiday.
+			s := c.Settlement(disbursement.MethodPesonet, date(10, 30, 15, 0))
+			So(s.ExpectedAt, ShouldEqual, date(11, 3, 17, 0))
+			So(s.AfterCutoff, ShouldBeTrue)
+			So(s.NextWindow, ShouldEqual, date(11, 3, 0, 0))
+
+			s = c.Settlement(disbursement.MethodPesonet, date(11, 1, 9, 0))
+			So(s.ExpectedAt, ShouldEqual, date(11, 3, 17, 0))
+			So(s.AfterCutoff, ShouldBeTrue)
+		})
+
+		Convey("real-time and unknown rails settle at once", func() {
+			at := date(10, 31, 23, 0)
+			for _, m := range []disbursement.Method{disbursement.MethodInstapay, disbursement.MethodUBP} {
+				s := c.Settlement(m, at)
+				So(s.ExpectedAt, ShouldEqual, at)
+				So(s.AfterCutoff, ShouldBeFalse)
+			}
+		})
+	})
+
+	Convey("malformed configurations are rejected", t, func() {
+		_, err := New(Config{Holidays: []Holiday{{Date: "30/11/2026"}}})
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+
+		_, err = New(Config{Rails: map[disbursement.Method]Rail{disbursement.MethodPesonet: {}}})
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+
+		_, err = New(Config{Rails: map[disbursement.Method]Rail{"gcash": {RealTime: true}}})
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+	})
+
+	Convey("the development calendar loads", t, func() {
+		_, err := Load("../calendar.dev.json")
+		So(err, ShouldBeNil)
+	})
+}
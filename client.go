@@ -0,0 +1,79 @@
+package disbursement
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Scope is a permission granted to an API client.
+type Scope string
+
+const (
+	// ScopeReadBanks allows listing banks and purpose codes.
+	ScopeReadBanks Scope = "banks:read"
+
+	// ScopeReadStatus allows looking up transfers, transactions, batches
+	// and approvals.
+	ScopeReadStatus Scope = "status:read"
+
+	// ScopeCreateTransfer allows sending transfers and creating or
+	// cancelling batches.
+	ScopeCreateTransfer Scope = "transfers:create"
+
+	// ScopeApprove allows approving and rejecting held transfers.
+	ScopeApprove Scope = "approvals:approve"
+
+	// ScopeManageWebhooks allows managing webhook subscriptions.
+	ScopeManageWebhooks Scope = "webhooks:manage"
+
+	// ScopeReadLedger allows reading ledger balances and entries.
+	ScopeReadLedger Scope = "ledger:read"
+
+	// ScopeWriteLedger allows recording deposits into the partner account.
+	ScopeWriteLedger Scope = "ledger:write"
+)
+
+// Client is a caller of the disbursement API.
+type Client struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+
+	// APIKeyHashes are the hex SHA-256 digests of the client's API keys, as
+	// returned by HashAPIKey. Several keys may be valid while one is
+	// rotated.
+	APIKeyHashes []string `json:"api_key_hashes,omitempty"`
+
+	// SigningSecret is the secret the client signs requests with. If
+	// empty the client cannot sign requests.
+	SigningSecret string `json:"signing_secret,omitempty"`
+
+	// Disabled clients cannot make requests.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// HasScope reports whether c was granted scope.
+func (c *Client) HasScope(scope Scope) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashAPIKey returns the digest under which an API key is registered.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientService looks up API clients.
+type ClientService interface {
+	// FindClientByID returns the client with the given id, or ErrNotFound.
+	FindClientByID(ctx context.Context, id string) (*Client, error)
+
+	// FindClientByAPIKey returns the client holding key, or ErrNotFound.
+	FindClientByAPIKey(ctx context.Context, key string) (*Client, error)
+}
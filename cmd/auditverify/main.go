@@ -0,0 +1,53 @@
+// Command auditverify checks that an audit log has not been tampered with.
+//
+//	auditverify [-head HASH] PATH
+//
+// It exits with status 1 if any entry was altered, removed or reordered. The
+// hash of the last entry is printed so it can be kept apart from the log;
+// passing it as -head on a later run also detects entries removed from the
+// end of the log.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/audit"
+)
+
+func main() {
+	head := flag.String("head", "", "hash of an entry the log must still contain")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: auditverify [-head HASH] PATH")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Error opening audit log: %s\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	seen := *head == ""
+	last, err := audit.Verify(f, func(e *disbursement.AuditEntry) {
+		if e.Hash == *head {
+			seen = true
+		}
+	})
+	if err != nil {
+		fmt.Printf("Audit log is not intact: %s\n", err)
+		os.Exit(1)
+	} else if !seen {
+		fmt.Printf("Audit log is not intact: entry %s is missing\n", *head)
+		os.Exit(1)
+	}
+	if last == nil {
+		fmt.Println("Audit log is empty")
+		return
+	}
+	fmt.Printf("Audit log is intact: %d entries, last %s\n", last.Seq, last.Hash)
+}
@@ -1,26 +1,324 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/approval"
+	"github.com/jfpalngipang/fund-disbursement/audit"
+	"github.com/jfpalngipang/fund-disbursement/bankdir"
+	"github.com/jfpalngipang/fund-disbursement/calendar"
+	"github.com/jfpalngipang/fund-disbursement/filestore"
 	"github.com/jfpalngipang/fund-disbursement/http"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	"github.com/jfpalngipang/fund-disbursement/ledger"
+	"github.com/jfpalngipang/fund-disbursement/limits"
+	"github.com/jfpalngipang/fund-disbursement/payout"
+	"github.com/jfpalngipang/fund-disbursement/routing"
 	"github.com/jfpalngipang/fund-disbursement/ubp"
+	"github.com/jfpalngipang/fund-disbursement/webhook"
 )
 
 func main() {
 	httpServer := http.NewServer()
 	ub := ubp.UBP{}
 	ub.Init()
+
+	auditLog, err := openAuditLog(os.Getenv("AUDIT_LOG_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening audit log: %s\n", err)
+		os.Exit(1)
+	}
+	ub.SetAuditLog(auditLog)
+	recordConfig(auditLog, "ubp", "/app/ubp/config.dev.json")
+
 	disbursementService := ubp.NewDisbursementService(&ub)
-	httpServer.DisbursementService = disbursementService
+	bankDirectory := bankdir.NewDirectory(disbursementService)
+	go bankDirectory.Run(context.Background())
+	httpServer.BankDirectory = bankDirectory
+	validator := disbursement.NewValidator(bankDirectory)
+	if path := os.Getenv("PURPOSES_CONFIG_PATH"); path != "" {
+		if validator.Purposes, err = loadPurposes(path); err != nil {
+			fmt.Printf("Error loading purpose codes: %s\n", err)
+			os.Exit(1)
+		}
+		recordConfig(auditLog, "purposes", path)
+	}
+	httpServer.Validator = validator
 	httpServer.Addr = ":8080"
+	if purpose := os.Getenv("DEFAULT_PURPOSE"); purpose != "" {
+		httpServer.DefaultPurpose = purpose
+	}
 	// httpServer.Host = "127.0.0.1"
 
+	idempotencyStore, err := openIdempotencyStore(os.Getenv("IDEMPOTENCY_STORE_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening idempotency store: %s\n", err)
+		os.Exit(1)
+	}
+	httpServer.IdempotencyStore = idempotencyStore
+
+	transactionStore, err := openTransactionStore(os.Getenv("TRANSACTION_STORE_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening transaction store: %s\n", err)
+		os.Exit(1)
+	}
+
+	rules := routing.DefaultRules
+	if path := os.Getenv("ROUTING_RULES_PATH"); path != "" {
+		if rules, err = routing.LoadRules(path); err != nil {
+			fmt.Printf("Error loading routing rules: %s\n", err)
+			os.Exit(1)
+		}
+		recordConfig(auditLog, "routing", path)
+	}
+
+	ledgerPath := os.Getenv("LEDGER_CONFIG_PATH")
+	if ledgerPath == "" {
+		ledgerPath = "/app/ledger.dev.json"
+	}
+	ledgerConfig, err := ledger.LoadConfig(ledgerPath)
+	if err != nil {
+		fmt.Printf("Error loading ledger: %s\n", err)
+		os.Exit(1)
+	}
+	// Transfers are booked at the fee their rail is chosen on.
+	ledgerConfig.Fees = rules.Fees()
+	ledgerStore, err := openLedgerStore(os.Getenv("LEDGER_STORE_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening ledger store: %s\n", err)
+		os.Exit(1)
+	}
+	book, err := ledger.NewBook(context.Background(), ledgerStore, ledgerConfig)
+	if err != nil {
+		fmt.Printf("Error loading ledger: %s\n", err)
+		os.Exit(1)
+	}
+	httpServer.LedgerService = book
+	recordConfig(auditLog, "ledger", ledgerPath)
+	transactionStore = &ledger.TransactionStore{TransactionStore: transactionStore, Book: book}
+	transactionStore = &audit.TransactionStore{TransactionStore: transactionStore, Log: auditLog}
+	httpServer.TransactionStore = transactionStore
+	payoutService := payout.NewService(disbursementService, transactionStore)
+	payoutService.Validator = validator
+
+	calendarPath := os.Getenv("CALENDAR_CONFIG_PATH")
+	if calendarPath == "" {
+		calendarPath = "/app/calendar.dev.json"
+	}
+	settlementCalendar, err := calendar.Load(calendarPath)
+	if err != nil {
+		fmt.Printf("Error loading banking calendar: %s\n", err)
+		os.Exit(1)
+	}
+	payoutService.Calendar = settlementCalendar
+	recordConfig(auditLog, "calendar", calendarPath)
+
+	limitsPath := os.Getenv("LIMITS_CONFIG_PATH")
+	if limitsPath == "" {
+		limitsPath = "/app/limits.dev.json"
+	}
+	transferLimits, err := limits.LoadLimits(limitsPath)
+	if err != nil {
+		fmt.Printf("Error loading limits: %s\n", err)
+		os.Exit(1)
+	}
+	limitEngine, err := limits.NewEngine(transferLimits)
+	if err != nil {
+		fmt.Printf("Error loading limits: %s\n", err)
+		os.Exit(1)
+	}
+	if err := limitEngine.Restore(context.Background(), transactionStore); err != nil {
+		fmt.Printf("Error restoring limits: %s\n", err)
+		os.Exit(1)
+	}
+	payoutService.Limits = limitEngine
+	recordConfig(auditLog, "limits", limitsPath)
+
+	webhookStore, err := openWebhookStore(os.Getenv("WEBHOOK_STORE_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening webhook store: %s\n", err)
+		os.Exit(1)
+	}
+	dispatcher := webhook.NewDispatcher(webhookStore)
+	payoutService.Events = dispatcher
+	httpServer.WebhookService = dispatcher
+	httpServer.DisbursementService = payoutService
+
+	sendersPath := os.Getenv("SENDERS_CONFIG_PATH")
+	if sendersPath == "" {
+		sendersPath = "/app/senders.dev.json"
+	}
+	senderService, err := inmem.LoadSenderService(sendersPath)
+	if err != nil {
+		fmt.Printf("Error loading sender profiles: %s\n", err)
+		os.Exit(1)
+	}
+	httpServer.SenderService = senderService
+	recordConfig(auditLog, "senders", sendersPath)
+
+	approvalsPath := os.Getenv("APPROVALS_CONFIG_PATH")
+	if approvalsPath == "" {
+		approvalsPath = "/app/approvals.dev.json"
+	}
+	approvalConfig, err := approval.LoadConfig(approvalsPath)
+	if err != nil {
+		fmt.Printf("Error loading approval rules: %s\n", err)
+		os.Exit(1)
+	}
+	approvalStore, err := openApprovalStore(os.Getenv("APPROVAL_STORE_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening approval store: %s\n", err)
+		os.Exit(1)
+	}
+	approvalStore = &audit.ApprovalStore{ApprovalStore: approvalStore, Log: auditLog}
+	approvalService, err := approval.NewService(payoutService, approvalStore, approvalConfig)
+	if err != nil {
+		fmt.Printf("Error loading approval rules: %s\n", err)
+		os.Exit(1)
+	}
+	approvalService.Senders = senderService
+	payoutService.Approvals = approvalService
+	httpServer.ApprovalService = approvalService
+	recordConfig(auditLog, "approvals", approvalsPath)
+	go approvalService.Run(context.Background())
+	go dispatcher.Run(context.Background())
+
+	// Follow unsettled transfers in the background.
+	go payout.NewReconciler(payoutService).Run(context.Background())
+
+	batchStore, err := openBatchStore(os.Getenv("BATCH_STORE_PATH"))
+	if err != nil {
+		fmt.Printf("Error opening batch store: %s\n", err)
+		os.Exit(1)
+	}
+	// Ids issued before a restart must not be issued again.
+	if err := ub.ResumeRefIDs(context.Background(), transactionStore, batchStore); err != nil {
+		fmt.Printf("Error resuming reference ids: %s\n", err)
+		os.Exit(1)
+	}
+	batchRunner := payout.NewBatchRunner(payoutService, batchStore)
+	batchRunner.Senders = senderService
+	httpServer.BatchService = batchRunner
+	go batchRunner.Run(context.Background())
+
+	railSelector := routing.NewSelector(rules, bankDirectory)
+	railSelector.Calendar = settlementCalendar
+	httpServer.RailSelector = railSelector
+
+	// API clients hold credentials, so they come from a mounted secret
+	// rather than a file baked into the image.
+	clientsPath := os.Getenv("CLIENTS_CONFIG_PATH")
+	if clientsPath == "" {
+		fmt.Println("Error loading API clients: CLIENTS_CONFIG_PATH is not set")
+		os.Exit(1)
+	}
+	clientService, err := inmem.LoadClientService(clientsPath)
+	if err != nil {
+		fmt.Printf("Error loading API clients: %s\n", err)
+		os.Exit(1)
+	}
+	httpServer.Authenticators = []http.Authenticator{
+		&http.APIKeyAuthenticator{Clients: clientService},
+		http.NewSignatureAuthenticator(clientService),
+	}
+	httpServer.AuditLog = auditLog
+	recordConfig(auditLog, "clients", clientsPath)
+
 	// Open HTTP server.
-	err := httpServer.Open()
+	err = httpServer.Open()
 	if err != nil {
 		fmt.Printf("Error opening server: %s\n", err)
 	}
 	u := httpServer.URL()
 	fmt.Printf("Server listening: %s\n", u.String())
 }
+
+// openAuditLog returns a durable log at path, or an in-memory log if no path
+// is configured.
+func openAuditLog(path string) (disbursement.AuditLog, error) {
+	if path == "" {
+		return inmem.NewAuditLog(), nil
+	}
+	return filestore.OpenAuditLog(path)
+}
+
+// recordConfig records the configuration file at path in the audit log.
+func recordConfig(l disbursement.AuditLog, name, path string) {
+	if err := audit.RecordConfig(context.Background(), l, name, path); err != nil {
+		fmt.Printf("Error auditing %s configuration: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// loadPurposes reads the purpose code table of the partner agreement from the
+// JSON file at path.
+func loadPurposes(path string) ([]disbursement.Purpose, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var purposes []disbursement.Purpose
+	if err := json.Unmarshal(b, &purposes); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return purposes, nil
+}
+
+// openIdempotencyStore returns a durable store at path, or an in-memory store
+// if no path is configured.
+func openIdempotencyStore(path string) (disbursement.IdempotencyStore, error) {
+	if path == "" {
+		return inmem.NewIdempotencyStore(), nil
+	}
+	return filestore.OpenIdempotencyStore(path)
+}
+
+// openTransactionStore returns a durable store at path, or an in-memory store
+// if no path is configured.
+func openTransactionStore(path string) (disbursement.TransactionStore, error) {
+	if path == "" {
+		return inmem.NewTransactionStore(), nil
+	}
+	return filestore.OpenTransactionStore(path)
+}
+
+// openWebhookStore returns a durable store at path, or an in-memory store if
+// no path is configured.
+func openWebhookStore(path string) (disbursement.WebhookStore, error) {
+	if path == "" {
+		return inmem.NewWebhookStore(), nil
+	}
+	return filestore.OpenWebhookStore(path)
+}
+
+// openBatchStore returns a durable store at path, or an in-memory store if no
+// path is configured.
+func openBatchStore(path string) (disbursement.BatchStore, error) {
+	if path == "" {
+		return inmem.NewBatchStore(), nil
+	}
+	return filestore.OpenBatchStore(path)
+}
+
+// openApprovalStore returns a durable store at path, or an in-memory store if
+// no path is configured.
+func openApprovalStore(path string) (disbursement.ApprovalStore, error) {
+	if path == "" {
+		return inmem.NewApprovalStore(), nil
+	}
+	return filestore.OpenApprovalStore(path)
+}
+
+// openLedgerStore returns a durable store at path, or an in-memory store if
+// no path is configured.
+func openLedgerStore(path string) (disbursement.LedgerStore, error) {
+	if path == "" {
+		return inmem.NewLedgerStore(), nil
+	}
+	return filestore.OpenLedgerStore(path)
+}
@@ -0,0 +1,45 @@
+package disbursement
+
+import "context"
+
+type contextKey int
+
+const (
+	clientIDContextKey = contextKey(iota + 1)
+	clientContextKey
+)
+
+// NewContextWithClientID returns a copy of ctx carrying the id of the API
+// client making the request.
+func NewContextWithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDContextKey, clientID)
+}
+
+// ClientIDFromContext returns the id of the API client making the request, or
+// an empty string if the request is anonymous.
+func ClientIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDContextKey).(string)
+	return id
+}
+
+// NewContextWithClient returns a copy of ctx carrying the authenticated API
+// client making the request, and its id.
+func NewContextWithClient(ctx context.Context, c *Client) context.Context {
+	ctx = context.WithValue(ctx, clientContextKey, c)
+	return NewContextWithClientID(ctx, c.ID)
+}
+
+// ClientFromContext returns the authenticated API client making the request,
+// or nil if the request was not authenticated.
+func ClientFromContext(ctx context.Context) *Client {
+	c, _ := ctx.Value(clientContextKey).(*Client)
+	return c
+}
+
+// CallerOwns reports whether the client making the request may see and
+// manage a resource created by clientID. Anonymous callers may access every
+// resource.
+func CallerOwns(ctx context.Context, clientID string) bool {
+	caller := ClientIDFromContext(ctx)
+	return caller == "" || caller == clientID
+}
@@ -0,0 +1,289 @@
+// Package csv reads bulk disbursements from spreadsheet exports and writes
+// the results of a batch back out in the same format.
+//
+// An upload starts with a header row naming its columns, in any order.
+// Header names ignore case, surrounding spaces, and spaces or hyphens in
+// place of underscores, so "Account Number" matches account_number.
+//
+//	method          instapay, pesonet or ubp; optional if the upload names a
+//	                rail for every row
+//	reference_id    the caller's own reference for the transfer
+//	sender_id       the sender profile to disburse as
+//	account_number  receiver.accountNumber (required)
+//	name            receiver.name (required)
+//	address_line1   receiver.address.line1
+//	address_line2   receiver.address.line2
+//	city            receiver.address.city
+//	province        receiver.address.province
+//	zip_code        receiver.address.zipCode
+//	country         receiver.address.country
+//	amount          transfer_details.amount, e.g. 1500.00 (required)
+//	currency        transfer_details.currency; defaults to PHP
+//	receiving_bank  transfer_details.receivingBank
+//	purpose         transfer_details.purpose
+//	instructions    transfer_details.instructions
+//
+// Files saved by spreadsheet programs are accepted as they are: a leading
+// byte order mark is skipped, blank lines are ignored and amounts may use
+// thousands separators.
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// MaxRows bounds the number of disbursements read from one upload.
+const MaxRows = 1000
+
+// column maps a column of an upload onto a field of a disbursement.
+type column struct {
+	name  string
+	field string // JSON path of the field, as reported by validation
+	set   func(r *row, v string)
+}
+
+// row holds the raw values of one line until they are parsed.
+type row struct {
+	method   string
+	amount   string
+	currency string
+	d        disbursement.Disbursement
+}
+
+var columns = []column{
+	{"method", "method", func(r *row, v string) { r.method = v }},
+	{"reference_id", "reference_id", func(r *row, v string) { r.d.ReferenceID = v }},
+	{"sender_id", "sender_id", func(r *row, v string) { r.d.SenderID = v }},
+	{"account_number", "receiver.accountNumber", func(r *row, v string) { r.d.Receiver.AccountNumber = v }},
+	{"name", "receiver.name", func(r *row, v string) { r.d.Receiver.Name = v }},
+	{"address_line1", "receiver.address.line1", func(r *row, v string) { r.d.Receiver.Address.Line1 = v }},
+	{"address_line2", "receiver.address.line2", func(r *row, v string) { r.d.Receiver.Address.Line2 = v }},
+	{"city", "receiver.address.city", func(r *row, v string) { r.d.Receiver.Address.City = v }},
+	{"province", "receiver.address.province", func(r *row, v string) { r.d.Receiver.Address.Province = v }},
+	{"zip_code", "receiver.address.zipCode", func(r *row, v string) { r.d.Receiver.Address.ZipCode = v }},
+	{"country", "receiver.address.country", func(r *row, v string) { r.d.Receiver.Address.Country = v }},
+	{"amount", "transfer_details.amount", func(r *row, v string) { r.amount = v }},
+	{"currency", "transfer_details.currency", func(r *row, v string) { r.currency = v }},
+	{"receiving_bank", "transfer_details.receivingBank", func(r *row, v string) { r.d.Details.ReceivingBank = v }},
+	{"purpose", "transfer_details.purpose", func(r *row, v string) { r.d.Details.Purpose = v }},
+	{"instructions", "transfer_details.instructions", func(r *row, v string) { r.d.Details.Instructions = v }},
+}
+
+// requiredColumns must appear in every upload.
+var requiredColumns = []string{"account_number", "name", "amount"}
+
+// Column returns the name of the column holding the disbursement field at the
+// given JSON path, or field itself if no column holds it.
+func Column(field string) string {
+	for _, c := range columns {
+		if c.field == field {
+			return c.name
+		}
+	}
+	return field
+}
+
+// ReadBatch reads the disbursements of an upload into the items of a new
+// batch. Rows without a method column, or with an empty one, are sent
+// through method.
+//
+// Only the layout of each row is checked here; the disbursements still need
+// to be validated. Problems with rows are reported together as a
+// *disbursement.ValidationError whose errors carry the line and column of
+// each problem.
+func ReadBatch(r io.Reader, method disbursement.Method) (*disbursement.Batch, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", disbursement.ErrInvalid)
+	} else if err != nil {
+		return nil, readError(err)
+	}
+	line, _ := cr.FieldPos(0)
+	index, err := parseHeader(header, line)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &disbursement.Batch{}
+	verr := &disbursement.ValidationError{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, readError(err)
+		}
+		line, _ := cr.FieldPos(0)
+		if blank(record) {
+			continue
+		}
+		if len(b.Items) == MaxRows {
+			return nil, fmt.Errorf("%w: an upload holds at most %d rows", disbursement.ErrInvalid, MaxRows)
+		}
+
+		item, errs := parseRow(record, index, method)
+		for _, fe := range errs {
+			fe.Line = line
+			verr.Errors = append(verr.Errors, fe)
+		}
+		item.Index, item.Line = len(b.Items), line
+		b.Items = append(b.Items, item)
+	}
+
+	if len(b.Items) == 0 {
+		return nil, fmt.Errorf("%w: the file has no rows", disbursement.ErrInvalid)
+	} else if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return b, nil
+}
+
+// parseHeader returns the index of each known column in header. Unknown
+// columns are rejected so a misspelt one is not silently dropped.
+func parseHeader(header []string, line int) (map[string]int, error) {
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	verr := &disbursement.ValidationError{}
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		name := normalize(h)
+		if !known(name) {
+			verr.Errors = append(verr.Errors, disbursement.FieldError{Field: h, Message: "unknown column", Line: line})
+		} else if _, ok := index[name]; ok {
+			verr.Errors = append(verr.Errors, disbursement.FieldError{Field: h, Message: "duplicate column", Line: line})
+		}
+		index[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			verr.Errors = append(verr.Errors, disbursement.FieldError{Field: name, Message: "missing column", Line: line})
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return index, nil
+}
+
+// parseRow reads one record, returning the problems found in it.
+func parseRow(record []string, index map[string]int, method disbursement.Method) (*disbursement.BatchItem, []disbursement.FieldError) {
+	r := row{method: string(method), currency: disbursement.DefaultCurrency}
+	for _, c := range columns {
+		if i, ok := index[c.name]; ok && i < len(record) {
+			if v := strings.TrimSpace(record[i]); v != "" {
+				c.set(&r, v)
+			}
+		}
+	}
+
+	var errs []disbursement.FieldError
+	m, err := disbursement.ParseMethod(strings.ToLower(r.method))
+	if err != nil {
+		msg := fmt.Sprintf("unsupported disbursement method %q", r.method)
+		if r.method == "" {
+			msg = "is required"
+		}
+		errs = append(errs, disbursement.FieldError{Field: "method", Message: msg})
+	}
+
+	// Spreadsheets format amounts with thousands separators.
+	amount, err := disbursement.ParseMoney(strings.ReplaceAll(r.amount, ",", ""), strings.ToUpper(r.currency))
+	var verr *disbursement.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			if r.amount == "" && fe.Field == "amount" {
+				fe.Message = "is required"
+			}
+			errs = append(errs, fe)
+		}
+	}
+	r.d.Details.Amount = amount
+
+	return &disbursement.BatchItem{Method: m, Disbursement: &r.d}, errs
+}
+
+// WriteResults writes one row per item of b describing its outcome. txs holds
+// the transactions of submitted items by id; an item whose transaction is
+// missing is reported with the state it was submitted in.
+func WriteResults(w io.Writer, b *disbursement.Batch, txs map[string]*disbursement.Transaction) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"line", "reference_id", "method", "account_number", "name", "amount", "currency",
+		"item_state", "transaction_id", "provider_transaction_id", "state", "provider_state", "error",
+	})
+
+	for _, item := range b.Items {
+		d := item.Disbursement
+		line := ""
+		if item.Line > 0 {
+			line = fmt.Sprint(item.Line)
+		}
+
+		var providerTxID, state, providerState string
+		errMsg := item.Error
+		if tx, ok := txs[item.TransactionID]; ok {
+			providerTxID, state, providerState = tx.ProviderTransactionID, string(tx.State), tx.ProviderState
+			if tx.Error != "" {
+				errMsg = tx.Error
+			}
+		} else if res := item.Result; res != nil {
+			providerTxID, state, providerState = res.ProviderTransactionID, string(res.State), res.ProviderState
+		}
+
+		cw.Write([]string{
+			line, d.ReferenceID, string(item.Method), d.Receiver.AccountNumber, d.Receiver.Name,
+			d.Details.Amount.String(), d.Details.Amount.Currency,
+			string(item.State), item.TransactionID, providerTxID, state, providerState, errMsg,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+func known(name string) bool {
+	for _, c := range columns {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readError describes a malformed file, which cannot be read past.
+func readError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return &disbursement.ValidationError{Errors: []disbursement.FieldError{
+			{Field: "file", Message: perr.Err.Error(), Line: perr.Line},
+		}}
+	}
+	return fmt.Errorf("%w: cannot read file: %s", disbursement.ErrInvalid, err)
+}
@@ -0,0 +1,84 @@
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testUpload = "\ufeffReference ID,Account Number,Name,Address Line1,City,Province,Country,Amount,Receiving Bank,method\r\n" +
+	"PAY-1,109453095653,Rachelle,241 A.Del Mundo St,Caloocan,Metro Manila,PH,\"1,500.00\",161408,\r\n" +
+	",,,,,,,,,\r\n" +
+	"PAY-2,109453095654,Jose,1 Rizal St,Manila,Metro Manila,PH,30,,UBP\r\n"
+
+func TestReadBatch(t *testing.T) {
+	Convey("spreadsheet exports are read into batch items", t, func() {
+		b, err := ReadBatch(strings.NewReader(testUpload), disbursement.MethodInstapay)
+		So(err, ShouldBeNil)
+		So(b.Items, ShouldHaveLength, 2)
+
+		first := b.Items[0]
+		So(first.Method, ShouldEqual, disbursement.MethodInstapay)
+		So(first.Line, ShouldEqual, 2)
+		So(first.Disbursement.ReferenceID, ShouldEqual, "PAY-1")
+		So(first.Disbursement.Details.Amount.String(), ShouldEqual, "1500.00")
+		So(first.Disbursement.Details.ReceivingBank, ShouldEqual, "161408")
+
+		second := b.Items[1]
+		So(second.Index, ShouldEqual, 1)
+		So(second.Line, ShouldEqual, 4)
+		So(second.Method, ShouldEqual, disbursement.MethodUBP)
+	})
+
+	Convey("every bad row is reported with its line and column", t, func() {
+		upload := "account_number,name,amount,method\n" +
+			"1,A,10.00,instapay\n" +
+			"2,B,ten,instapay\n" +
+			"3,C,5.00,gcash\n"
+		_, err := ReadBatch(strings.NewReader(upload), "")
+
+		var verr *disbursement.ValidationError
+		So(errors.As(err, &verr), ShouldBeTrue)
+		So(verr.Errors, ShouldHaveLength, 2)
+		So(verr.Errors[0].Line, ShouldEqual, 3)
+		So(verr.Errors[0].Field, ShouldEqual, "amount")
+		So(verr.Errors[1].Line, ShouldEqual, 4)
+		So(verr.Errors[1].Field, ShouldEqual, "method")
+	})
+
+	Convey("unknown and missing columns are rejected", t, func() {
+		_, err := ReadBatch(strings.NewReader("acount_number,name\n1,A\n"), disbursement.MethodUBP)
+
+		var verr *disbursement.ValidationError
+		So(errors.As(err, &verr), ShouldBeTrue)
+		var fields []string
+		for _, fe := range verr.Errors {
+			fields = append(fields, fe.Field)
+		}
+		So(fields, ShouldResemble, []string{"acount_number", "account_number", "amount"})
+	})
+}
+
+func TestWriteResults(t *testing.T) {
+	Convey("results list the provider transaction and state of each item", t, func() {
+		b, err := ReadBatch(strings.NewReader(testUpload), disbursement.MethodInstapay)
+		So(err, ShouldBeNil)
+		b.Items[0].State, b.Items[0].TransactionID = disbursement.BatchItemSubmitted, "txn_1"
+		b.Items[1].State, b.Items[1].Error = disbursement.BatchItemFailed, "insufficient funds"
+
+		txs := map[string]*disbursement.Transaction{
+			"txn_1": {ID: "txn_1", ProviderTransactionID: "UB123", State: disbursement.TransferCredited, ProviderState: "Credited Beneficiary Account"},
+		}
+		var buf bytes.Buffer
+		So(WriteResults(&buf, b, txs), ShouldBeNil)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		So(lines, ShouldHaveLength, 3)
+		So(lines[1], ShouldEqual, "2,PAY-1,instapay,109453095653,Rachelle,1500.00,PHP,submitted,txn_1,UB123,credited,Credited Beneficiary Account,")
+		So(lines[2], ShouldEndWith, "failed,,,,,insufficient funds")
+	})
+}
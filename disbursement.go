@@ -1,9 +1,35 @@
 package disbursement
 
+import (
+	"context"
+	"encoding/json"
+)
+
 type Disbursement struct {
 	Receiver Receiver `json:"receiver"`
 	Details  Details  `json:"transfer_details"`
-	// IdemKey  string   `json:"idem_key"`
+	IdemKey  string   `json:"idem_key,omitempty"`
+
+	// ReferenceID is the caller's own reference for the transfer. It is
+	// embedded in the sender reference id sent to the provider.
+	ReferenceID string `json:"reference_id,omitempty"`
+
+	// SenderID names the sender profile to disburse as. If empty the
+	// default sender is used.
+	SenderID string `json:"sender_id,omitempty"`
+
+	// Sender is the resolved sender profile. It is set by ResolveSender
+	// and read by the provider.
+	Sender *Sender `json:"-"`
+
+	// SenderRefID is the provider reference id to send. If empty the
+	// provider issues one.
+	SenderRefID string `json:"-"`
+
+	// Route is the rail chosen for the transfer by a RailSelector, if
+	// any. A transfer that cannot reach its rail may be retried on the
+	// route's fallback.
+	Route *Route `json:"-"`
 }
 
 type Receiver struct {
@@ -12,14 +38,46 @@ type Receiver struct {
 	Address       Address `json:"address"`
 }
 
+// Details of a transfer. Amount is encoded as separate "amount" and
+// "currency" fields.
 type Details struct {
-	Amount        string `json:"amount"`
-	Currency      string `json:"currency"`
+	Amount        Money  `json:"-"`
 	ReceivingBank string `json:"receivingBank"`
 	Purpose       string `json:"purpose"`
 	Instructions  string `json:"instructions"`
 }
 
+func (d Details) MarshalJSON() ([]byte, error) {
+	type details Details
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+		details
+	}{d.Amount.String(), d.Amount.Currency, details(d)})
+}
+
+// UnmarshalJSON decodes d, rejecting malformed amounts and unsupported
+// currencies with a *ValidationError.
+func (d *Details) UnmarshalJSON(b []byte) error {
+	type details Details
+	aux := struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+		*details
+	}{details: (*details)(d)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	amount, fe := unmarshalMoney(aux.Amount, aux.Currency)
+	if fe != nil {
+		fe.Field = "transfer_details." + fe.Field
+		return &ValidationError{Errors: []FieldError{*fe}}
+	}
+	d.Amount = amount
+	return nil
+}
+
 type Address struct {
 	Line1    string `json:"line1"`
 	Line2    string `json:"line2"`
@@ -29,8 +87,10 @@ type Address struct {
 	Country  string `json:"country"`
 }
 
+// DisbursementService sends transfers through a provider. Implementations
+// map the provider's responses into provider-neutral results.
 type DisbursementService interface {
-	TransferFunds(method string, d *Disbursement) (interface{}, error)
-	GetBanks(method string) (interface{}, error)
-	GetStatus(method string, referenceID string) (interface{}, error)
+	TransferFunds(ctx context.Context, method Method, d *Disbursement) (*TransferResult, error)
+	GetBanks(ctx context.Context, method Method) ([]Bank, error)
+	GetStatus(ctx context.Context, method Method, referenceID string) (*TransferStatus, error)
 }
@@ -0,0 +1,53 @@
+package disbursement
+
+import "errors"
+
+// Error categories returned by a DisbursementService. Provider errors match
+// them with errors.Is so callers can react without knowing the provider.
+var (
+	// ErrRetryable means the provider is unavailable or throttling; the same
+	// request may succeed later.
+	ErrRetryable = errors.New("provider temporarily unavailable")
+
+	// ErrInvalid means the provider rejected the request as malformed or
+	// referring to an unknown account, bank or transaction.
+	ErrInvalid = errors.New("invalid request")
+
+	// ErrUnauthorized means the provider rejected our credentials.
+	ErrUnauthorized = errors.New("provider authorization failed")
+
+	// ErrFunding means the source account cannot cover the transfer.
+	ErrFunding = errors.New("insufficient funds")
+
+	// ErrNotFound means the requested transfer or record does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict means a record was changed by someone else since it was
+	// read.
+	ErrConflict = errors.New("conflict")
+
+	// ErrUnauthenticated means the caller did not prove which API client it
+	// is.
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrForbidden means the caller is not allowed to make the request.
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrLimitExceeded means the transfer would exceed a velocity or
+	// exposure limit. It may be accepted once the limit's window has moved
+	// on.
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// ProviderError is implemented by errors that carry details from a
+// provider's API response.
+type ProviderError interface {
+	error
+
+	// ErrorCode returns the provider's own error code.
+	ErrorCode() string
+
+	// ErrorCorrelationID returns the id the provider assigned to the failed
+	// call, for tracing it with the provider's support.
+	ErrorCorrelationID() string
+}
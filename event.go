@@ -0,0 +1,52 @@
+package disbursement
+
+import (
+	"context"
+	"time"
+)
+
+// EventType names a change to a transaction that clients can be told about.
+type EventType string
+
+const (
+	EventTransferCreated  EventType = "transfer.created"
+	EventTransferCredited EventType = "transfer.credited"
+	EventTransferFailed   EventType = "transfer.failed"
+	EventTransferReturned EventType = "transfer.returned"
+
+	// EventTransferEscalated means a transfer stayed unsettled for too long
+	// and needs an operator to look at it.
+	EventTransferEscalated EventType = "transfer.escalated"
+)
+
+// StateEvent returns the event announcing that a transfer reached state, if
+// the state is final.
+func StateEvent(state TransferState) (EventType, bool) {
+	switch state {
+	case TransferCredited:
+		return EventTransferCredited, true
+	case TransferFailed:
+		return EventTransferFailed, true
+	case TransferReturned:
+		return EventTransferReturned, true
+	}
+	return "", false
+}
+
+// NewEventID returns a random event id.
+func NewEventID() string {
+	return newID("evt_")
+}
+
+// Event is a change to a transaction.
+type Event struct {
+	ID          string       `json:"id"`
+	Type        EventType    `json:"type"`
+	Transaction *Transaction `json:"transaction"`
+	OccurredAt  time.Time    `json:"occurred_at"`
+}
+
+// EventPublisher delivers events to whoever is interested in them.
+type EventPublisher interface {
+	PublishEvent(ctx context.Context, e *Event) error
+}
@@ -0,0 +1,119 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// ApprovalStore is a disbursement.ApprovalStore persisted to a journal file.
+// Every create and update appends the full approval, so the file also keeps
+// each approval's earlier states.
+type ApprovalStore struct {
+	journal *journal
+
+	mu        sync.Mutex
+	approvals map[string]*disbursement.Approval
+	order     []string
+
+	now func() time.Time
+}
+
+// OpenApprovalStore loads the store at path, creating it if needed.
+func OpenApprovalStore(path string) (*ApprovalStore, error) {
+	s := &ApprovalStore{
+		approvals: make(map[string]*disbursement.Approval),
+		now:       time.Now,
+	}
+	j, err := openJournal(path, func(line []byte) error {
+		var a disbursement.Approval
+		if err := json.Unmarshal(line, &a); err != nil {
+			return err
+		}
+		if _, ok := s.approvals[a.ID]; !ok {
+			s.order = append(s.order, a.ID)
+		}
+		s.approvals[a.ID] = &a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = j
+	return s, nil
+}
+
+// Close closes the journal file.
+func (s *ApprovalStore) Close() error {
+	return s.journal.close()
+}
+
+func (s *ApprovalStore) CreateApproval(ctx context.Context, a *disbursement.Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID == "" {
+		a.ID = disbursement.NewApprovalID()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = s.now()
+	}
+	if a.UpdatedAt.IsZero() {
+		a.UpdatedAt = a.CreatedAt
+	}
+	if err := s.journal.append(a); err != nil {
+		return err
+	}
+	s.approvals[a.ID] = copyApproval(a)
+	s.order = append(s.order, a.ID)
+	return nil
+}
+
+func (s *ApprovalStore) UpdateApproval(ctx context.Context, a *disbursement.Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.approvals[a.ID]; !ok {
+		return disbursement.ErrNotFound
+	}
+	if err := s.journal.append(a); err != nil {
+		return err
+	}
+	s.approvals[a.ID] = copyApproval(a)
+	return nil
+}
+
+func (s *ApprovalStore) FindApprovalByID(ctx context.Context, id string) (*disbursement.Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.approvals[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return copyApproval(a), nil
+}
+
+func (s *ApprovalStore) FindApprovals(ctx context.Context, filter disbursement.ApprovalFilter) ([]*disbursement.Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var approvals []*disbursement.Approval
+	for _, id := range s.order {
+		if a := s.approvals[id]; filter.Match(a) {
+			approvals = append(approvals, copyApproval(a))
+		}
+	}
+	return approvals, nil
+}
+
+// copyApproval returns a copy of a that shares no history with it. The
+// disbursement is never modified once held, so it is shared.
+func copyApproval(a *disbursement.Approval) *disbursement.Approval {
+	cp := *a
+	cp.History = append([]disbursement.ApprovalEvent(nil), a.History...)
+	return &cp
+}
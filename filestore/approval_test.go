@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApprovalStore(t *testing.T) {
+	Convey("approvals survive reopening the store with their history", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "approvals.jsonl")
+
+		s, err := OpenApprovalStore(path)
+		So(err, ShouldBeNil)
+		a := &disbursement.Approval{
+			ClientID: "acme",
+			Method:   disbursement.MethodPesonet,
+			Disbursement: &disbursement.Disbursement{
+				ReferenceID: "INV-1",
+				Details:     disbursement.Details{Amount: disbursement.Money{Minor: 50000000, Currency: "PHP"}},
+			},
+			State:             disbursement.ApprovalPending,
+			RequiredApprovals: 1,
+		}
+		a.Record(disbursement.ApprovalEvent{Action: disbursement.ApprovalRequested, ActorID: "acme", At: s.now()})
+		So(s.CreateApproval(ctx, a), ShouldBeNil)
+		a.State = disbursement.ApprovalRejected
+		a.Record(disbursement.ApprovalEvent{Action: disbursement.ApprovalDenied, ActorID: "treasury", Reason: "duplicate", At: s.now()})
+		So(s.UpdateApproval(ctx, a), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		s, err = OpenApprovalStore(path)
+		So(err, ShouldBeNil)
+		defer s.Close()
+		got, err := s.FindApprovalByID(ctx, a.ID)
+		So(err, ShouldBeNil)
+		So(got.State, ShouldEqual, disbursement.ApprovalRejected)
+		So(got.History, ShouldHaveLength, 2)
+		So(got.History[1].Reason, ShouldEqual, "duplicate")
+		So(got.Disbursement.ReferenceID, ShouldEqual, "INV-1")
+
+		found, err := s.FindApprovals(ctx, disbursement.ApprovalFilter{State: disbursement.ApprovalPending})
+		So(err, ShouldBeNil)
+		So(found, ShouldBeEmpty)
+	})
+}
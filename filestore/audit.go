@@ -0,0 +1,65 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// AuditLog is a disbursement.AuditLog kept in a journal file. Only the last
+// entry is held in memory, to chain the next one to.
+type AuditLog struct {
+	journal *journal
+
+	mu   sync.Mutex
+	last *disbursement.AuditEntry
+
+	now func() time.Time
+}
+
+// OpenAuditLog opens the log at path, creating it if needed. It refuses to
+// open a log whose chain is broken, so entries are never appended to a log
+// that was tampered with.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{now: time.Now}
+	j, err := openJournal(path, func(line []byte) error {
+		var e disbursement.AuditEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		if err := disbursement.VerifyAuditEntry(l.last, &e); err != nil {
+			return err
+		}
+		l.last = &e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.journal = j
+	return l, nil
+}
+
+// Close closes the journal file.
+func (l *AuditLog) Close() error {
+	return l.journal.close()
+}
+
+func (l *AuditLog) Record(ctx context.Context, e *disbursement.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.At.IsZero() {
+		e.At = l.now()
+	}
+	e.Chain(l.last)
+	if err := l.journal.append(e); err != nil {
+		return err
+	}
+	cp := *e
+	l.last = &cp
+	return nil
+}
@@ -0,0 +1,42 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuditLog(t *testing.T) {
+	Convey("the chain continues across reopening the log", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+		l, err := OpenAuditLog(path)
+		So(err, ShouldBeNil)
+		So(l.Record(ctx, &disbursement.AuditEntry{Kind: disbursement.AuditAPICall, ActorID: "acme", Action: "POST"}), ShouldBeNil)
+		So(l.Close(), ShouldBeNil)
+
+		l, err = OpenAuditLog(path)
+		So(err, ShouldBeNil)
+		e := &disbursement.AuditEntry{Kind: disbursement.AuditAPICall, ActorID: "acme", Action: "GET"}
+		So(l.Record(ctx, e), ShouldBeNil)
+		So(l.Close(), ShouldBeNil)
+		So(e.Seq, ShouldEqual, 2)
+		So(e.PrevHash, ShouldNotBeEmpty)
+
+		Convey("and a log that was tampered with is not reopened", func() {
+			b, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(ioutil.WriteFile(path, bytes.Replace(b, []byte(`"acme"`), []byte(`"evil"`), 1), 0600), ShouldBeNil)
+
+			_, err = OpenAuditLog(path)
+			So(errors.Is(err, disbursement.ErrAuditTampered), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// BatchStore is a disbursement.BatchStore persisted to a journal file. A
+// batch is written in full when created; later changes append only the
+// batch's state or the one item that changed, so large batches stay cheap to
+// update.
+type BatchStore struct {
+	journal *journal
+
+	mu      sync.Mutex
+	batches map[string]*disbursement.Batch
+	order   []string
+
+	now func() time.Time
+}
+
+// batchRecord is one journal entry. Exactly one field is set.
+type batchRecord struct {
+	Batch *disbursement.Batch `json:"batch,omitempty"`
+	State *batchStateRecord   `json:"state,omitempty"`
+	Item  *batchItemRecord    `json:"item,omitempty"`
+}
+
+type batchStateRecord struct {
+	BatchID     string                  `json:"batch_id"`
+	State       disbursement.BatchState `json:"state"`
+	UpdatedAt   time.Time               `json:"updated_at"`
+	CompletedAt *time.Time              `json:"completed_at,omitempty"`
+}
+
+type batchItemRecord struct {
+	BatchID string                  `json:"batch_id"`
+	Item    *disbursement.BatchItem `json:"item"`
+}
+
+// OpenBatchStore loads the store at path, creating it if needed.
+func OpenBatchStore(path string) (*BatchStore, error) {
+	s := &BatchStore{
+		batches: make(map[string]*disbursement.Batch),
+		now:     time.Now,
+	}
+	j, err := openJournal(path, func(line []byte) error {
+		var rec batchRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		return s.apply(&rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = j
+	return s, nil
+}
+
+// Close closes the journal file.
+func (s *BatchStore) Close() error {
+	return s.journal.close()
+}
+
+// apply adds rec to the in-memory state.
+func (s *BatchStore) apply(rec *batchRecord) error {
+	switch {
+	case rec.Batch != nil:
+		if _, ok := s.batches[rec.Batch.ID]; !ok {
+			s.order = append(s.order, rec.Batch.ID)
+		}
+		s.batches[rec.Batch.ID] = rec.Batch
+	case rec.State != nil:
+		b, ok := s.batches[rec.State.BatchID]
+		if !ok {
+			return fmt.Errorf("batch %s: %w", rec.State.BatchID, disbursement.ErrNotFound)
+		}
+		b.State = rec.State.State
+		b.UpdatedAt = rec.State.UpdatedAt
+		b.CompletedAt = rec.State.CompletedAt
+	case rec.Item != nil:
+		b, ok := s.batches[rec.Item.BatchID]
+		if !ok || rec.Item.Item.Index < 0 || rec.Item.Item.Index >= len(b.Items) {
+			return fmt.Errorf("batch %s item: %w", rec.Item.BatchID, disbursement.ErrNotFound)
+		}
+		b.Items[rec.Item.Item.Index] = rec.Item.Item
+	}
+	return nil
+}
+
+// write journals rec and applies it.
+func (s *BatchStore) write(rec *batchRecord) error {
+	if err := s.journal.append(rec); err != nil {
+		return err
+	}
+	return s.apply(rec)
+}
+
+func (s *BatchStore) CreateBatch(ctx context.Context, b *disbursement.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.ID == "" {
+		b.ID = disbursement.NewBatchID()
+	}
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = s.now()
+	}
+	if b.UpdatedAt.IsZero() {
+		b.UpdatedAt = b.CreatedAt
+	}
+	return s.write(&batchRecord{Batch: copyBatch(b)})
+}
+
+func (s *BatchStore) UpdateBatch(ctx context.Context, b *disbursement.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.batches[b.ID]; !ok {
+		return disbursement.ErrNotFound
+	}
+	return s.write(&batchRecord{State: &batchStateRecord{
+		BatchID:     b.ID,
+		State:       b.State,
+		UpdatedAt:   b.UpdatedAt,
+		CompletedAt: b.CompletedAt,
+	}})
+}
+
+func (s *BatchStore) UpdateBatchItem(ctx context.Context, batchID string, item *disbursement.BatchItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.batches[batchID]
+	if !ok || item.Index < 0 || item.Index >= len(b.Items) {
+		return disbursement.ErrNotFound
+	}
+	cp := *item
+	return s.write(&batchRecord{Item: &batchItemRecord{BatchID: batchID, Item: &cp}})
+}
+
+func (s *BatchStore) FindBatchByID(ctx context.Context, id string) (*disbursement.Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.batches[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return copyBatch(b), nil
+}
+
+func (s *BatchStore) FindBatches(ctx context.Context, state disbursement.BatchState) ([]*disbursement.Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var batches []*disbursement.Batch
+	for _, id := range s.order {
+		if b := s.batches[id]; state == "" || b.State == state {
+			batches = append(batches, copyBatch(b))
+		}
+	}
+	return batches, nil
+}
+
+// copyBatch returns a copy of b whose items can be changed without affecting
+// b. Requests and results are never modified once recorded, so they are
+// shared.
+func copyBatch(b *disbursement.Batch) *disbursement.Batch {
+	cp := *b
+	cp.Items = make([]*disbursement.BatchItem, len(b.Items))
+	for i, item := range b.Items {
+		itemCp := *item
+		cp.Items[i] = &itemCp
+	}
+	return &cp
+}
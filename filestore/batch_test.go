@@ -0,0 +1,54 @@
+package filestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBatchStore(t *testing.T) {
+	Convey("batches and item progress survive reopening the store", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "batches.jsonl")
+
+		s, err := OpenBatchStore(path)
+		So(err, ShouldBeNil)
+		b := &disbursement.Batch{State: disbursement.BatchRunning}
+		for i := 0; i < 2; i++ {
+			b.Items = append(b.Items, &disbursement.BatchItem{
+				Index:  i,
+				Method: disbursement.MethodInstapay,
+				State:  disbursement.BatchItemQueued,
+				Disbursement: &disbursement.Disbursement{
+					Details: disbursement.Details{Amount: disbursement.Money{Minor: 10000, Currency: "PHP"}},
+				},
+			})
+		}
+		So(s.CreateBatch(ctx, b), ShouldBeNil)
+		So(b.ID, ShouldNotBeEmpty)
+
+		item := *b.Items[1]
+		item.State, item.TransactionID = disbursement.BatchItemSubmitted, "txn_1"
+		So(s.UpdateBatchItem(ctx, b.ID, &item), ShouldBeNil)
+		b.State = disbursement.BatchCompleted
+		So(s.UpdateBatch(ctx, b), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		s, err = OpenBatchStore(path)
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		got, err := s.FindBatchByID(ctx, b.ID)
+		So(err, ShouldBeNil)
+		So(got.State, ShouldEqual, disbursement.BatchCompleted)
+		So(got.Items[0].State, ShouldEqual, disbursement.BatchItemQueued)
+		So(got.Items[1].TransactionID, ShouldEqual, "txn_1")
+		So(got.Totals().SubmittedAmount.String(), ShouldEqual, "100.00")
+
+		running, _ := s.FindBatches(ctx, disbursement.BatchRunning)
+		So(running, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,57 @@
+// Package filestore implements durable stores that keep their state in JSON
+// files on local disk. Small stores rewrite their whole file on every change,
+// writing to a temporary file and renaming it into place, so a crash leaves
+// either the old or the new state behind. Stores that keep every record
+// append to a journal instead.
+package filestore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// readFile decodes the JSON file at path into v. A missing file leaves v
+// untouched.
+func readFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// writeFile atomically replaces the file at path with v encoded as JSON.
+func writeFile(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,160 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// defaultCompactAfter is the number of appends after which the journal of an
+// IdempotencyStore is compacted, if it holds more entries than records.
+const defaultCompactAfter = 1000
+
+// IdempotencyStore is a disbursement.IdempotencyStore persisted to a journal
+// file so replays survive a restart. Every change appends the record's new
+// state; the journal is compacted to the unexpired records from time to
+// time.
+type IdempotencyStore struct {
+	journal *journal
+
+	mu      sync.Mutex
+	records map[string]*disbursement.IdempotencyRecord
+
+	// appended counts the entries since the journal was last compacted.
+	appended     int
+	compactAfter int
+
+	now func() time.Time
+}
+
+// idempotencyEntry is a journal entry: the new state of the record with Key,
+// or its removal if Record is nil.
+type idempotencyEntry struct {
+	Key    string                          `json:"key"`
+	Record *disbursement.IdempotencyRecord `json:"record,omitempty"`
+}
+
+// OpenIdempotencyStore loads the store at path, creating it if needed.
+func OpenIdempotencyStore(path string) (*IdempotencyStore, error) {
+	s := &IdempotencyStore{
+		records:      make(map[string]*disbursement.IdempotencyRecord),
+		compactAfter: defaultCompactAfter,
+		now:          time.Now,
+	}
+	j, err := openJournal(path, func(line []byte) error {
+		var e idempotencyEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		if e.Record == nil {
+			delete(s.records, e.Key)
+		} else {
+			s.records[e.Key] = e.Record
+		}
+		s.appended++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = j
+	return s, nil
+}
+
+// Close closes the journal file.
+func (s *IdempotencyStore) Close() error {
+	return s.journal.close()
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, key, requestHash string) (*disbursement.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if rec, ok := s.records[key]; ok && now.Sub(rec.CreatedAt) < disbursement.IdempotencyTTL {
+		cp := *rec
+		return &cp, nil
+	}
+
+	rec := &disbursement.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+	}
+	if err := s.append(key, rec); err != nil {
+		return nil, err
+	}
+	s.records[key] = rec
+	s.compact(now)
+	return nil, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, resp *disbursement.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+	completed := *rec
+	completed.Response = resp
+	if err := s.append(key, &completed); err != nil {
+		return err
+	}
+	s.records[key] = &completed
+	return nil
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if !ok || rec.Response != nil {
+		return nil
+	}
+	if err := s.append(key, nil); err != nil {
+		return err
+	}
+	delete(s.records, key)
+	return nil
+}
+
+// append journals the new state of the record with key. The caller must hold
+// s.mu.
+func (s *IdempotencyStore) append(key string, rec *disbursement.IdempotencyRecord) error {
+	if err := s.journal.append(idempotencyEntry{Key: key, Record: rec}); err != nil {
+		return err
+	}
+	s.appended++
+	return nil
+}
+
+// compact drops expired records and, once enough entries were appended,
+// rewrites the journal with the remaining ones. A failed rewrite leaves the
+// journal as it was. The caller must hold s.mu.
+func (s *IdempotencyStore) compact(now time.Time) {
+	for key, rec := range s.records {
+		if now.Sub(rec.CreatedAt) >= disbursement.IdempotencyTTL {
+			delete(s.records, key)
+		}
+	}
+	if s.appended < s.compactAfter || s.appended <= len(s.records) {
+		return
+	}
+
+	entries := make([]interface{}, 0, len(s.records))
+	for key, rec := range s.records {
+		entries = append(entries, idempotencyEntry{Key: key, Record: rec})
+	}
+	if err := s.journal.rewrite(entries); err != nil {
+		log.Printf("filestore: cannot compact idempotency journal: %s", err)
+		return
+	}
+	s.appended = len(entries)
+}
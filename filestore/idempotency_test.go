@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	Convey("idempotency records survive reopening the store", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+		resp := &disbursement.IdempotentResponse{StatusCode: 200, Body: []byte(`{}`)}
+
+		s, err := OpenIdempotencyStore(path)
+		So(err, ShouldBeNil)
+		_, err = s.Reserve(ctx, "completed", "hash-1")
+		So(err, ShouldBeNil)
+		So(s.Complete(ctx, "completed", resp), ShouldBeNil)
+		_, err = s.Reserve(ctx, "released", "hash-2")
+		So(err, ShouldBeNil)
+		So(s.Release(ctx, "released"), ShouldBeNil)
+
+		reopen := func() *IdempotencyStore {
+			So(s.Close(), ShouldBeNil)
+			s, err = OpenIdempotencyStore(path)
+			So(err, ShouldBeNil)
+			return s
+		}
+
+		Convey("except released ones", func() {
+			s := reopen()
+			defer s.Close()
+			rec, err := s.Reserve(ctx, "completed", "hash-1")
+			So(err, ShouldBeNil)
+			So(rec.Response, ShouldResemble, resp)
+			rec, err = s.Reserve(ctx, "released", "hash-2")
+			So(err, ShouldBeNil)
+			So(rec, ShouldBeNil)
+		})
+
+		Convey("with the journal compacted to the unexpired records", func() {
+			now := time.Now()
+			s.now = func() time.Time { return now }
+			for i := 0; i < 10; i++ {
+				s.Reserve(ctx, fmt.Sprint("key-", i), "hash")
+				s.Release(ctx, fmt.Sprint("key-", i))
+			}
+			s.compactAfter = 10
+			now = now.Add(disbursement.IdempotencyTTL)
+			_, err := s.Reserve(ctx, "latest", "hash")
+			So(err, ShouldBeNil)
+
+			b, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(bytes.Count(b, []byte("\n")), ShouldEqual, 1)
+
+			s := reopen()
+			defer s.Close()
+			rec, err := s.Reserve(ctx, "latest", "hash")
+			So(err, ShouldBeNil)
+			So(rec, ShouldNotBeNil)
+		})
+	})
+}
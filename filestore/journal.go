@@ -0,0 +1,112 @@
+package filestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// journal is an append-only file of JSON records, one per line. It suits
+// stores that keep every record forever, where rewriting the whole file on
+// each change would grow too slow. Each append is synced before it returns.
+type journal struct {
+	path string
+	f    *os.File
+}
+
+// openJournal opens the journal at path, creating it if needed, and calls fn
+// with each record in order. A partially written last record, left by a
+// crash during an append, is discarded.
+func openJournal(path string, fn func(line []byte) error) (*journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var valid int64
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			break // torn write
+		}
+		if err := fn(b[:i]); err != nil {
+			return nil, fmt.Errorf("%s: record at offset %d: %w", path, valid, err)
+		}
+		valid += int64(i + 1)
+		b = b[i+1:]
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &journal{path: path, f: f}, nil
+}
+
+// append writes v as the next record.
+func (j *journal) append(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// rewrite atomically replaces every record with vs, for stores that compact
+// their journal.
+func (j *journal) rewrite(vs []interface{}) error {
+	var buf bytes.Buffer
+	for _, v := range vs {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(j.path), filepath.Base(j.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), j.path); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	// The renamed file is the journal now; appends continue at its end.
+	j.f.Close()
+	j.f = f
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.f.Close()
+}
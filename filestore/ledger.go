@@ -0,0 +1,80 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// LedgerStore is a disbursement.LedgerStore persisted to a journal file.
+type LedgerStore struct {
+	journal *journal
+
+	mu      sync.Mutex
+	entries []*disbursement.LedgerEntry
+
+	now func() time.Time
+}
+
+// OpenLedgerStore loads the store at path, creating it if needed.
+func OpenLedgerStore(path string) (*LedgerStore, error) {
+	s := &LedgerStore{now: time.Now}
+	j, err := openJournal(path, func(line []byte) error {
+		var e disbursement.LedgerEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		s.entries = append(s.entries, &e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = j
+	return s, nil
+}
+
+// Close closes the journal file.
+func (s *LedgerStore) Close() error {
+	return s.journal.close()
+}
+
+func (s *LedgerStore) CreateLedgerEntry(ctx context.Context, e *disbursement.LedgerEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.ID == "" {
+		e.ID = disbursement.NewLedgerEntryID()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = s.now()
+	}
+	if err := s.journal.append(e); err != nil {
+		return err
+	}
+	s.entries = append(s.entries, copyLedgerEntry(e))
+	return nil
+}
+
+func (s *LedgerStore) FindLedgerEntries(ctx context.Context, filter disbursement.LedgerFilter) ([]*disbursement.LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []*disbursement.LedgerEntry
+	for _, e := range s.entries {
+		if filter.Match(e) {
+			entries = append(entries, copyLedgerEntry(e))
+		}
+	}
+	return entries, nil
+}
+
+// copyLedgerEntry returns a copy of e that shares no lines with it.
+func copyLedgerEntry(e *disbursement.LedgerEntry) *disbursement.LedgerEntry {
+	cp := *e
+	cp.Lines = append([]disbursement.LedgerLine(nil), e.Lines...)
+	return &cp
+}
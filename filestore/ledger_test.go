@@ -0,0 +1,45 @@
+package filestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLedgerStore(t *testing.T) {
+	Convey("ledger entries survive reopening the store", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		php := disbursement.Money{Minor: 100000, Currency: "PHP"}
+
+		s, err := OpenLedgerStore(path)
+		So(err, ShouldBeNil)
+		e := &disbursement.LedgerEntry{
+			Kind: disbursement.LedgerDeposit,
+			Memo: "top-up",
+			Lines: []disbursement.LedgerLine{
+				{Account: disbursement.LedgerPartner, Side: disbursement.LedgerDebit, Amount: php},
+				{Account: disbursement.LedgerFunding, Side: disbursement.LedgerCredit, Amount: php},
+			},
+		}
+		So(s.CreateLedgerEntry(ctx, e), ShouldBeNil)
+		So(e.ID, ShouldStartWith, "led_")
+		So(s.Close(), ShouldBeNil)
+
+		s, err = OpenLedgerStore(path)
+		So(err, ShouldBeNil)
+		defer s.Close()
+		entries, err := s.FindLedgerEntries(ctx, disbursement.LedgerFilter{Account: disbursement.LedgerFunding})
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 1)
+		So(entries[0].ID, ShouldEqual, e.ID)
+		So(entries[0].Lines, ShouldResemble, e.Lines)
+
+		entries, err = s.FindLedgerEntries(ctx, disbursement.LedgerFilter{Account: disbursement.LedgerHolds})
+		So(err, ShouldBeNil)
+		So(entries, ShouldBeEmpty)
+	})
+}
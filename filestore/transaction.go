@@ -0,0 +1,128 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// TransactionStore is a disbursement.TransactionStore persisted to a journal
+// file. Every create and update appends the full transaction, so the file is
+// also a log of each transaction's changes. Indexes are rebuilt in memory on
+// open.
+type TransactionStore struct {
+	journal *journal
+
+	mu           sync.Mutex
+	transactions map[string]*disbursement.Transaction
+	order        []string
+
+	now func() time.Time
+}
+
+// OpenTransactionStore loads the store at path, creating it if needed.
+func OpenTransactionStore(path string) (*TransactionStore, error) {
+	s := &TransactionStore{
+		transactions: make(map[string]*disbursement.Transaction),
+		now:          time.Now,
+	}
+	j, err := openJournal(path, func(line []byte) error {
+		var tx disbursement.Transaction
+		if err := json.Unmarshal(line, &tx); err != nil {
+			return err
+		}
+		if _, ok := s.transactions[tx.ID]; !ok {
+			s.order = append(s.order, tx.ID)
+		}
+		s.transactions[tx.ID] = &tx
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = j
+	return s, nil
+}
+
+// Close closes the journal file.
+func (s *TransactionStore) Close() error {
+	return s.journal.close()
+}
+
+func (s *TransactionStore) CreateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tx.ID == "" {
+		tx.ID = disbursement.NewTransactionID()
+	}
+	if tx.CreatedAt.IsZero() {
+		tx.CreatedAt = s.now()
+	}
+	if tx.UpdatedAt.IsZero() {
+		tx.UpdatedAt = tx.CreatedAt
+	}
+	if err := s.journal.append(tx); err != nil {
+		return err
+	}
+	s.transactions[tx.ID] = copyTransaction(tx)
+	s.order = append(s.order, tx.ID)
+	return nil
+}
+
+func (s *TransactionStore) UpdateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.transactions[tx.ID]
+	if !ok {
+		return disbursement.ErrNotFound
+	} else if stored.Version != tx.Version {
+		return disbursement.ErrConflict
+	}
+	tx.Version++
+	if err := s.journal.append(tx); err != nil {
+		tx.Version--
+		return err
+	}
+	s.transactions[tx.ID] = copyTransaction(tx)
+	return nil
+}
+
+func (s *TransactionStore) FindTransactionByID(ctx context.Context, id string) (*disbursement.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return copyTransaction(tx), nil
+}
+
+func (s *TransactionStore) FindTransactions(ctx context.Context, filter disbursement.TransactionFilter) ([]*disbursement.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var txs []*disbursement.Transaction
+	for _, id := range s.order {
+		if filter.Limit > 0 && len(txs) == filter.Limit {
+			break
+		}
+		if tx := s.transactions[id]; filter.Match(tx) {
+			txs = append(txs, copyTransaction(tx))
+		}
+	}
+	return txs, nil
+}
+
+// copyTransaction returns a copy of tx that shares no history with it. The
+// request is never modified once recorded, so it is shared.
+func copyTransaction(tx *disbursement.Transaction) *disbursement.Transaction {
+	cp := *tx
+	cp.History = append([]disbursement.TransactionEvent(nil), tx.History...)
+	return &cp
+}
@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTransactionStore(t *testing.T) {
+	Convey("transactions survive reopening the store", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "transactions.jsonl")
+
+		s, err := OpenTransactionStore(path)
+		So(err, ShouldBeNil)
+		tx := &disbursement.Transaction{Method: disbursement.MethodInstapay, SenderRefID: "REF1", Request: &disbursement.Disbursement{
+			ReferenceID: "INV-1",
+			Details:     disbursement.Details{Amount: disbursement.Money{Minor: 10000, Currency: "PHP"}},
+		}}
+		tx.SetState(disbursement.TransferPending, "", s.now())
+		So(s.CreateTransaction(ctx, tx), ShouldBeNil)
+		So(tx.ID, ShouldNotBeEmpty)
+		tx.SetState(disbursement.TransferCredited, "Credited Beneficiary Account", s.now())
+		So(s.UpdateTransaction(ctx, tx), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		Convey("with their latest state", func() {
+			s, err := OpenTransactionStore(path)
+			So(err, ShouldBeNil)
+			defer s.Close()
+			got, err := s.FindTransactionByID(ctx, tx.ID)
+			So(err, ShouldBeNil)
+			So(got.State, ShouldEqual, disbursement.TransferCredited)
+			So(got.History, ShouldHaveLength, 2)
+			So(got.Request.ReferenceID, ShouldEqual, "INV-1")
+			So(got.Request.Details.Amount.String(), ShouldEqual, "100.00")
+
+			txs, err := s.FindTransactions(ctx, disbursement.TransactionFilter{ReferenceID: "INV-1"})
+			So(err, ShouldBeNil)
+			So(txs, ShouldHaveLength, 1)
+		})
+
+		Convey("refusing updates of a stale copy", func() {
+			s, err := OpenTransactionStore(path)
+			So(err, ShouldBeNil)
+			defer s.Close()
+			a, _ := s.FindTransactionByID(ctx, tx.ID)
+			b, _ := s.FindTransactionByID(ctx, tx.ID)
+			a.SetState(disbursement.TransferReturned, "Returned", s.now())
+			So(s.UpdateTransaction(ctx, a), ShouldBeNil)
+			b.SetState(disbursement.TransferFailed, "Failed", s.now())
+			So(errors.Is(s.UpdateTransaction(ctx, b), disbursement.ErrConflict), ShouldBeTrue)
+
+			got, _ := s.FindTransactionByID(ctx, tx.ID)
+			So(got.State, ShouldEqual, disbursement.TransferReturned)
+		})
+
+		Convey("discarding a record torn by a crash", func() {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+			So(err, ShouldBeNil)
+			f.WriteString(`{"id":"` + tx.ID + `","state":"fai`)
+			f.Close()
+
+			s, err := OpenTransactionStore(path)
+			So(err, ShouldBeNil)
+			got, err := s.FindTransactionByID(ctx, tx.ID)
+			So(err, ShouldBeNil)
+			So(got.State, ShouldEqual, disbursement.TransferCredited)
+
+			So(s.CreateTransaction(ctx, &disbursement.Transaction{SenderRefID: "REF2"}), ShouldBeNil)
+			So(s.Close(), ShouldBeNil)
+			s, err = OpenTransactionStore(path)
+			So(err, ShouldBeNil)
+			defer s.Close()
+			txs, _ := s.FindTransactions(ctx, disbursement.TransactionFilter{})
+			So(txs, ShouldHaveLength, 2)
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// WebhookStore is a disbursement.WebhookStore persisted to a journal file,
+// so registered endpoints, events and pending deliveries survive a restart.
+type WebhookStore struct {
+	journal *journal
+
+	mu         sync.Mutex
+	endpoints  []*disbursement.WebhookEndpoint
+	events     map[string]*disbursement.Event
+	deliveries []*disbursement.WebhookDelivery
+	byID       map[string]int
+}
+
+// webhookRecord is one journal entry. Exactly one field is set.
+type webhookRecord struct {
+	Endpoint        *disbursement.WebhookEndpoint `json:"endpoint,omitempty"`
+	DeletedEndpoint string                        `json:"deleted_endpoint,omitempty"`
+	Event           *disbursement.Event           `json:"event,omitempty"`
+	Delivery        *disbursement.WebhookDelivery `json:"delivery,omitempty"`
+}
+
+// OpenWebhookStore loads the store at path, creating it if needed.
+func OpenWebhookStore(path string) (*WebhookStore, error) {
+	s := &WebhookStore{
+		events: make(map[string]*disbursement.Event),
+		byID:   make(map[string]int),
+	}
+	j, err := openJournal(path, func(line []byte) error {
+		var rec webhookRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		s.apply(&rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = j
+	return s, nil
+}
+
+// Close closes the journal file.
+func (s *WebhookStore) Close() error {
+	return s.journal.close()
+}
+
+// apply adds rec to the in-memory state.
+func (s *WebhookStore) apply(rec *webhookRecord) {
+	switch {
+	case rec.Endpoint != nil:
+		s.endpoints = append(s.endpoints, rec.Endpoint)
+	case rec.DeletedEndpoint != "":
+		for i, e := range s.endpoints {
+			if e.ID == rec.DeletedEndpoint {
+				s.endpoints = append(s.endpoints[:i], s.endpoints[i+1:]...)
+				break
+			}
+		}
+	case rec.Event != nil:
+		s.events[rec.Event.ID] = rec.Event
+	case rec.Delivery != nil:
+		if i, ok := s.byID[rec.Delivery.ID]; ok {
+			s.deliveries[i] = rec.Delivery
+		} else {
+			s.byID[rec.Delivery.ID] = len(s.deliveries)
+			s.deliveries = append(s.deliveries, rec.Delivery)
+		}
+	}
+}
+
+// write journals rec and applies it.
+func (s *WebhookStore) write(rec *webhookRecord) error {
+	if err := s.journal.append(rec); err != nil {
+		return err
+	}
+	s.apply(rec)
+	return nil
+}
+
+func (s *WebhookStore) CreateWebhookEndpoint(ctx context.Context, e *disbursement.WebhookEndpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *e
+	return s.write(&webhookRecord{Endpoint: &cp})
+}
+
+func (s *WebhookStore) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.endpoints {
+		if e.ID == id {
+			return s.write(&webhookRecord{DeletedEndpoint: id})
+		}
+	}
+	return disbursement.ErrNotFound
+}
+
+func (s *WebhookStore) FindWebhookEndpoints(ctx context.Context) ([]*disbursement.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := make([]*disbursement.WebhookEndpoint, len(s.endpoints))
+	for i, e := range s.endpoints {
+		cp := *e
+		endpoints[i] = &cp
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookStore) CreateEvent(ctx context.Context, e *disbursement.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(&webhookRecord{Event: e})
+}
+
+func (s *WebhookStore) FindEventByID(ctx context.Context, id string) (*disbursement.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *WebhookStore) CreateWebhookDelivery(ctx context.Context, d *disbursement.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *d
+	return s.write(&webhookRecord{Delivery: &cp})
+}
+
+func (s *WebhookStore) UpdateWebhookDelivery(ctx context.Context, d *disbursement.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byID[d.ID]; !ok {
+		return disbursement.ErrNotFound
+	}
+	cp := *d
+	return s.write(&webhookRecord{Delivery: &cp})
+}
+
+func (s *WebhookStore) FindWebhookDeliveries(ctx context.Context, filter disbursement.WebhookDeliveryFilter) ([]*disbursement.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deliveries []*disbursement.WebhookDelivery
+	for _, d := range s.deliveries {
+		if filter.Limit > 0 && len(deliveries) == filter.Limit {
+			break
+		}
+		if filter.Match(d) {
+			cp := *d
+			deliveries = append(deliveries, &cp)
+		}
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,47 @@
+package filestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhookStore(t *testing.T) {
+	Convey("endpoints, events and delivery progress survive reopening the store", t, func() {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "webhooks.jsonl")
+
+		s, err := OpenWebhookStore(path)
+		So(err, ShouldBeNil)
+		So(s.CreateWebhookEndpoint(ctx, &disbursement.WebhookEndpoint{ID: "wh_1", URL: "https://example.com/a", Secret: "whsec_1"}), ShouldBeNil)
+		So(s.CreateWebhookEndpoint(ctx, &disbursement.WebhookEndpoint{ID: "wh_2", URL: "https://example.com/b"}), ShouldBeNil)
+		So(s.DeleteWebhookEndpoint(ctx, "wh_2"), ShouldBeNil)
+		So(s.CreateEvent(ctx, &disbursement.Event{ID: "evt_1", Type: disbursement.EventTransferFailed}), ShouldBeNil)
+
+		d := &disbursement.WebhookDelivery{ID: "whd_1", EndpointID: "wh_1", EventID: "evt_1", State: disbursement.WebhookDeliveryPending, NextAttemptAt: time.Now()}
+		So(s.CreateWebhookDelivery(ctx, d), ShouldBeNil)
+		d.Attempts, d.State = 8, disbursement.WebhookDeliveryDead
+		So(s.UpdateWebhookDelivery(ctx, d), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		s, err = OpenWebhookStore(path)
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		endpoints, _ := s.FindWebhookEndpoints(ctx)
+		So(endpoints, ShouldHaveLength, 1)
+		So(endpoints[0].Secret, ShouldEqual, "whsec_1")
+
+		e, err := s.FindEventByID(ctx, "evt_1")
+		So(err, ShouldBeNil)
+		So(e.Type, ShouldEqual, disbursement.EventTransferFailed)
+
+		dead, _ := s.FindWebhookDeliveries(ctx, disbursement.WebhookDeliveryFilter{State: disbursement.WebhookDeliveryDead})
+		So(dead, ShouldHaveLength, 1)
+		So(dead[0].Attempts, ShouldEqual, 8)
+	})
+}
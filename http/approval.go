@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/pressly/chi"
+)
+
+// rejectApprovalRequest is the body of POST /approvals/{id}/reject.
+type rejectApprovalRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *disbursementHandler) handleGetApprovals(w http.ResponseWriter, r *http.Request) {
+	if h.approvalService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	filter := disbursement.ApprovalFilter{State: disbursement.ApprovalState(r.URL.Query().Get("state"))}
+	approvals, err := h.approvalService.FindApprovals(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	if approvals == nil {
+		approvals = []*disbursement.Approval{}
+	}
+	encodeJSON(w, http.StatusOK, approvals)
+}
+
+func (h *disbursementHandler) handleGetApproval(w http.ResponseWriter, r *http.Request) {
+	if h.approvalService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	a, err := h.approvalService.FindApprovalByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, a)
+}
+
+// handleApprove approves a held transfer on behalf of the calling client.
+// The approval that completes it sends the transfer; its outcome is in the
+// response.
+func (h *disbursementHandler) handleApprove(w http.ResponseWriter, r *http.Request) {
+	if h.approvalService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	a, err := h.approvalService.Approve(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, a)
+}
+
+func (h *disbursementHandler) handleReject(w http.ResponseWriter, r *http.Request) {
+	if h.approvalService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	var req rejectApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, fmt.Errorf("%w: cannot parse request body: %s", disbursement.ErrInvalid, err))
+		return
+	}
+
+	a, err := h.approvalService.Reject(r.Context(), chi.URLParam(r, "id"), req.Reason)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, a)
+}
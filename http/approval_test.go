@@ -0,0 +1,93 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/approval"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	"github.com/jfpalngipang/fund-disbursement/payout"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testClientHeader names the client a test request is made as.
+const testClientHeader = "X-Test-Client"
+
+// withTestClient puts the client named by testClientHeader in the context.
+func withTestClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := disbursement.NewContextWithClientID(r.Context(), r.Header.Get(testClientHeader))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func postAs(srv *httptest.Server, client, path, body string) (*http.Response, string) {
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+	req.Header.Set(testClientHeader, client)
+	resp, err := http.DefaultClient.Do(req)
+	So(err, ShouldBeNil)
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestApprovals(t *testing.T) {
+	Convey("given a server holding transfers above 20.00 for approval", t, func() {
+		svc := &fakeDisbursementService{}
+		approvals, err := approval.NewService(svc, inmem.NewApprovalStore(), approval.Config{
+			Rules:     []approval.Rule{{Above: disbursement.Money{Minor: 2000, Currency: "PHP"}, Approvals: 1}},
+			Approvers: []*disbursement.Approver{{ID: "treasury", Roles: []string{"treasury"}}},
+		})
+		So(err, ShouldBeNil)
+
+		s := NewServer()
+		s.DisbursementService = svc
+		s.ApprovalService = approvals
+		s.BatchService = payout.NewBatchRunner(payout.NewService(svc, inmem.NewTransactionStore()), inmem.NewBatchStore())
+		s.Validator = disbursement.NewValidator(nil)
+		srv := httptest.NewServer(withTestClient(s.router()))
+		defer srv.Close()
+
+		resp, out := postAs(srv, "acme", "/disbursement/single/pesonet", testTransferBody)
+		So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+		var held disbursement.Approval
+		So(json.Unmarshal([]byte(out), &held), ShouldBeNil)
+		So(held.State, ShouldEqual, disbursement.ApprovalPending)
+		So(svc.transfers, ShouldBeEmpty)
+
+		Convey("the requester cannot approve it", func() {
+			resp, _ := postAs(srv, "acme", "/disbursement/approvals/"+held.ID+"/approve", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("an approver's approval sends it", func() {
+			resp, out := postAs(srv, "treasury", "/disbursement/approvals/"+held.ID+"/approve", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			var got disbursement.Approval
+			So(json.Unmarshal([]byte(out), &got), ShouldBeNil)
+			So(got.State, ShouldEqual, disbursement.ApprovalApproved)
+			So(got.Result.ReferenceID, ShouldEqual, "REF1")
+			So(svc.transfers, ShouldHaveLength, 1)
+		})
+
+		Convey("a rejection needs a reason", func() {
+			resp, _ := postAs(srv, "treasury", "/disbursement/approvals/"+held.ID+"/reject", `{}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+			resp, out := postAs(srv, "treasury", "/disbursement/approvals/"+held.ID+"/reject", `{"reason":"unknown receiver"}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(out, ShouldContainSubstring, `"state":"rejected"`)
+		})
+
+		Convey("batch items that would be held are refused", func() {
+			resp, out := postAs(srv, "acme", "/disbursement/batches", `{"method":"pesonet","items":[`+testTransferBody+`]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(out, ShouldContainSubstring, "requires approval")
+		})
+	})
+}
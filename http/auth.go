@@ -0,0 +1,208 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Headers of signed requests.
+const (
+	ClientIDHeader  = "X-Client-ID"
+	TimestampHeader = "X-Timestamp"
+	SignatureHeader = "X-Signature"
+)
+
+// DefaultSignatureWindow is how far the timestamp of a signed request may be
+// from the server's clock.
+const DefaultSignatureWindow = 5 * time.Minute
+
+// Authenticator identifies the API client making a request.
+type Authenticator interface {
+	// Authenticate returns the client whose credentials r carries. It
+	// returns nil if r carries no credentials of the kind it checks, and an
+	// error matching disbursement.ErrUnauthenticated if they are invalid.
+	Authenticate(r *http.Request) (*disbursement.Client, error)
+}
+
+// authenticate identifies the client of each request with the server's
+// authenticators, in order, and rejects requests none of them accepts. If the
+// server has no authenticators every request is passed on anonymously.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.Authenticators) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var client *disbursement.Client
+		for _, a := range s.Authenticators {
+			c, err := a.Authenticate(r)
+			if err != nil {
+				Error(w, r, err)
+				return
+			} else if c != nil {
+				client = c
+				break
+			}
+		}
+		if client == nil {
+			Error(w, r, fmt.Errorf("%w: no credentials", disbursement.ErrUnauthenticated))
+			return
+		}
+		setAuditClient(r.Context(), client.ID)
+		if client.Disabled {
+			Error(w, r, fmt.Errorf("%w: client %q is disabled", disbursement.ErrUnauthenticated, client.ID))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(disbursement.NewContextWithClient(r.Context(), client)))
+	})
+}
+
+// requireScope refuses requests from clients without scope. It does nothing
+// unless the server authenticates requests.
+func (h *disbursementHandler) requireScope(scope disbursement.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.requireScopes {
+				next.ServeHTTP(w, r)
+				return
+			}
+			c := disbursement.ClientFromContext(r.Context())
+			if c == nil || !c.HasScope(scope) {
+				Error(w, r, fmt.Errorf("%w: the %s scope is required", disbursement.ErrForbidden, scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIKeyAuthenticator accepts requests carrying a client's API key as a
+// bearer token in the Authorization header.
+type APIKeyAuthenticator struct {
+	Clients disbursement.ClientService
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*disbursement.Client, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, nil
+	}
+	c, err := a.Clients.FindClientByAPIKey(r.Context(), strings.TrimPrefix(auth, "Bearer "))
+	if errors.Is(err, disbursement.ErrNotFound) {
+		return nil, fmt.Errorf("%w: invalid API key", disbursement.ErrUnauthenticated)
+	} else if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SignatureAuthenticator accepts requests signed with a client's signing
+// secret. The client sends its id, the Unix time of the request and the hex
+// HMAC-SHA256 of
+//
+//	METHOD \n REQUEST-URI \n TIMESTAMP \n hex(SHA-256(BODY))
+//
+// in the ClientIDHeader, TimestampHeader and SignatureHeader headers.
+//
+// Requests whose timestamp is further than Window from the server's clock
+// are refused, so a captured request can be replayed only within Window. A
+// second request with the same signature is also refused, but only by the
+// process that saw the first and until it restarts; other replicas accept
+// it. Clients that must not have a transfer repeated send an idempotency
+// key with it.
+type SignatureAuthenticator struct {
+	Clients disbursement.ClientService
+	Window  time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time // signature by when it may be forgotten
+
+	now func() time.Time
+}
+
+// NewSignatureAuthenticator returns a SignatureAuthenticator for clients.
+func NewSignatureAuthenticator(clients disbursement.ClientService) *SignatureAuthenticator {
+	return &SignatureAuthenticator{
+		Clients: clients,
+		Window:  DefaultSignatureWindow,
+		seen:    make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (a *SignatureAuthenticator) Authenticate(r *http.Request) (*disbursement.Client, error) {
+	sig := r.Header.Get(SignatureHeader)
+	if sig == "" {
+		return nil, nil
+	}
+
+	ts, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing or malformed %s", disbursement.ErrUnauthenticated, TimestampHeader)
+	}
+	now := a.now()
+	at := time.Unix(ts, 0)
+	if at.Before(now.Add(-a.Window)) || at.After(now.Add(a.Window)) {
+		return nil, fmt.Errorf("%w: request timestamp outside the allowed window", disbursement.ErrUnauthenticated)
+	}
+
+	c, err := a.Clients.FindClientByID(r.Context(), r.Header.Get(ClientIDHeader))
+	if errors.Is(err, disbursement.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown client", disbursement.ErrUnauthenticated)
+	} else if err != nil {
+		return nil, err
+	} else if c.SigningSecret == "" {
+		return nil, fmt.Errorf("%w: client %q cannot sign requests", disbursement.ErrUnauthenticated, c.ID)
+	}
+
+	// The body is read to be signed and restored for the handler.
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxUploadSize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read request body: %s", disbursement.ErrInvalid, err)
+	} else if len(b) > maxUploadSize {
+		return nil, fmt.Errorf("%w: request body too large", disbursement.ErrInvalid)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	want := Sign(c.SigningSecret, r.Method, r.URL.RequestURI(), r.Header.Get(TimestampHeader), b)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return nil, fmt.Errorf("%w: invalid signature", disbursement.ErrUnauthenticated)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for s, until := range a.seen {
+		if now.After(until) {
+			delete(a.seen, s)
+		}
+	}
+	if _, ok := a.seen[sig]; ok {
+		return nil, fmt.Errorf("%w: replayed request", disbursement.ErrUnauthenticated)
+	}
+	a.seen[sig] = at.Add(a.Window)
+	return c, nil
+}
+
+// Sign returns the signature of a request made with secret, as checked by
+// SignatureAuthenticator. timestamp is the Unix time sent in TimestampHeader.
+func Sign(secret, method, requestURI, timestamp string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestURI, timestamp, hex.EncodeToString(digest[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
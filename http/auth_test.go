@@ -0,0 +1,127 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuthentication(t *testing.T) {
+	Convey("given a server authenticating API keys and signed requests", t, func() {
+		clients, err := inmem.NewClientService([]*disbursement.Client{
+			{ID: "viewer", Scopes: []disbursement.Scope{disbursement.ScopeReadBanks}, APIKeyHashes: []string{disbursement.HashAPIKey("viewer-key")}},
+			{ID: "payroll", Scopes: []disbursement.Scope{disbursement.ScopeCreateTransfer}, SigningSecret: "payroll-secret"},
+			{ID: "former", Scopes: []disbursement.Scope{disbursement.ScopeReadBanks}, APIKeyHashes: []string{disbursement.HashAPIKey("former-key")}, Disabled: true},
+		})
+		So(err, ShouldBeNil)
+		signatures := NewSignatureAuthenticator(clients)
+		now := time.Now()
+		signatures.now = func() time.Time { return now }
+
+		svc := &fakeDisbursementService{}
+		auditLog := inmem.NewAuditLog()
+		s := NewServer()
+		s.DisbursementService = svc
+		s.Authenticators = []Authenticator{&APIKeyAuthenticator{Clients: clients}, signatures}
+		s.AuditLog = auditLog
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		do := func(method, path, body string, header map[string]string) (int, string) {
+			req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, string(b)
+		}
+		signed := func(client, secret, body string, at time.Time) map[string]string {
+			ts := strconv.FormatInt(at.Unix(), 10)
+			return map[string]string{
+				ClientIDHeader:  client,
+				TimestampHeader: ts,
+				SignatureHeader: Sign(secret, http.MethodPost, "/disbursement/single/instapay", ts, []byte(body)),
+			}
+		}
+
+		Convey("requests without valid credentials are refused", func() {
+			status, out := do(http.MethodGet, "/disbursement/instapay/banks", "", nil)
+			So(status, ShouldEqual, http.StatusUnauthorized)
+			So(out, ShouldContainSubstring, ECodeUnauthenticated)
+
+			status, _ = do(http.MethodGet, "/disbursement/instapay/banks", "", map[string]string{"Authorization": "Bearer guess"})
+			So(status, ShouldEqual, http.StatusUnauthorized)
+
+			status, _ = do(http.MethodGet, "/disbursement/instapay/banks", "", map[string]string{"Authorization": "Bearer former-key"})
+			So(status, ShouldEqual, http.StatusUnauthorized)
+
+			status, _ = do(http.MethodGet, "/health", "", nil)
+			So(status, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("API keys grant their client's scopes only", func() {
+			key := map[string]string{"Authorization": "Bearer viewer-key"}
+			status, _ := do(http.MethodGet, "/disbursement/instapay/banks", "", key)
+			So(status, ShouldEqual, http.StatusOK)
+
+			status, out := do(http.MethodPost, "/disbursement/single/instapay", testTransferBody, key)
+			So(status, ShouldEqual, http.StatusForbidden)
+			So(out, ShouldContainSubstring, "transfers:create")
+			So(svc.transfers, ShouldBeEmpty)
+		})
+
+		Convey("every call is audited with its client and outcome", func() {
+			do(http.MethodGet, "/disbursement/instapay/banks", "", map[string]string{"Authorization": "Bearer viewer-key"})
+			do(http.MethodGet, "/disbursement/instapay/banks", "", nil)
+			do(http.MethodGet, "/health", "", nil)
+
+			entries := auditLog.Entries()
+			So(entries, ShouldHaveLength, 2)
+			So(entries[0].Kind, ShouldEqual, disbursement.AuditAPICall)
+			So(entries[0].ActorID, ShouldEqual, "viewer")
+			So(entries[0].Subject, ShouldEqual, "/disbursement/instapay/banks")
+			So(string(entries[0].Details), ShouldContainSubstring, `"status":200`)
+			So(entries[1].ActorID, ShouldBeEmpty)
+			So(string(entries[1].Details), ShouldContainSubstring, `"status":401`)
+		})
+
+		Convey("signed requests are accepted once", func() {
+			header := signed("payroll", "payroll-secret", testTransferBody, now)
+			status, _ := do(http.MethodPost, "/disbursement/single/instapay", testTransferBody, header)
+			So(status, ShouldEqual, http.StatusOK)
+			So(svc.transfers, ShouldHaveLength, 1)
+
+			status, out := do(http.MethodPost, "/disbursement/single/instapay", testTransferBody, header)
+			So(status, ShouldEqual, http.StatusUnauthorized)
+			So(out, ShouldContainSubstring, "replayed")
+			So(svc.transfers, ShouldHaveLength, 1)
+		})
+
+		Convey("signed requests are refused if altered, stale or signed with another secret", func() {
+			header := signed("payroll", "payroll-secret", testTransferBody, now)
+			status, _ := do(http.MethodPost, "/disbursement/single/instapay", strings.Replace(testTransferBody, "30.00", "3000.00", 1), header)
+			So(status, ShouldEqual, http.StatusUnauthorized)
+
+			header = signed("payroll", "payroll-secret", testTransferBody, now.Add(-10*time.Minute))
+			status, out := do(http.MethodPost, "/disbursement/single/instapay", testTransferBody, header)
+			So(status, ShouldEqual, http.StatusUnauthorized)
+			So(out, ShouldContainSubstring, "window")
+
+			header = signed("payroll", "guess", testTransferBody, now)
+			status, _ = do(http.MethodPost, "/disbursement/single/instapay", testTransferBody, header)
+			So(status, ShouldEqual, http.StatusUnauthorized)
+			So(svc.transfers, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// defaultBankPageSize is the page size of GET /banks without a limit.
+const defaultBankPageSize = 50
+
+// findBanksResponse is a page of the bank directory.
+type findBanksResponse struct {
+	Banks  []*disbursement.DirectoryBank `json:"banks"`
+	Total  int                           `json:"total"`
+	Offset int                           `json:"offset"`
+	Limit  int                           `json:"limit"`
+}
+
+// handleFindBanks searches the bank directory. Query parameters are q, method,
+// offset and limit.
+func (h *disbursementHandler) handleFindBanks(w http.ResponseWriter, r *http.Request) {
+	if h.bankDirectory == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := disbursement.BankFilter{Query: q.Get("q"), Limit: defaultBankPageSize}
+	if method := q.Get("method"); method != "" {
+		m, err := disbursement.ParseMethod(method)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+		filter.Method = m
+	}
+	for name, dst := range map[string]*int{"offset": &filter.Offset, "limit": &filter.Limit} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				Error(w, r, fmt.Errorf("%w: invalid %s %q", disbursement.ErrInvalid, name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+
+	banks, total, err := h.bankDirectory.FindBanks(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	if banks == nil {
+		banks = []*disbursement.DirectoryBank{}
+	}
+	encodeJSON(w, http.StatusOK, findBanksResponse{Banks: banks, Total: total, Offset: filter.Offset, Limit: filter.Limit})
+}
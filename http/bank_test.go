@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/bankdir"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindBanks(t *testing.T) {
+	Convey("the bank directory is searched a page at a time", t, func() {
+		svc := &fakeDisbursementService{banks: []disbursement.Bank{
+			{Code: "010419", Name: "BDO Unibank, Inc."},
+			{Code: "161408", Name: "Metropolitan Bank and Trust Co."},
+			{Code: "140013", Name: "Security Bank Corporation"},
+		}}
+		s := NewServer()
+		s.DisbursementService = svc
+		s.BankDirectory = bankdir.NewDirectory(svc)
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		get := func(path string) (*http.Response, findBanksResponse) {
+			resp, err := http.Get(srv.URL + path)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			var page findBanksResponse
+			json.NewDecoder(resp.Body).Decode(&page)
+			return resp, page
+		}
+
+		resp, page := get("/disbursement/banks?q=metropolitn&method=pesonet")
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(page.Total, ShouldEqual, 1)
+		So(page.Banks[0].Code, ShouldEqual, "161408")
+		So(page.Banks[0].Methods, ShouldResemble, []disbursement.Method{disbursement.MethodInstapay, disbursement.MethodPesonet})
+
+		_, page = get("/disbursement/banks?limit=2&offset=2")
+		So(page.Total, ShouldEqual, 3)
+		So(page.Banks, ShouldHaveLength, 1)
+
+		resp, _ = get("/disbursement/banks?method=gcash")
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+	})
+}
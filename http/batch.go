@@ -0,0 +1,289 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/csv"
+	"github.com/pressly/chi"
+)
+
+// maxBatchItems bounds the number of disbursements in one batch.
+const maxBatchItems = 1000
+
+// maxUploadSize bounds the size of an uploaded batch file.
+const maxUploadSize = 10 << 20
+
+// createBatchRequest is the body of POST /batches. Items are decoded one by
+// one so that every invalid item is reported, not just the first.
+type createBatchRequest struct {
+	// Method is the rail of every item that does not name its own.
+	Method disbursement.Method `json:"method"`
+	Items  []json.RawMessage   `json:"items"`
+
+	// AfterCutoff is "submit" (the default) or "defer", to hold items
+	// that missed their rail's cutoff until its next window.
+	AfterCutoff string `json:"after_cutoff"`
+}
+
+// batchItemRequest is one item of a createBatchRequest.
+type batchItemRequest struct {
+	Method disbursement.Method `json:"method"`
+	disbursement.Disbursement
+}
+
+// batchResponse is a batch with its totals.
+type batchResponse struct {
+	*disbursement.Batch
+	Totals disbursement.BatchTotals `json:"totals"`
+}
+
+func newBatchResponse(b *disbursement.Batch) batchResponse {
+	return batchResponse{Batch: b, Totals: b.Totals()}
+}
+
+func (h *disbursementHandler) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	if h.batchService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	var req createBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, fmt.Errorf("%w: cannot parse request body: %s", disbursement.ErrInvalid, err))
+		return
+	}
+	switch {
+	case len(req.Items) == 0:
+		Error(w, r, fmt.Errorf("%w: a batch needs at least one item", disbursement.ErrInvalid))
+		return
+	case len(req.Items) > maxBatchItems:
+		Error(w, r, fmt.Errorf("%w: a batch holds at most %d items", disbursement.ErrInvalid, maxBatchItems))
+		return
+	}
+	policy, err := disbursement.ParseCutoffPolicy(req.AfterCutoff)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Every item is checked before any is sent, so a batch is either
+	// accepted whole or rejected with the problems of all its items.
+	b := &disbursement.Batch{AfterCutoff: policy}
+	verr := &disbursement.ValidationError{}
+	for i, raw := range req.Items {
+		field := "items[" + strconv.Itoa(i) + "]"
+		rename := func(fe disbursement.FieldError) disbursement.FieldError {
+			if fe.Field == "" {
+				fe.Field = field
+			} else {
+				fe.Field = field + "." + fe.Field
+			}
+			return fe
+		}
+
+		item, err := decodeBatchItem(raw, req.Method)
+		if err == nil {
+			err = h.prepareItem(r.Context(), item)
+		}
+		if err == nil {
+			b.Items = append(b.Items, item)
+		} else if err := collectFieldErrors(verr, err, rename); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	h.createBatch(w, r, b, verr)
+}
+
+// createBatch starts b unless its items had problems, which are reported
+// instead.
+func (h *disbursementHandler) createBatch(w http.ResponseWriter, r *http.Request, b *disbursement.Batch, verr *disbursement.ValidationError) {
+	if len(verr.Errors) > 0 {
+		Error(w, r, verr)
+		return
+	}
+	if err := h.batchService.CreateBatch(r.Context(), b); err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusAccepted, newBatchResponse(b))
+}
+
+// prepareItem prepares the disbursement of a batch item. Batches run
+// unattended, so items that would need approval are refused.
+func (h *disbursementHandler) prepareItem(ctx context.Context, item *disbursement.BatchItem) error {
+	if err := h.prepare(ctx, item.Method, item.Disbursement); err != nil {
+		return err
+	}
+	if h.approvalService != nil && h.approvalService.RequiresApproval(item.Method, item.Disbursement) {
+		return &disbursement.ValidationError{Errors: []disbursement.FieldError{
+			{Field: "transfer_details.amount", Message: "requires approval; send it as a single disbursement"},
+		}}
+	}
+	return nil
+}
+
+// collectFieldErrors adds the field errors of err to verr, renamed by rename.
+// Errors other than a *disbursement.ValidationError are returned.
+func collectFieldErrors(verr *disbursement.ValidationError, err error, rename func(disbursement.FieldError) disbursement.FieldError) error {
+	var itemErr *disbursement.ValidationError
+	if !errors.As(err, &itemErr) {
+		return err
+	}
+	for _, fe := range itemErr.Errors {
+		verr.Errors = append(verr.Errors, rename(fe))
+	}
+	return nil
+}
+
+// decodeBatchItem decodes one item of a batch, sent through method unless it
+// names its own. Problems are reported as a *disbursement.ValidationError.
+func decodeBatchItem(raw json.RawMessage, method disbursement.Method) (*disbursement.BatchItem, error) {
+	var req batchItemRequest
+	var verr *disbursement.ValidationError
+	if err := json.Unmarshal(raw, &req); errors.As(err, &verr) {
+		return nil, err
+	} else if err != nil {
+		return nil, &disbursement.ValidationError{Errors: []disbursement.FieldError{
+			{Message: "cannot parse item: " + err.Error()},
+		}}
+	}
+
+	if req.Method != "" {
+		method = req.Method
+	}
+	if _, err := disbursement.ParseMethod(string(method)); err != nil {
+		return nil, &disbursement.ValidationError{Errors: []disbursement.FieldError{
+			{Field: "method", Message: fmt.Sprintf("unsupported disbursement method %q", method)},
+		}}
+	}
+	return &disbursement.BatchItem{Method: method, Disbursement: &req.Disbursement}, nil
+}
+
+// handleUploadBatch creates a batch from a CSV file, sent either as the
+// request body or as the "file" field of a multipart form. The method query
+// parameter is the rail of rows that do not name their own, and the
+// after_cutoff parameter is the batch's disbursement.CutoffPolicy.
+func (h *disbursementHandler) handleUploadBatch(w http.ResponseWriter, r *http.Request) {
+	if h.batchService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	policy, err := disbursement.ParseCutoffPolicy(r.URL.Query().Get("after_cutoff"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	var body io.Reader = r.Body
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			Error(w, r, fmt.Errorf("%w: cannot read uploaded file: %s", disbursement.ErrInvalid, err))
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	b, err := csv.ReadBatch(body, disbursement.Method(r.URL.Query().Get("method")))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	b.AfterCutoff = policy
+
+	verr := &disbursement.ValidationError{}
+	for _, item := range b.Items {
+		line := item.Line
+		rename := func(fe disbursement.FieldError) disbursement.FieldError {
+			fe.Field, fe.Line = csv.Column(fe.Field), line
+			return fe
+		}
+		err := h.prepareItem(r.Context(), item)
+		if err := collectFieldErrors(verr, err, rename); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	h.createBatch(w, r, b, verr)
+}
+
+func (h *disbursementHandler) handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	if h.batchService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	b, err := h.batchService.FindBatchByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, newBatchResponse(b))
+}
+
+func (h *disbursementHandler) handleCancelBatch(w http.ResponseWriter, r *http.Request) {
+	if h.batchService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	b, err := h.batchService.CancelBatch(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, newBatchResponse(b))
+}
+
+// handleGetBatchResults writes the outcome of every item of a batch as a CSV
+// file, with the latest state of each item's transaction.
+func (h *disbursementHandler) handleGetBatchResults(w http.ResponseWriter, r *http.Request) {
+	if h.batchService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	b, err := h.batchService.FindBatchByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	txs := make(map[string]*disbursement.Transaction)
+	if h.transactionStore != nil {
+		for _, item := range b.Items {
+			if item.TransactionID == "" {
+				continue
+			}
+			tx, err := h.transactionStore.FindTransactionByID(r.Context(), item.TransactionID)
+			if errors.Is(err, disbursement.ErrNotFound) {
+				continue
+			} else if err != nil {
+				Error(w, r, err)
+				return
+			}
+			txs[tx.ID] = tx
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+b.ID+`-results.csv"`)
+	if err := csv.WriteResults(w, b, txs); err != nil {
+		logError(r, err)
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	"github.com/jfpalngipang/fund-disbursement/payout"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitForBatch polls the batch until it is completed.
+func waitForBatch(srv *httptest.Server, id string) batchResponse {
+	var got batchResponse
+	for i := 0; i < 100 && (got.Batch == nil || got.State != disbursement.BatchCompleted); i++ {
+		time.Sleep(5 * time.Millisecond)
+		resp, err := http.Get(srv.URL + "/disbursement/batches/" + id)
+		So(err, ShouldBeNil)
+		So(json.NewDecoder(resp.Body).Decode(&got), ShouldBeNil)
+		resp.Body.Close()
+	}
+	return got
+}
+
+func TestBatches(t *testing.T) {
+	Convey("given a server running batches", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		svc := &fakeDisbursementService{}
+		txs := inmem.NewTransactionStore()
+		runner := payout.NewBatchRunner(payout.NewService(svc, txs), inmem.NewBatchStore())
+		go runner.Run(ctx)
+
+		s := NewServer()
+		s.DisbursementService = svc
+		s.BatchService = runner
+		s.TransactionStore = txs
+		s.Validator = disbursement.NewValidator(nil)
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		pesonetItem := strings.Replace(testTransferBody, `{"receiver"`, `{"method":"pesonet","receiver"`, 1)
+
+		Convey("every item is sent and reported", func() {
+			resp, out := post(srv, "/disbursement/batches", "", `{"method":"instapay","items":[`+testTransferBody+`,`+pesonetItem+`]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+			var created batchResponse
+			So(json.Unmarshal([]byte(out), &created), ShouldBeNil)
+			So(created.Totals.Count, ShouldEqual, 2)
+			So(created.Totals.Amount.String(), ShouldEqual, "60.00")
+
+			got := waitForBatch(srv, created.ID)
+			So(got.Totals.Submitted, ShouldEqual, 2)
+			So(got.Items[1].Method, ShouldEqual, disbursement.MethodPesonet)
+			So(got.Items[1].Result.ReferenceID, ShouldNotBeEmpty)
+		})
+
+		Convey("a batch with an invalid item is rejected whole", func() {
+			invalid := strings.Replace(testTransferBody, `"30.00"`, `"-1.00"`, 1)
+			resp, out := post(srv, "/disbursement/batches", "", `{"items":[`+pesonetItem+`,`+invalid+`,`+testTransferBody+`]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+			var e ErrorResponse
+			So(json.Unmarshal([]byte(out), &e), ShouldBeNil)
+			var fields []string
+			for _, fe := range e.Error.Fields {
+				fields = append(fields, fe.Field)
+			}
+			So(fields, ShouldResemble, []string{"items[1].transfer_details.amount", "items[2].method"})
+			So(svc.transfers, ShouldBeEmpty)
+		})
+
+		Convey("a batch body over the upload size is refused unread", func() {
+			body := `{"items":[` + pesonetItem + strings.Repeat(" ", maxUploadSize) + `]}`
+			resp, _ := post(srv, "/disbursement/batches", "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(svc.transfers, ShouldBeEmpty)
+		})
+
+		Convey("the cutoff policy is recorded on the batch", func() {
+			resp, out := post(srv, "/disbursement/batches", "", `{"method":"instapay","after_cutoff":"defer","items":[`+testTransferBody+`]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+			var created batchResponse
+			So(json.Unmarshal([]byte(out), &created), ShouldBeNil)
+			So(created.AfterCutoff, ShouldEqual, disbursement.CutoffDefer)
+
+			resp, out = post(srv, "/disbursement/batches", "", `{"method":"instapay","after_cutoff":"later","items":[`+testTransferBody+`]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(out, ShouldContainSubstring, "after_cutoff")
+		})
+
+		Convey("unknown batches are not found", func() {
+			resp, _ := post(srv, "/disbursement/batches/bat_missing/cancel", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("CSV uploads are run as batches with downloadable results", func() {
+			upload := "account_number,name,address_line1,city,province,country,amount,receiving_bank\n" +
+				"109453095653,Rachelle,241 A.Del Mundo St,Caloocan,Metro Manila,PH,\"1,500.00\",161408\n"
+			resp, out := post(srv, "/disbursement/batches/csv?method=instapay", "", upload)
+			So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+			var created batchResponse
+			So(json.Unmarshal([]byte(out), &created), ShouldBeNil)
+			So(waitForBatch(srv, created.ID).Totals.Submitted, ShouldEqual, 1)
+
+			resp, err := http.Get(srv.URL + "/disbursement/batches/" + created.ID + "/results.csv")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.Header.Get("Content-Type"), ShouldStartWith, "text/csv")
+			b, _ := ioutil.ReadAll(resp.Body)
+			So(string(b), ShouldContainSubstring, "2,,instapay,109453095653,Rachelle,1500.00,PHP,submitted,txn_")
+		})
+
+		Convey("CSV rows failing validation are reported by line and column", func() {
+			upload := "account_number,name,amount\n" +
+				"109453095653,Rachelle,10.00\n"
+			resp, out := post(srv, "/disbursement/batches/csv?method=instapay", "", upload)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+			var e ErrorResponse
+			So(json.Unmarshal([]byte(out), &e), ShouldBeNil)
+			So(e.Error.Fields, ShouldNotBeEmpty)
+			So(e.Error.Fields[0].Line, ShouldEqual, 2)
+			So(e.Error.Fields[0].Field, ShouldEqual, "address_line1")
+			So(svc.transfers, ShouldBeEmpty)
+		})
+	})
+}
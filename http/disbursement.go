@@ -1,11 +1,14 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	disbursement "github.com/jfpalngipang/fund-disbursement"
 	"github.com/pressly/chi"
@@ -16,16 +19,57 @@ type disbursementHandler struct {
 
 	baseUrl             url.URL
 	disbursementService disbursement.DisbursementService
+	bankDirectory       disbursement.BankDirectory
+	idempotencyStore    disbursement.IdempotencyStore
+	senderService       disbursement.SenderService
+	batchService        disbursement.BatchService
+	railSelector        disbursement.RailSelector
+	transactionStore    disbursement.TransactionStore
+	webhookService      disbursement.WebhookService
+	approvalService     disbursement.ApprovalService
+	ledgerService       disbursement.LedgerService
+	validator           *disbursement.Validator
+	defaultPurpose      string
+	requireScopes       bool
 }
 
 func newDisbursementHandler() *disbursementHandler {
 	h := &disbursementHandler{router: chi.NewRouter()}
-	h.router.Get("/instapay/banks", h.handleGetBanksForInstapay)
-	h.router.Get("/pesonet/banks", h.handleGetBanksForPesonet)
-	h.router.Post("/single/instapay", h.handleSingleDisbursementViaInstapay)
-	h.router.Post("/single/pesonet", h.handleSingleDisbursementViaPesonet)
-	h.router.Post("/single/ubptoubp", h.handleSingleDisbursementViaUbpToUbp)
-	h.router.Get("/status/{method}/{refID}", h.handleGetStatus)
+	banks := h.requireScope(disbursement.ScopeReadBanks)
+	status := h.requireScope(disbursement.ScopeReadStatus)
+	create := h.requireScope(disbursement.ScopeCreateTransfer)
+	approve := h.requireScope(disbursement.ScopeApprove)
+	webhooks := h.requireScope(disbursement.ScopeManageWebhooks)
+	readLedger := h.requireScope(disbursement.ScopeReadLedger)
+	writeLedger := h.requireScope(disbursement.ScopeWriteLedger)
+	h.router.With(banks).Get("/instapay/banks", h.handleGetBanksForInstapay)
+	h.router.With(banks).Get("/pesonet/banks", h.handleGetBanksForPesonet)
+	h.router.With(banks).Get("/banks", h.handleFindBanks)
+	h.router.With(create, h.idempotent).Post("/single", h.handleRoutedDisbursement)
+	h.router.With(create, h.idempotent).Post("/single/instapay", h.handleSingleDisbursementViaInstapay)
+	h.router.With(create, h.idempotent).Post("/single/pesonet", h.handleSingleDisbursementViaPesonet)
+	h.router.With(create, h.idempotent).Post("/single/ubptoubp", h.handleSingleDisbursementViaUbpToUbp)
+	h.router.With(status).Get("/status/{method}/{refID}", h.handleGetStatus)
+	h.router.With(banks).Get("/purposes", h.handleGetPurposes)
+	h.router.With(status).Get("/transactions", h.handleGetTransactions)
+	h.router.With(status).Get("/transactions/{id}", h.handleGetTransaction)
+	h.router.With(create, h.idempotent).Post("/batches", h.handleCreateBatch)
+	h.router.With(create, h.idempotent).Post("/batches/csv", h.handleUploadBatch)
+	h.router.With(status).Get("/batches/{id}", h.handleGetBatch)
+	h.router.With(status).Get("/batches/{id}/results.csv", h.handleGetBatchResults)
+	h.router.With(create).Post("/batches/{id}/cancel", h.handleCancelBatch)
+	h.router.With(status).Get("/approvals", h.handleGetApprovals)
+	h.router.With(status).Get("/approvals/{id}", h.handleGetApproval)
+	h.router.With(approve).Post("/approvals/{id}/approve", h.handleApprove)
+	h.router.With(approve).Post("/approvals/{id}/reject", h.handleReject)
+	h.router.With(webhooks).Post("/webhooks", h.handleCreateWebhook)
+	h.router.With(webhooks).Get("/webhooks", h.handleGetWebhooks)
+	h.router.With(webhooks).Delete("/webhooks/{id}", h.handleDeleteWebhook)
+	h.router.With(webhooks).Get("/webhooks/deliveries", h.handleGetWebhookDeliveries)
+	h.router.With(webhooks).Post("/webhooks/events/{id}/replay", h.handleReplayEvent)
+	h.router.With(readLedger).Get("/ledger/balances", h.handleGetLedgerBalances)
+	h.router.With(readLedger).Get("/ledger/entries", h.handleGetLedgerEntries)
+	h.router.With(writeLedger).Post("/ledger/deposits", h.handleCreateDeposit)
 	return h
 }
 
@@ -34,48 +78,277 @@ func (h *disbursementHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *disbursementHandler) handleGetBanksForInstapay(w http.ResponseWriter, r *http.Request) {
-	resp, _ := h.disbursementService.GetBanks("instapay")
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	h.handleGetBanksForMethod(w, r, disbursement.MethodInstapay)
 }
 
 func (h *disbursementHandler) handleGetBanksForPesonet(w http.ResponseWriter, r *http.Request) {
-	resp, _ := h.disbursementService.GetBanks("pesonet")
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	h.handleGetBanksForMethod(w, r, disbursement.MethodPesonet)
 }
 
-func (h *disbursementHandler) handleSingleDisbursementViaInstapay(w http.ResponseWriter, r *http.Request) {
-	var fundTransferRequestBody disbursement.Disbursement
-	b, _ := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	err := json.Unmarshal(b, &fundTransferRequestBody)
+// handleGetBanksForMethod lists the banks of a rail, from the bank directory
+// if there is one.
+func (h *disbursementHandler) handleGetBanksForMethod(w http.ResponseWriter, r *http.Request, method disbursement.Method) {
+	var resp []disbursement.Bank
+	var err error
+	if h.bankDirectory != nil {
+		resp, err = h.bankDirectory.Banks(r.Context(), method)
+	} else {
+		resp, err = h.disbursementService.GetBanks(r.Context(), method)
+	}
 	if err != nil {
-		log.Println("Error in unmarshaling the request body.")
+		Error(w, r, err)
+		return
 	}
-	resp, _ := h.disbursementService.TransferFunds("instapay", &fundTransferRequestBody)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	encodeJSON(w, http.StatusOK, resp)
+}
+
+func (h *disbursementHandler) handleSingleDisbursementViaInstapay(w http.ResponseWriter, r *http.Request) {
+	h.handleSingleDisbursement(w, r, disbursement.MethodInstapay)
 }
 
 func (h *disbursementHandler) handleSingleDisbursementViaPesonet(w http.ResponseWriter, r *http.Request) {
+	h.handleSingleDisbursement(w, r, disbursement.MethodPesonet)
+}
+
+func (h *disbursementHandler) handleSingleDisbursementViaUbpToUbp(w http.ResponseWriter, r *http.Request) {
+	h.handleSingleDisbursement(w, r, disbursement.MethodUBP)
+}
+
+func (h *disbursementHandler) handleSingleDisbursement(w http.ResponseWriter, r *http.Request, method disbursement.Method) {
 	var fundTransferRequestBody disbursement.Disbursement
-	b, _ := ioutil.ReadAll(r.Body)
+	if err := decodeDisbursement(r, &fundTransferRequestBody); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.prepare(r.Context(), method, &fundTransferRequestBody); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	h.transfer(w, r, method, &fundTransferRequestBody)
+}
+
+// routedDisbursementRequest is the body of POST /single: a disbursement
+// and how soon it must arrive.
+type routedDisbursementRequest struct {
+	Urgency string `json:"urgency"`
+	disbursement.Disbursement
+}
+
+// handleRoutedDisbursement sends a disbursement through the rail chosen by
+// the rail selector. The response records the chosen route.
+func (h *disbursementHandler) handleRoutedDisbursement(w http.ResponseWriter, r *http.Request) {
+	if h.railSelector == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	var req routedDisbursementRequest
+	if err := decodeDisbursement(r, &req); err != nil {
+		Error(w, r, err)
+		return
+	}
+	urgency, err := disbursement.ParseUrgency(req.Urgency)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	d := &req.Disbursement
+	if err := h.resolve(r.Context(), d); err != nil {
+		Error(w, r, err)
+		return
+	}
+	route, err := h.railSelector.SelectRail(r.Context(), d, urgency)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	d.Route = route
+	if err := h.validate(r.Context(), route.Method, d); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	h.transfer(w, r, route.Method, d)
+}
+
+// transfer sends the prepared d through method, unless it must be approved
+// first. A held transfer is answered with 202 Accepted and its approval.
+func (h *disbursementHandler) transfer(w http.ResponseWriter, r *http.Request, method disbursement.Method, d *disbursement.Disbursement) {
+	if h.approvalService != nil {
+		a, err := h.approvalService.HoldForApproval(r.Context(), method, d)
+		if err != nil {
+			Error(w, r, err)
+			return
+		} else if a != nil {
+			encodeJSON(w, http.StatusAccepted, a)
+			return
+		}
+	}
+
+	resp, err := h.disbursementService.TransferFunds(r.Context(), method, d)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, resp)
+}
+
+// decodeDisbursement decodes the request body into v, passing on the
+// *disbursement.ValidationError of malformed amounts.
+func decodeDisbursement(r *http.Request, v interface{}) error {
+	b, err := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
-	err := json.Unmarshal(b, &fundTransferRequestBody)
 	if err != nil {
-		log.Println("Error in unmarshaling the request body.")
+		return fmt.Errorf("%w: cannot read request body: %s", disbursement.ErrInvalid, err)
+	}
+	err = json.Unmarshal(b, v)
+	var verr *disbursement.ValidationError
+	if errors.As(err, &verr) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%w: cannot parse request body: %s", disbursement.ErrInvalid, err)
+	}
+	return nil
+}
+
+// prepare resolves the sender of d, fills in its default purpose and
+// validates it for method.
+func (h *disbursementHandler) prepare(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) error {
+	if err := h.resolve(ctx, d); err != nil {
+		return err
+	}
+	return h.validate(ctx, method, d)
+}
+
+// resolve resolves the sender of d and fills in its default purpose.
+func (h *disbursementHandler) resolve(ctx context.Context, d *disbursement.Disbursement) error {
+	if h.senderService != nil {
+		if err := disbursement.ResolveSender(ctx, h.senderService, d); err != nil {
+			return err
+		}
 	}
 
-	resp, _ := h.disbursementService.TransferFunds("pesonet", &fundTransferRequestBody)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	if d.Details.Purpose == "" {
+		if s := d.Sender; s != nil && s.DefaultPurpose != "" {
+			d.Details.Purpose = s.DefaultPurpose
+		} else {
+			d.Details.Purpose = h.defaultPurpose
+		}
+	}
+	return nil
+}
+
+func (h *disbursementHandler) validate(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) error {
+	if h.validator == nil {
+		return nil
+	}
+	return h.validator.Validate(ctx, method, d)
 }
 
 func (h *disbursementHandler) handleGetStatus(w http.ResponseWriter, r *http.Request) {
-	method := chi.URLParam(r, "method")
+	method, err := disbursement.ParseMethod(chi.URLParam(r, "method"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
 	refID := chi.URLParam(r, "refID")
-	resp, _ := h.disbursementService.GetStatus(method, refID)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	if err := h.checkTransferOwner(r.Context(), method, refID); err != nil {
+		Error(w, r, err)
+		return
+	}
+	resp, err := h.disbursementService.GetStatus(r.Context(), method, refID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, resp)
+}
+
+// checkTransferOwner returns ErrNotFound unless the caller requested the
+// transfer sent with reference id refID. Anonymous callers may look up
+// every transfer.
+func (h *disbursementHandler) checkTransferOwner(ctx context.Context, method disbursement.Method, refID string) error {
+	caller := disbursement.ClientIDFromContext(ctx)
+	if caller == "" {
+		return nil
+	} else if h.transactionStore == nil {
+		return disbursement.ErrNotFound
+	}
+	txs, err := h.transactionStore.FindTransactions(ctx, disbursement.TransactionFilter{
+		Method:      method,
+		SenderRefID: refID,
+		ClientID:    caller,
+		Limit:       1,
+	})
+	if err != nil {
+		return err
+	} else if len(txs) == 0 {
+		return disbursement.ErrNotFound
+	}
+	return nil
+}
+
+// handleGetPurposes lists the purpose codes transfers are validated against.
+// The list is empty if any code is accepted.
+func (h *disbursementHandler) handleGetPurposes(w http.ResponseWriter, r *http.Request) {
+	purposes := []disbursement.Purpose{}
+	if h.validator != nil && h.validator.Purposes != nil {
+		purposes = h.validator.Purposes
+	}
+	encodeJSON(w, http.StatusOK, purposes)
+}
+
+func (h *disbursementHandler) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
+	if h.transactionStore == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := disbursement.TransactionFilter{
+		Method:      disbursement.Method(q.Get("method")),
+		State:       disbursement.TransferState(q.Get("state")),
+		ReferenceID: q.Get("reference_id"),
+		SenderRefID: q.Get("sender_ref_id"),
+		// Clients see only their own transactions.
+		ClientID: disbursement.ClientIDFromContext(r.Context()),
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			Error(w, r, fmt.Errorf("%w: invalid limit %q", disbursement.ErrInvalid, limit))
+			return
+		}
+		filter.Limit = n
+	}
+
+	txs, err := h.transactionStore.FindTransactions(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	if txs == nil {
+		txs = []*disbursement.Transaction{}
+	}
+	encodeJSON(w, http.StatusOK, txs)
+}
+
+func (h *disbursementHandler) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	if h.transactionStore == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	tx, err := h.transactionStore.FindTransactionByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if !disbursement.CallerOwns(r.Context(), tx.ClientID) {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+	encodeJSON(w, http.StatusOK, tx)
 }
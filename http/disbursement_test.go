@@ -0,0 +1,314 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	"github.com/jfpalngipang/fund-disbursement/limits"
+	"github.com/jfpalngipang/fund-disbursement/payout"
+	"github.com/jfpalngipang/fund-disbursement/routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeDisbursementService records transfers instead of sending them.
+type fakeDisbursementService struct {
+	mu        sync.Mutex
+	transfers []*disbursement.Disbursement
+	banks     []disbursement.Bank
+}
+
+func (s *fakeDisbursementService) TransferFunds(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) (*disbursement.TransferResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transfers = append(s.transfers, d)
+	return &disbursement.TransferResult{
+		Method:      method,
+		ReferenceID: fmt.Sprintf("REF%d", len(s.transfers)),
+		State:       disbursement.TransferPending,
+	}, nil
+}
+
+func (s *fakeDisbursementService) GetBanks(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	return append([]disbursement.Bank{}, s.banks...), nil
+}
+
+func (s *fakeDisbursementService) GetStatus(ctx context.Context, method disbursement.Method, referenceID string) (*disbursement.TransferStatus, error) {
+	return &disbursement.TransferStatus{Method: method, ReferenceID: referenceID, State: disbursement.TransferPending}, nil
+}
+
+const testTransferBody = `{"receiver":{"accountNumber":"109453095653","name":"Rachelle","address":{"line1":"241 A.Del Mundo St","city":"Caloocan","province":"Metro Manila","zipCode":"1900","country":"PH"}},"transfer_details":{"amount":"30.00","currency":"PHP","receivingBank":"161408"}}`
+
+func post(srv *httptest.Server, path, key, body string) (*http.Response, string) {
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	So(err, ShouldBeNil)
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestSingleDisbursementValidation(t *testing.T) {
+	Convey("invalid disbursements are rejected before reaching the provider", t, func() {
+		svc := &fakeDisbursementService{}
+		s := NewServer()
+		s.DisbursementService = svc
+		s.Validator = disbursement.NewValidator(nil)
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		decode := func(body string) ErrorResponse {
+			var resp ErrorResponse
+			So(json.Unmarshal([]byte(body), &resp), ShouldBeNil)
+			return resp
+		}
+
+		Convey("malformed amounts", func() {
+			body := strings.Replace(testTransferBody, `"30.00"`, `"1e3"`, 1)
+			resp, out := post(srv, "/disbursement/single/instapay", "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			e := decode(out)
+			So(e.Error.Code, ShouldEqual, ECodeValidationFailed)
+			So(e.Error.Fields, ShouldHaveLength, 1)
+			So(e.Error.Fields[0].Field, ShouldEqual, "transfer_details.amount")
+		})
+
+		Convey("missing fields", func() {
+			resp, out := post(srv, "/disbursement/single/pesonet", "", `{"receiver":{},"transfer_details":{"amount":"10.00","currency":"PHP"}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(len(decode(out).Error.Fields), ShouldBeGreaterThan, 1)
+		})
+
+		Convey("unparseable bodies", func() {
+			resp, out := post(srv, "/disbursement/single/pesonet", "", `{"receiver":`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(decode(out).Error.Code, ShouldEqual, ECodeInvalidRequest)
+		})
+
+		So(svc.transfers, ShouldBeEmpty)
+	})
+}
+
+func TestSingleDisbursementSender(t *testing.T) {
+	Convey("disbursements are made on behalf of a sender profile", t, func() {
+		address := disbursement.Address{Line1: "Some Tower", City: "Some City", Province: "Metro Manila", Country: "PH"}
+		senders, err := inmem.NewSenderService([]*disbursement.Sender{
+			{ID: "corp", Name: "Palngipang Corp.", Address: address},
+			{ID: "payroll", Name: "Palngipang Payroll", Address: address, DefaultPurpose: "1004", Clients: []string{"hr"}},
+		}, "corp")
+		So(err, ShouldBeNil)
+
+		svc := &fakeDisbursementService{}
+		s := NewServer()
+		s.DisbursementService = svc
+		s.SenderService = senders
+		clientID := ""
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.router().ServeHTTP(w, r.WithContext(disbursement.NewContextWithClientID(r.Context(), clientID)))
+		}))
+		defer srv.Close()
+
+		withSender := func(id string) string {
+			return strings.Replace(testTransferBody, `{"receiver"`, `{"sender_id":"`+id+`","receiver"`, 1)
+		}
+
+		Convey("the default sender is used when none is named", func() {
+			resp, _ := post(srv, "/disbursement/single/instapay", "", testTransferBody)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(svc.transfers[0].Sender.Name, ShouldEqual, "Palngipang Corp.")
+			So(svc.transfers[0].Details.Purpose, ShouldEqual, disbursement.DefaultPurposeCode)
+		})
+
+		Convey("a named sender is used along with its default purpose", func() {
+			clientID = "hr"
+			resp, _ := post(srv, "/disbursement/single/instapay", "", withSender("payroll"))
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(svc.transfers[0].SenderID, ShouldEqual, "payroll")
+			So(svc.transfers[0].Details.Purpose, ShouldEqual, "1004")
+		})
+
+		Convey("clients may only use senders they are allowed to", func() {
+			clientID = "sales"
+			resp, out := post(srv, "/disbursement/single/instapay", "", withSender("payroll"))
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(out, ShouldContainSubstring, ECodeForbidden)
+			So(svc.transfers, ShouldBeEmpty)
+		})
+
+		Convey("unknown senders are rejected", func() {
+			resp, out := post(srv, "/disbursement/single/instapay", "", withSender("nobody"))
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(out, ShouldContainSubstring, `"sender_id"`)
+			So(svc.transfers, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSingleDisbursementLimits(t *testing.T) {
+	Convey("disbursements over a limit are refused with the limit that tripped", t, func() {
+		engine, err := limits.NewEngine([]disbursement.Limit{
+			{Scope: disbursement.LimitPerBeneficiary, Window: disbursement.LimitDay, MaxCount: 1},
+		})
+		So(err, ShouldBeNil)
+		svc := &fakeDisbursementService{}
+		payoutService := payout.NewService(svc, inmem.NewTransactionStore())
+		payoutService.Limits = engine
+
+		s := NewServer()
+		s.DisbursementService = payoutService
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		resp, _ := post(srv, "/disbursement/single/instapay", "", testTransferBody)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		resp, out := post(srv, "/disbursement/single/instapay", "", testTransferBody)
+		So(resp.StatusCode, ShouldEqual, http.StatusTooManyRequests)
+		var e ErrorResponse
+		So(json.Unmarshal([]byte(out), &e), ShouldBeNil)
+		So(e.Error.Code, ShouldEqual, ECodeLimitExceeded)
+		So(e.Error.Message, ShouldContainSubstring, "daily limit of 1 transfers per beneficiary")
+		So(e.Error.Limit.Key, ShouldEqual, "161408:109453095653")
+		So(e.Error.Limit.Count, ShouldEqual, 1)
+		So(svc.transfers, ShouldHaveLength, 1)
+	})
+}
+
+func TestGetStatus(t *testing.T) {
+	Convey("transfer status lookups", t, func() {
+		s := NewServer()
+		s.DisbursementService = &fakeDisbursementService{}
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		Convey("return the normalized status", func() {
+			resp, err := http.Get(srv.URL + "/disbursement/status/pesonet/REF1")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			var status disbursement.TransferStatus
+			So(json.NewDecoder(resp.Body).Decode(&status), ShouldBeNil)
+			So(status.Method, ShouldEqual, disbursement.MethodPesonet)
+			So(status.State, ShouldEqual, disbursement.TransferPending)
+		})
+
+		Convey("reject unknown rails", func() {
+			resp, err := http.Get(srv.URL + "/disbursement/status/swift/REF1")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+func TestTransactions(t *testing.T) {
+	Convey("recorded transactions can be looked up", t, func() {
+		store := inmem.NewTransactionStore()
+		s := NewServer()
+		s.DisbursementService = payout.NewService(&fakeDisbursementService{}, store)
+		s.TransactionStore = store
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		body := strings.Replace(testTransferBody, `{"receiver"`, `{"reference_id":"INV-7","receiver"`, 1)
+		_, out := post(srv, "/disbursement/single/instapay", "", body)
+		var result disbursement.TransferResult
+		So(json.Unmarshal([]byte(out), &result), ShouldBeNil)
+		So(result.TransactionID, ShouldNotBeEmpty)
+
+		get := func(path string, v interface{}) int {
+			resp, err := http.Get(srv.URL + path)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			json.NewDecoder(resp.Body).Decode(v)
+			return resp.StatusCode
+		}
+
+		var tx disbursement.Transaction
+		So(get("/disbursement/transactions/"+result.TransactionID, &tx), ShouldEqual, http.StatusOK)
+		So(tx.State, ShouldEqual, disbursement.TransferPending)
+		So(tx.Request.Details.Amount.String(), ShouldEqual, "30.00")
+
+		var txs []disbursement.Transaction
+		So(get("/disbursement/transactions?reference_id=INV-7", &txs), ShouldEqual, http.StatusOK)
+		So(txs, ShouldHaveLength, 1)
+
+		So(get("/disbursement/transactions/txn_missing", &tx), ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("clients see only their own transactions", t, func() {
+		store := inmem.NewTransactionStore()
+		s := NewServer()
+		s.DisbursementService = payout.NewService(&fakeDisbursementService{}, store)
+		s.TransactionStore = store
+		srv := httptest.NewServer(withTestClient(s.router()))
+		defer srv.Close()
+
+		_, out := postAs(srv, "acme", "/disbursement/single/instapay", testTransferBody)
+		var result disbursement.TransferResult
+		So(json.Unmarshal([]byte(out), &result), ShouldBeNil)
+
+		getAs := func(client, path string, v interface{}) int {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+			req.Header.Set(testClientHeader, client)
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			json.NewDecoder(resp.Body).Decode(v)
+			return resp.StatusCode
+		}
+
+		var tx disbursement.Transaction
+		So(getAs("acme", "/disbursement/transactions/"+result.TransactionID, &tx), ShouldEqual, http.StatusOK)
+		So(getAs("globex", "/disbursement/transactions/"+result.TransactionID, &tx), ShouldEqual, http.StatusNotFound)
+
+		var txs []disbursement.Transaction
+		So(getAs("acme", "/disbursement/transactions", &txs), ShouldEqual, http.StatusOK)
+		So(txs, ShouldHaveLength, 1)
+		So(getAs("globex", "/disbursement/transactions", &txs), ShouldEqual, http.StatusOK)
+		So(txs, ShouldBeEmpty)
+
+		var status disbursement.TransferStatus
+		So(getAs("acme", "/disbursement/status/instapay/"+result.ReferenceID, &status), ShouldEqual, http.StatusOK)
+		So(getAs("globex", "/disbursement/status/instapay/"+result.ReferenceID, &status), ShouldEqual, http.StatusNotFound)
+	})
+}
+
+func TestRoutedDisbursement(t *testing.T) {
+	Convey("disbursements without a rail are routed", t, func() {
+		svc := &fakeDisbursementService{}
+		s := NewServer()
+		s.DisbursementService = payout.NewService(svc, inmem.NewTransactionStore())
+		s.RailSelector = routing.NewSelector(routing.DefaultRules, nil)
+		s.Validator = disbursement.NewValidator(nil)
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		Convey("through the rail chosen for them", func() {
+			resp, out := post(srv, "/disbursement/single", "", strings.Replace(testTransferBody, `{"receiver"`, `{"urgency":"immediate","receiver"`, 1))
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			var result disbursement.TransferResult
+			So(json.Unmarshal([]byte(out), &result), ShouldBeNil)
+			So(result.Method, ShouldEqual, disbursement.MethodInstapay)
+			So(result.Route.Skipped, ShouldHaveLength, 1)
+			So(result.Route.Skipped[0].Method, ShouldEqual, disbursement.MethodPesonet)
+		})
+
+		Convey("unless the urgency is unknown", func() {
+			resp, _ := post(srv, "/disbursement/single", "", strings.Replace(testTransferBody, `{"receiver"`, `{"urgency":"asap","receiver"`, 1))
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(svc.transfers, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Error codes used in the error envelope. They are part of the API and must
+// not change once published.
+const (
+	ECodeInvalidRequest      = "invalid_request"
+	ECodeValidationFailed    = "validation_failed"
+	ECodeInsufficientFunds   = "insufficient_funds"
+	ECodeUnauthenticated     = "unauthenticated"
+	ECodeForbidden           = "forbidden"
+	ECodeLimitExceeded       = "limit_exceeded"
+	ECodeNotFound            = "not_found"
+	ECodeConflict            = "conflict"
+	ECodeProviderAuth        = "provider_auth_failed"
+	ECodeProviderUnavailable = "provider_unavailable"
+	ECodeIdempotencyInUse    = "idempotency_key_in_use"
+	ECodeIdempotencyReused   = "idempotency_key_reused"
+	ECodeInternal            = "internal_error"
+)
+
+// ErrorResponse is the envelope written for every failed request.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	ProviderCode  string `json:"provider_code,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	Fields []disbursement.FieldError `json:"fields,omitempty"`
+
+	// Limit describes the limit a refused transfer would have exceeded.
+	Limit *disbursement.LimitError `json:"limit,omitempty"`
+}
+
+// Error writes err to w as an ErrorResponse with a status code derived from
+// its disbursement error category.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		logError(r, err)
+	}
+
+	body := ErrorBody{Code: code, Message: err.Error()}
+	if status == http.StatusInternalServerError {
+		body.Message = "internal error"
+	}
+
+	var perr disbursement.ProviderError
+	if errors.As(err, &perr) {
+		body.ProviderCode = perr.ErrorCode()
+		body.CorrelationID = perr.ErrorCorrelationID()
+	}
+
+	var verr *disbursement.ValidationError
+	if errors.As(err, &verr) {
+		body.Fields = verr.Errors
+	}
+
+	var lerr *disbursement.LimitError
+	if errors.As(err, &lerr) {
+		body.Limit = lerr
+	}
+
+	encodeJSON(w, status, ErrorResponse{Error: body})
+}
+
+func errorStatus(err error) (int, string) {
+	var verr *disbursement.ValidationError
+	if errors.As(err, &verr) {
+		return http.StatusBadRequest, ECodeValidationFailed
+	}
+
+	switch {
+	case errors.Is(err, disbursement.ErrIdempotencyKeyInUse):
+		return http.StatusConflict, ECodeIdempotencyInUse
+	case errors.Is(err, disbursement.ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity, ECodeIdempotencyReused
+	case errors.Is(err, disbursement.ErrInvalid):
+		return http.StatusBadRequest, ECodeInvalidRequest
+	case errors.Is(err, disbursement.ErrNotFound):
+		return http.StatusNotFound, ECodeNotFound
+	case errors.Is(err, disbursement.ErrConflict):
+		return http.StatusConflict, ECodeConflict
+	case errors.Is(err, disbursement.ErrUnauthenticated):
+		return http.StatusUnauthorized, ECodeUnauthenticated
+	case errors.Is(err, disbursement.ErrForbidden):
+		return http.StatusForbidden, ECodeForbidden
+	case errors.Is(err, disbursement.ErrLimitExceeded):
+		return http.StatusTooManyRequests, ECodeLimitExceeded
+	case errors.Is(err, disbursement.ErrFunding):
+		return http.StatusUnprocessableEntity, ECodeInsufficientFunds
+	case errors.Is(err, disbursement.ErrUnauthorized):
+		return http.StatusBadGateway, ECodeProviderAuth
+	case errors.Is(err, disbursement.ErrRetryable):
+		return http.StatusServiceUnavailable, ECodeProviderUnavailable
+	}
+	return http.StatusInternalServerError, ECodeInternal
+}
+
+// logError logs an error that the client cannot act on.
+func logError(r *http.Request, err error) {
+	log.Printf("http error: %s %s: %s", r.Method, r.URL.Path, err)
+}
+
+// encodeJSON writes v to w as JSON with the given status code.
+func encodeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("http: cannot encode response: %s", err)
+	}
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// IdempotencyKeyHeader carries the client's idempotency key. The key may also
+// be sent as the idem_key field of the request body.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLen bounds the length of client-supplied keys.
+const maxIdempotencyKeyLen = 255
+
+// idempotent replays the stored response for requests that repeat an
+// idempotency key, and stores the first response for new keys. Requests
+// without a key are passed through unchanged.
+func (h *disbursementHandler) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.idempotencyStore == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+		r.Body.Close()
+		if err != nil {
+			Error(w, r, fmt.Errorf("%w: cannot read request body: %s", disbursement.ErrInvalid, err))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+		key, err := idempotencyKey(r, b)
+		if err != nil {
+			Error(w, r, err)
+			return
+		} else if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = clientIdempotencyKey(r.Context(), key)
+
+		hash := requestHash(r, b)
+		rec, err := h.idempotencyStore.Reserve(r.Context(), key, hash)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+		if rec != nil {
+			replay(w, r, rec, hash)
+			return
+		}
+
+		// The outcome is stored even if the client has gone away, since that
+		// is exactly the client that will retry.
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				h.idempotencyStore.Release(context.Background(), key)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+
+		err = h.idempotencyStore.Complete(context.Background(), key, &disbursement.IdempotentResponse{
+			StatusCode:  rw.status,
+			ContentType: rw.Header().Get("Content-Type"),
+			Body:        rw.body.Bytes(),
+		})
+		if err != nil {
+			logError(r, fmt.Errorf("cannot store idempotent response: %w", err))
+		}
+		completed = true
+	})
+}
+
+func replay(w http.ResponseWriter, r *http.Request, rec *disbursement.IdempotencyRecord, hash string) {
+	if rec.RequestHash != hash {
+		Error(w, r, disbursement.ErrIdempotencyKeyReused)
+		return
+	} else if rec.Response == nil {
+		Error(w, r, disbursement.ErrIdempotencyKeyInUse)
+		return
+	}
+
+	w.Header().Set("Content-Type", rec.Response.ContentType)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.Response.StatusCode)
+	w.Write(rec.Response.Body)
+}
+
+// idempotencyKey returns the key from the request header or body.
+func idempotencyKey(r *http.Request, body []byte) (string, error) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+
+	var payload struct {
+		IdemKey string `json:"idem_key"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.IdemKey != "" {
+		if key != "" && key != payload.IdemKey {
+			return "", fmt.Errorf("%w: %s header and idem_key differ", disbursement.ErrInvalid, IdempotencyKeyHeader)
+		}
+		key = payload.IdemKey
+	}
+
+	if len(key) > maxIdempotencyKeyLen {
+		return "", fmt.Errorf("%w: idempotency key longer than %d characters", disbursement.ErrInvalid, maxIdempotencyKeyLen)
+	}
+	return key, nil
+}
+
+// clientIdempotencyKey scopes key to the calling client, so that clients
+// choosing the same key never see each other's responses. The client id is
+// prefixed with its length, so that no pair of client id and key can stand
+// for another. Anonymous keys are left as they are.
+func clientIdempotencyKey(ctx context.Context, key string) string {
+	client := disbursement.ClientIDFromContext(ctx)
+	if client == "" {
+		return key
+	}
+	return strconv.Itoa(len(client)) + ":" + client + ":" + key
+}
+
+// requestHash fingerprints the request so a reused key with a different
+// payload can be detected.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies everything written to the response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jfpalngipang/fund-disbursement/filestore"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIdempotency(t *testing.T) {
+	Convey("repeated requests with an idempotency key", t, func() {
+		svc := &fakeDisbursementService{}
+		s := NewServer()
+		s.DisbursementService = svc
+		s.IdempotencyStore = inmem.NewIdempotencyStore()
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		resp, first := post(srv, "/disbursement/single/instapay", "key-1", testTransferBody)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		Convey("replay the first response without transferring again", func() {
+			resp, second := post(srv, "/disbursement/single/instapay", "key-1", testTransferBody)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Idempotent-Replayed"), ShouldEqual, "true")
+			So(second, ShouldEqual, first)
+			So(svc.transfers, ShouldHaveLength, 1)
+		})
+
+		Convey("are rejected when the payload differs", func() {
+			body := strings.Replace(testTransferBody, "30.00", "3000.00", 1)
+			resp, out := post(srv, "/disbursement/single/instapay", "key-1", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnprocessableEntity)
+			So(out, ShouldContainSubstring, ECodeIdempotencyReused)
+			So(svc.transfers, ShouldHaveLength, 1)
+		})
+
+		Convey("accept the key in the request body", func() {
+			body := strings.Replace(testTransferBody, `{"receiver"`, `{"idem_key":"key-2","receiver"`, 1)
+			post(srv, "/disbursement/single/instapay", "", body)
+			resp, _ := post(srv, "/disbursement/single/instapay", "", body)
+			So(resp.Header.Get("Idempotent-Replayed"), ShouldEqual, "true")
+			So(svc.transfers, ShouldHaveLength, 2)
+		})
+
+		Convey("are scoped to the client that sent them", func() {
+			client := httptest.NewServer(withTestClient(s.router()))
+			defer client.Close()
+			send := func(name string) (*http.Response, string) {
+				req, _ := http.NewRequest(http.MethodPost, client.URL+"/disbursement/single/instapay", strings.NewReader(testTransferBody))
+				req.Header.Set(IdempotencyKeyHeader, "key-1")
+				req.Header.Set(testClientHeader, name)
+				resp, err := http.DefaultClient.Do(req)
+				So(err, ShouldBeNil)
+				defer resp.Body.Close()
+				b, _ := ioutil.ReadAll(resp.Body)
+				return resp, string(b)
+			}
+
+			resp, acme := send("acme")
+			So(resp.Header.Get("Idempotent-Replayed"), ShouldBeEmpty)
+			resp, globex := send("globex")
+			So(resp.Header.Get("Idempotent-Replayed"), ShouldBeEmpty)
+			So(globex, ShouldNotEqual, acme)
+			resp, again := send("acme")
+			So(resp.Header.Get("Idempotent-Replayed"), ShouldEqual, "true")
+			So(again, ShouldEqual, acme)
+			So(svc.transfers, ShouldHaveLength, 3)
+		})
+
+		Convey("are refused if the body is too large to hold", func() {
+			resp, _ := post(srv, "/disbursement/single/instapay", "key-3", strings.Repeat(" ", maxUploadSize+1))
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(svc.transfers, ShouldHaveLength, 1)
+		})
+
+		Convey("are not deduplicated without a key", func() {
+			post(srv, "/disbursement/single/instapay", "", testTransferBody)
+			post(srv, "/disbursement/single/instapay", "", testTransferBody)
+			So(svc.transfers, ShouldHaveLength, 3)
+		})
+	})
+
+	Convey("the durable store replays responses after a restart", t, func() {
+		path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+		svc := &fakeDisbursementService{}
+
+		open := func() *httptest.Server {
+			store, err := filestore.OpenIdempotencyStore(path)
+			So(err, ShouldBeNil)
+			s := NewServer()
+			s.DisbursementService = svc
+			s.IdempotencyStore = store
+			return httptest.NewServer(s.router())
+		}
+
+		srv := open()
+		_, first := post(srv, "/disbursement/single/pesonet", "key-1", testTransferBody)
+		srv.Close()
+
+		srv = open()
+		defer srv.Close()
+		resp, second := post(srv, "/disbursement/single/pesonet", "key-1", testTransferBody)
+		So(resp.Header.Get("Idempotent-Replayed"), ShouldEqual, "true")
+		So(second, ShouldEqual, first)
+		So(svc.transfers, ShouldHaveLength, 1)
+	})
+}
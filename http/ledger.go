@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// ledgerBalancesResponse is the body returned by GET /ledger/balances.
+type ledgerBalancesResponse struct {
+	Currency  string                       `json:"currency"`
+	Available disbursement.Money           `json:"available"`
+	Balances  []disbursement.LedgerBalance `json:"balances"`
+}
+
+// createDepositRequest is the body of POST /ledger/deposits.
+type createDepositRequest struct {
+	Amount disbursement.Money `json:"amount"`
+	Memo   string             `json:"memo"`
+}
+
+// handleGetLedgerBalances returns the funds available in the partner account
+// and the balance of every ledger account, in the currency given by the
+// currency query parameter or PHP.
+func (h *disbursementHandler) handleGetLedgerBalances(w http.ResponseWriter, r *http.Request) {
+	if h.ledgerService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		currency = "PHP"
+	}
+	available, err := h.ledgerService.Available(r.Context(), currency)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	balances, err := h.ledgerService.Balances(r.Context(), currency)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, ledgerBalancesResponse{Currency: currency, Available: available, Balances: balances})
+}
+
+func (h *disbursementHandler) handleGetLedgerEntries(w http.ResponseWriter, r *http.Request) {
+	if h.ledgerService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	filter := disbursement.LedgerFilter{
+		Account:       disbursement.LedgerAccount(r.URL.Query().Get("account")),
+		TransactionID: r.URL.Query().Get("transaction_id"),
+	}
+	entries, err := h.ledgerService.FindLedgerEntries(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []*disbursement.LedgerEntry{}
+	}
+	encodeJSON(w, http.StatusOK, entries)
+}
+
+// handleCreateDeposit records funds added to the partner account.
+func (h *disbursementHandler) handleCreateDeposit(w http.ResponseWriter, r *http.Request) {
+	if h.ledgerService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	var req createDepositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, fmt.Errorf("%w: cannot parse request body: %s", disbursement.ErrInvalid, err))
+		return
+	}
+
+	e, err := h.ledgerService.Deposit(r.Context(), req.Amount, req.Memo)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusCreated, e)
+}
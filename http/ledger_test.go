@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	"github.com/jfpalngipang/fund-disbursement/ledger"
+	"github.com/jfpalngipang/fund-disbursement/payout"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLedger(t *testing.T) {
+	Convey("given a server posting transfers to a ledger", t, func() {
+		book, err := ledger.NewBook(context.Background(), inmem.NewLedgerStore(), ledger.Config{
+			Fees: map[disbursement.Method]disbursement.Money{disbursement.MethodInstapay: {Minor: 1500, Currency: "PHP"}},
+		})
+		So(err, ShouldBeNil)
+		transactions := &ledger.TransactionStore{TransactionStore: inmem.NewTransactionStore(), Book: book}
+
+		s := NewServer()
+		s.DisbursementService = payout.NewService(&fakeDisbursementService{}, transactions)
+		s.TransactionStore = transactions
+		s.LedgerService = book
+		s.Validator = disbursement.NewValidator(nil)
+		srv := httptest.NewServer(s.router())
+		defer srv.Close()
+
+		get := func(path string, v interface{}) {
+			resp, err := http.Get(srv.URL + path)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			b, _ := ioutil.ReadAll(resp.Body)
+			So(json.Unmarshal(b, v), ShouldBeNil)
+		}
+
+		resp, _ := postAs(srv, "acme", "/disbursement/ledger/deposits", `{"amount":{"amount":"1000.00","currency":"PHP"},"memo":"top-up"}`)
+		So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+
+		Convey("balances show what transfers have committed", func() {
+			resp, _ := postAs(srv, "acme", "/disbursement/single/instapay", testTransferBody)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			var balances ledgerBalancesResponse
+			get("/disbursement/ledger/balances", &balances)
+			So(balances.Currency, ShouldEqual, "PHP")
+			So(balances.Available.String(), ShouldEqual, "955.00")
+			So(balances.Balances, ShouldHaveLength, len(disbursement.LedgerAccounts))
+
+			var entries []*disbursement.LedgerEntry
+			get("/disbursement/ledger/entries?account=holds", &entries)
+			So(entries, ShouldHaveLength, 1)
+			So(entries[0].Kind, ShouldEqual, disbursement.LedgerHold)
+			So(entries[0].TransactionID, ShouldNotBeEmpty)
+		})
+
+		Convey("deposits must have a positive amount", func() {
+			resp, _ := postAs(srv, "acme", "/disbursement/ledger/deposits", `{"amount":{"amount":"0.00","currency":"PHP"}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
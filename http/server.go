@@ -14,13 +14,35 @@ type Server struct {
 
 	// Services
 	DisbursementService disbursement.DisbursementService
+	BankDirectory       disbursement.BankDirectory
+	IdempotencyStore    disbursement.IdempotencyStore
+	SenderService       disbursement.SenderService
+	BatchService        disbursement.BatchService
+	RailSelector        disbursement.RailSelector
+	TransactionStore    disbursement.TransactionStore
+	WebhookService      disbursement.WebhookService
+	ApprovalService     disbursement.ApprovalService
+	LedgerService       disbursement.LedgerService
+	Validator           *disbursement.Validator
+
+	// Authenticators identify the API client of each request to
+	// /disbursement, which must then hold the scope of the route. If empty,
+	// requests are anonymous and unrestricted.
+	Authenticators []Authenticator
+
+	// AuditLog, if set, records every request to /disbursement.
+	AuditLog disbursement.AuditLog
+
 	// Server options
 	Addr string
+	// DefaultPurpose is the purpose code of transfers that omit one and
+	// whose sender has no default of its own.
+	DefaultPurpose string
 	// Host string
 }
 
 func NewServer() *Server {
-	return &Server{}
+	return &Server{DefaultPurpose: disbursement.DefaultPurposeCode}
 }
 
 // Open opens the server.
@@ -51,7 +73,7 @@ func (s *Server) router() http.Handler {
 	r := chi.NewRouter()
 
 	r.Route("/", func(r chi.Router) {
-		r.Mount("/disbursement", s.disbursementHandler())
+		r.With(s.auditCalls, s.authenticate).Mount("/disbursement", s.disbursementHandler())
 		r.Get("/health", healthHandler)
 		r.Get("/readiness", readinessHandler)
 	})
@@ -71,6 +93,18 @@ func (s *Server) disbursementHandler() *disbursementHandler {
 	h := newDisbursementHandler()
 	h.baseUrl = s.URL()
 	h.disbursementService = s.DisbursementService
+	h.bankDirectory = s.BankDirectory
+	h.idempotencyStore = s.IdempotencyStore
+	h.senderService = s.SenderService
+	h.batchService = s.BatchService
+	h.railSelector = s.RailSelector
+	h.transactionStore = s.TransactionStore
+	h.webhookService = s.WebhookService
+	h.approvalService = s.ApprovalService
+	h.ledgerService = s.LedgerService
+	h.validator = s.Validator
+	h.defaultPurpose = s.DefaultPurpose
+	h.requireScopes = len(s.Authenticators) > 0
 	return h
 
 }
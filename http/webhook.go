@@ -0,0 +1,122 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/pressly/chi"
+)
+
+// createWebhookRequest is the body of POST /webhooks.
+type createWebhookRequest struct {
+	URL    string                   `json:"url"`
+	Events []disbursement.EventType `json:"events"`
+}
+
+// replayEventRequest is the optional body of POST /webhooks/events/{id}/replay.
+type replayEventRequest struct {
+	EndpointID string `json:"endpoint_id"`
+}
+
+func (h *disbursementHandler) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhookService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, fmt.Errorf("%w: cannot parse request body: %s", disbursement.ErrInvalid, err))
+		return
+	}
+
+	endpoint := &disbursement.WebhookEndpoint{URL: req.URL, Events: req.Events}
+	if err := h.webhookService.CreateWebhookEndpoint(r.Context(), endpoint); err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusCreated, endpoint)
+}
+
+func (h *disbursementHandler) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if h.webhookService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	endpoints, err := h.webhookService.FindWebhookEndpoints(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, endpoints)
+}
+
+func (h *disbursementHandler) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhookService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhookEndpoint(r.Context(), chi.URLParam(r, "id")); err != nil {
+		Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetWebhookDeliveries lists deliveries. Pass state=dead for the
+// dead-letter list.
+func (h *disbursementHandler) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if h.webhookService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := disbursement.WebhookDeliveryFilter{
+		EndpointID: q.Get("endpoint_id"),
+		EventID:    q.Get("event_id"),
+		State:      disbursement.WebhookDeliveryState(q.Get("state")),
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			Error(w, r, fmt.Errorf("%w: invalid limit %q", disbursement.ErrInvalid, limit))
+			return
+		}
+		filter.Limit = n
+	}
+
+	deliveries, err := h.webhookService.FindWebhookDeliveries(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *disbursementHandler) handleReplayEvent(w http.ResponseWriter, r *http.Request) {
+	if h.webhookService == nil {
+		Error(w, r, disbursement.ErrNotFound)
+		return
+	}
+
+	var req replayEventRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, r, fmt.Errorf("%w: cannot parse request body: %s", disbursement.ErrInvalid, err))
+			return
+		}
+	}
+
+	deliveries, err := h.webhookService.ReplayEvent(r.Context(), chi.URLParam(r, "id"), req.EndpointID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	encodeJSON(w, http.StatusAccepted, deliveries)
+}
@@ -0,0 +1,53 @@
+package disbursement
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// IdempotencyTTL is how long the response to an idempotency key is kept.
+const IdempotencyTTL = 24 * time.Hour
+
+var (
+	// ErrIdempotencyKeyInUse means a request with the same idempotency key
+	// is still being processed.
+	ErrIdempotencyKeyInUse = errors.New("idempotency key in use")
+
+	// ErrIdempotencyKeyReused means the idempotency key was already used for
+	// a request with a different payload.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+)
+
+// IdempotencyRecord is the state of a request made with an idempotency key.
+// Response is nil while the first request is still in flight.
+type IdempotencyRecord struct {
+	Key         string              `json:"key"`
+	RequestHash string              `json:"requestHash"`
+	Response    *IdempotentResponse `json:"response,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+}
+
+// IdempotentResponse is the response replayed for a repeated request.
+type IdempotentResponse struct {
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
+// IdempotencyStore persists the first response to each idempotency key so
+// that a retried request is answered without repeating the transfer.
+// Records older than IdempotencyTTL may be discarded.
+type IdempotencyStore interface {
+	// Reserve claims key for a request whose payload hashes to requestHash.
+	// If the key is already claimed the existing record is returned and the
+	// store is left unchanged; otherwise it returns nil.
+	Reserve(ctx context.Context, key, requestHash string) (*IdempotencyRecord, error)
+
+	// Complete stores the response for a reserved key.
+	Complete(ctx context.Context, key string, resp *IdempotentResponse) error
+
+	// Release drops a reservation that did not produce a response so the key
+	// can be used again.
+	Release(ctx context.Context, key string) error
+}
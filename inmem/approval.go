@@ -0,0 +1,87 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// ApprovalStore is an in-memory disbursement.ApprovalStore. Approvals do not
+// survive a restart.
+type ApprovalStore struct {
+	mu        sync.Mutex
+	approvals map[string]*disbursement.Approval
+	order     []string
+
+	now func() time.Time
+}
+
+func NewApprovalStore() *ApprovalStore {
+	return &ApprovalStore{
+		approvals: make(map[string]*disbursement.Approval),
+		now:       time.Now,
+	}
+}
+
+func (s *ApprovalStore) CreateApproval(ctx context.Context, a *disbursement.Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID == "" {
+		a.ID = disbursement.NewApprovalID()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = s.now()
+	}
+	if a.UpdatedAt.IsZero() {
+		a.UpdatedAt = a.CreatedAt
+	}
+	s.approvals[a.ID] = copyApproval(a)
+	s.order = append(s.order, a.ID)
+	return nil
+}
+
+func (s *ApprovalStore) UpdateApproval(ctx context.Context, a *disbursement.Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.approvals[a.ID]; !ok {
+		return disbursement.ErrNotFound
+	}
+	s.approvals[a.ID] = copyApproval(a)
+	return nil
+}
+
+func (s *ApprovalStore) FindApprovalByID(ctx context.Context, id string) (*disbursement.Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.approvals[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return copyApproval(a), nil
+}
+
+func (s *ApprovalStore) FindApprovals(ctx context.Context, filter disbursement.ApprovalFilter) ([]*disbursement.Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var approvals []*disbursement.Approval
+	for _, id := range s.order {
+		if a := s.approvals[id]; filter.Match(a) {
+			approvals = append(approvals, copyApproval(a))
+		}
+	}
+	return approvals, nil
+}
+
+// copyApproval returns a copy of a that shares no history with it. The
+// disbursement is never modified once held, so it is shared.
+func copyApproval(a *disbursement.Approval) *disbursement.Approval {
+	cp := *a
+	cp.History = append([]disbursement.ApprovalEvent(nil), a.History...)
+	return &cp
+}
@@ -0,0 +1,52 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// AuditLog is an in-memory disbursement.AuditLog. Entries do not survive a
+// restart.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []*disbursement.AuditEntry
+
+	now func() time.Time
+}
+
+func NewAuditLog() *AuditLog {
+	return &AuditLog{now: time.Now}
+}
+
+func (l *AuditLog) Record(ctx context.Context, e *disbursement.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.At.IsZero() {
+		e.At = l.now()
+	}
+	var prev *disbursement.AuditEntry
+	if n := len(l.entries); n > 0 {
+		prev = l.entries[n-1]
+	}
+	e.Chain(prev)
+	cp := *e
+	l.entries = append(l.entries, &cp)
+	return nil
+}
+
+// Entries returns the recorded entries, oldest first.
+func (l *AuditLog) Entries() []*disbursement.AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]*disbursement.AuditEntry, len(l.entries))
+	for i, e := range l.entries {
+		cp := *e
+		entries[i] = &cp
+	}
+	return entries
+}
@@ -0,0 +1,108 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// BatchStore is an in-memory disbursement.BatchStore. Batches do not survive
+// a restart.
+type BatchStore struct {
+	mu      sync.Mutex
+	batches map[string]*disbursement.Batch
+	order   []string
+
+	now func() time.Time
+}
+
+func NewBatchStore() *BatchStore {
+	return &BatchStore{
+		batches: make(map[string]*disbursement.Batch),
+		now:     time.Now,
+	}
+}
+
+func (s *BatchStore) CreateBatch(ctx context.Context, b *disbursement.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.ID == "" {
+		b.ID = disbursement.NewBatchID()
+	}
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = s.now()
+	}
+	if b.UpdatedAt.IsZero() {
+		b.UpdatedAt = b.CreatedAt
+	}
+	s.batches[b.ID] = copyBatch(b)
+	s.order = append(s.order, b.ID)
+	return nil
+}
+
+func (s *BatchStore) UpdateBatch(ctx context.Context, b *disbursement.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.batches[b.ID]
+	if !ok {
+		return disbursement.ErrNotFound
+	}
+	stored.State = b.State
+	stored.UpdatedAt = b.UpdatedAt
+	stored.CompletedAt = b.CompletedAt
+	return nil
+}
+
+func (s *BatchStore) UpdateBatchItem(ctx context.Context, batchID string, item *disbursement.BatchItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.batches[batchID]
+	if !ok || item.Index < 0 || item.Index >= len(stored.Items) {
+		return disbursement.ErrNotFound
+	}
+	cp := *item
+	stored.Items[item.Index] = &cp
+	return nil
+}
+
+func (s *BatchStore) FindBatchByID(ctx context.Context, id string) (*disbursement.Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.batches[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return copyBatch(b), nil
+}
+
+func (s *BatchStore) FindBatches(ctx context.Context, state disbursement.BatchState) ([]*disbursement.Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var batches []*disbursement.Batch
+	for _, id := range s.order {
+		if b := s.batches[id]; state == "" || b.State == state {
+			batches = append(batches, copyBatch(b))
+		}
+	}
+	return batches, nil
+}
+
+// copyBatch returns a copy of b whose items can be changed without affecting
+// b. Requests and results are never modified once recorded, so they are
+// shared.
+func copyBatch(b *disbursement.Batch) *disbursement.Batch {
+	cp := *b
+	cp.Items = make([]*disbursement.BatchItem, len(b.Items))
+	for i, item := range b.Items {
+		itemCp := *item
+		cp.Items[i] = &itemCp
+	}
+	return &cp
+}
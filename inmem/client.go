@@ -0,0 +1,75 @@
+package inmem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// ClientService is a disbursement.ClientService over a fixed set of API
+// clients, usually loaded from a configuration file.
+type ClientService struct {
+	clients map[string]*disbursement.Client
+	keys    map[string]*disbursement.Client // by API key hash
+}
+
+// NewClientService returns a ClientService for clients.
+func NewClientService(clients []*disbursement.Client) (*ClientService, error) {
+	s := &ClientService{
+		clients: make(map[string]*disbursement.Client, len(clients)),
+		keys:    make(map[string]*disbursement.Client),
+	}
+	for _, c := range clients {
+		if err := disbursement.ValidateClient(c); err != nil {
+			return nil, fmt.Errorf("client %q: %w", c.ID, err)
+		} else if _, ok := s.clients[c.ID]; ok {
+			return nil, fmt.Errorf("client %q: duplicate id", c.ID)
+		}
+		s.clients[c.ID] = c
+		for _, h := range c.APIKeyHashes {
+			if other, ok := s.keys[h]; ok {
+				return nil, fmt.Errorf("client %q: API key shared with client %q", c.ID, other.ID)
+			}
+			s.keys[h] = c
+		}
+	}
+	return s, nil
+}
+
+// clientConfig is the layout of a client configuration file.
+type clientConfig struct {
+	Clients []*disbursement.Client `json:"clients"`
+}
+
+// LoadClientService returns a ClientService for the API clients in the JSON
+// configuration file at path.
+func LoadClientService(path string) (*ClientService, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config clientConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewClientService(config.Clients)
+}
+
+func (s *ClientService) FindClientByID(ctx context.Context, id string) (*disbursement.Client, error) {
+	c, ok := s.clients[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: client %q", disbursement.ErrNotFound, id)
+	}
+	return c, nil
+}
+
+func (s *ClientService) FindClientByAPIKey(ctx context.Context, key string) (*disbursement.Client, error) {
+	c, ok := s.keys[disbursement.HashAPIKey(key)]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", disbursement.ErrNotFound)
+	}
+	return c, nil
+}
@@ -0,0 +1,73 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// IdempotencyStore is an in-memory disbursement.IdempotencyStore. Records do
+// not survive a restart and are not shared between replicas.
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*disbursement.IdempotencyRecord
+
+	now func() time.Time
+}
+
+func NewIdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{
+		records: make(map[string]*disbursement.IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, key, requestHash string) (*disbursement.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if rec, ok := s.records[key]; ok && now.Sub(rec.CreatedAt) < disbursement.IdempotencyTTL {
+		cp := *rec
+		return &cp, nil
+	}
+
+	s.records[key] = &disbursement.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+	}
+	s.purge(now)
+	return nil, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, resp *disbursement.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok {
+		rec.Response = resp
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && rec.Response == nil {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// purge removes expired records. The caller must hold s.mu.
+func (s *IdempotencyStore) purge(now time.Time) {
+	for key, rec := range s.records {
+		if now.Sub(rec.CreatedAt) >= disbursement.IdempotencyTTL {
+			delete(s.records, key)
+		}
+	}
+}
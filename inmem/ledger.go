@@ -0,0 +1,56 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// LedgerStore is an in-memory disbursement.LedgerStore. Entries do not
+// survive a restart.
+type LedgerStore struct {
+	mu      sync.Mutex
+	entries []*disbursement.LedgerEntry
+
+	now func() time.Time
+}
+
+func NewLedgerStore() *LedgerStore {
+	return &LedgerStore{now: time.Now}
+}
+
+func (s *LedgerStore) CreateLedgerEntry(ctx context.Context, e *disbursement.LedgerEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.ID == "" {
+		e.ID = disbursement.NewLedgerEntryID()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = s.now()
+	}
+	s.entries = append(s.entries, copyLedgerEntry(e))
+	return nil
+}
+
+func (s *LedgerStore) FindLedgerEntries(ctx context.Context, filter disbursement.LedgerFilter) ([]*disbursement.LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []*disbursement.LedgerEntry
+	for _, e := range s.entries {
+		if filter.Match(e) {
+			entries = append(entries, copyLedgerEntry(e))
+		}
+	}
+	return entries, nil
+}
+
+// copyLedgerEntry returns a copy of e that shares no lines with it.
+func copyLedgerEntry(e *disbursement.LedgerEntry) *disbursement.LedgerEntry {
+	cp := *e
+	cp.Lines = append([]disbursement.LedgerLine(nil), e.Lines...)
+	return &cp
+}
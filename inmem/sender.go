@@ -0,0 +1,70 @@
+package inmem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// SenderService is a disbursement.SenderService over a fixed set of sender
+// profiles, usually loaded from a configuration file.
+type SenderService struct {
+	senders       map[string]*disbursement.Sender
+	defaultSender string
+}
+
+// NewSenderService returns a SenderService for senders. Disbursements without
+// a sender id use the sender with id defaultSender; if it is empty they must
+// name a sender.
+func NewSenderService(senders []*disbursement.Sender, defaultSender string) (*SenderService, error) {
+	s := &SenderService{
+		senders:       make(map[string]*disbursement.Sender, len(senders)),
+		defaultSender: defaultSender,
+	}
+	for _, sender := range senders {
+		if err := disbursement.ValidateSender(sender); err != nil {
+			return nil, fmt.Errorf("sender %q: %w", sender.ID, err)
+		} else if _, ok := s.senders[sender.ID]; ok {
+			return nil, fmt.Errorf("sender %q: duplicate id", sender.ID)
+		}
+		s.senders[sender.ID] = sender
+	}
+	if _, ok := s.senders[defaultSender]; defaultSender != "" && !ok {
+		return nil, fmt.Errorf("default sender %q: %w", defaultSender, disbursement.ErrSenderNotFound)
+	}
+	return s, nil
+}
+
+// senderConfig is the layout of a sender configuration file.
+type senderConfig struct {
+	DefaultSender string                 `json:"defaultSender"`
+	Senders       []*disbursement.Sender `json:"senders"`
+}
+
+// LoadSenderService returns a SenderService for the sender profiles in the
+// JSON configuration file at path.
+func LoadSenderService(path string) (*SenderService, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config senderConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewSenderService(config.Senders, config.DefaultSender)
+}
+
+func (s *SenderService) FindSender(ctx context.Context, id string) (*disbursement.Sender, error) {
+	if id == "" {
+		id = s.defaultSender
+	}
+	sender, ok := s.senders[id]
+	if !ok || id == "" {
+		return nil, disbursement.ErrSenderNotFound
+	}
+	return sender, nil
+}
@@ -0,0 +1,106 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// WebhookStore is an in-memory disbursement.WebhookStore. Endpoints, events
+// and pending deliveries are lost on restart.
+type WebhookStore struct {
+	mu         sync.Mutex
+	endpoints  []*disbursement.WebhookEndpoint
+	events     map[string]*disbursement.Event
+	deliveries []*disbursement.WebhookDelivery
+}
+
+func NewWebhookStore() *WebhookStore {
+	return &WebhookStore{events: make(map[string]*disbursement.Event)}
+}
+
+func (s *WebhookStore) CreateWebhookEndpoint(ctx context.Context, e *disbursement.WebhookEndpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *e
+	s.endpoints = append(s.endpoints, &cp)
+	return nil
+}
+
+func (s *WebhookStore) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.endpoints {
+		if e.ID == id {
+			s.endpoints = append(s.endpoints[:i], s.endpoints[i+1:]...)
+			return nil
+		}
+	}
+	return disbursement.ErrNotFound
+}
+
+func (s *WebhookStore) FindWebhookEndpoints(ctx context.Context) ([]*disbursement.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := make([]*disbursement.WebhookEndpoint, len(s.endpoints))
+	for i, e := range s.endpoints {
+		cp := *e
+		endpoints[i] = &cp
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookStore) CreateEvent(ctx context.Context, e *disbursement.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[e.ID] = e
+	return nil
+}
+
+func (s *WebhookStore) FindEventByID(ctx context.Context, id string) (*disbursement.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return nil, disbursement.ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *WebhookStore) CreateWebhookDelivery(ctx context.Context, d *disbursement.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *d
+	s.deliveries = append(s.deliveries, &cp)
+	return nil
+}
+
+func (s *WebhookStore) UpdateWebhookDelivery(ctx context.Context, d *disbursement.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.deliveries {
+		if existing.ID == d.ID {
+			cp := *d
+			s.deliveries[i] = &cp
+			return nil
+		}
+	}
+	return disbursement.ErrNotFound
+}
+
+func (s *WebhookStore) FindWebhookDeliveries(ctx context.Context, filter disbursement.WebhookDeliveryFilter) ([]*disbursement.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deliveries []*disbursement.WebhookDelivery
+	for _, d := range s.deliveries {
+		if filter.Limit > 0 && len(deliveries) == filter.Limit {
+			break
+		}
+		if filter.Match(d) {
+			cp := *d
+			deliveries = append(deliveries, &cp)
+		}
+	}
+	return deliveries, nil
+}
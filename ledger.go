@@ -0,0 +1,182 @@
+package disbursement
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LedgerAccount is an account of the double-entry ledger of the provider
+// partner account.
+type LedgerAccount string
+
+const (
+	// LedgerPartner holds the funds of the partner account that are not
+	// committed to any transfer: what is available to pay out.
+	LedgerPartner LedgerAccount = "partner"
+
+	// LedgerHolds holds the funds committed to transfers in flight,
+	// including their fees.
+	LedgerHolds LedgerAccount = "holds"
+
+	// LedgerPayouts counts the funds paid out to receivers.
+	LedgerPayouts LedgerAccount = "payouts"
+
+	// LedgerFees counts the fees charged by the provider.
+	LedgerFees LedgerAccount = "fees"
+
+	// LedgerFunding counts the funds deposited into the partner account.
+	LedgerFunding LedgerAccount = "funding"
+)
+
+// LedgerAccounts lists every account of the ledger.
+var LedgerAccounts = []LedgerAccount{LedgerPartner, LedgerHolds, LedgerPayouts, LedgerFees, LedgerFunding}
+
+// CreditNormal reports whether credits increase the balance of a. Only the
+// funding account, the source of every deposit, is credit-normal.
+func (a LedgerAccount) CreditNormal() bool {
+	return a == LedgerFunding
+}
+
+// LedgerSide is the side of an account a line is posted to.
+type LedgerSide string
+
+const (
+	LedgerDebit  LedgerSide = "debit"
+	LedgerCredit LedgerSide = "credit"
+)
+
+// LedgerLine moves Amount into or out of an account.
+type LedgerLine struct {
+	Account LedgerAccount `json:"account"`
+	Side    LedgerSide    `json:"side"`
+	Amount  Money         `json:"amount"`
+}
+
+// LedgerEntryKind is why an entry was posted.
+type LedgerEntryKind string
+
+const (
+	// LedgerHold commits the amount and fee of a new transfer.
+	LedgerHold LedgerEntryKind = "hold"
+
+	// LedgerSettle turns the hold of a credited transfer into a payout
+	// and a fee.
+	LedgerSettle LedgerEntryKind = "settle"
+
+	// LedgerRelease returns the hold of a failed transfer.
+	LedgerRelease LedgerEntryKind = "release"
+
+	// LedgerReturn returns the amount of a settled transfer sent back by
+	// the receiving bank. The fee is not refunded.
+	LedgerReturn LedgerEntryKind = "return"
+
+	// LedgerDeposit records funds added to the partner account.
+	LedgerDeposit LedgerEntryKind = "deposit"
+)
+
+// LedgerEntry is a balanced set of lines posted together. Entries are never
+// changed once posted; mistakes are corrected by further entries.
+type LedgerEntry struct {
+	ID   string          `json:"id"`
+	Kind LedgerEntryKind `json:"kind"`
+
+	// TransactionID is the transfer the entry is for, if any.
+	TransactionID string `json:"transaction_id,omitempty"`
+
+	Memo      string       `json:"memo,omitempty"`
+	Lines     []LedgerLine `json:"lines"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func NewLedgerEntryID() string {
+	return newID("led_")
+}
+
+// Validate checks that e has positive amounts on known accounts and that its
+// debits equal its credits in every currency.
+func (e *LedgerEntry) Validate() error {
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("%w: ledger entry needs at least two lines", ErrInvalid)
+	}
+	net := make(map[string]int64)
+	for _, l := range e.Lines {
+		if !l.Amount.IsPositive() {
+			return fmt.Errorf("%w: ledger line amounts must be positive", ErrInvalid)
+		}
+		switch l.Account {
+		case LedgerPartner, LedgerHolds, LedgerPayouts, LedgerFees, LedgerFunding:
+		default:
+			return fmt.Errorf("%w: unknown ledger account %q", ErrInvalid, l.Account)
+		}
+		switch l.Side {
+		case LedgerDebit:
+			net[l.Amount.Currency] += l.Amount.Minor
+		case LedgerCredit:
+			net[l.Amount.Currency] -= l.Amount.Minor
+		default:
+			return fmt.Errorf("%w: unknown ledger side %q", ErrInvalid, l.Side)
+		}
+	}
+	for currency, n := range net {
+		if n != 0 {
+			return fmt.Errorf("%w: ledger entry does not balance in %s", ErrInvalid, currency)
+		}
+	}
+	return nil
+}
+
+// LedgerBalance is the balance of an account in one currency, positive on
+// the account's normal side.
+type LedgerBalance struct {
+	Account LedgerAccount `json:"account"`
+	Balance Money         `json:"balance"`
+}
+
+// LedgerFilter selects ledger entries. Empty fields match every entry.
+type LedgerFilter struct {
+	Account       LedgerAccount
+	TransactionID string
+}
+
+// Match reports whether e satisfies the filter.
+func (f LedgerFilter) Match(e *LedgerEntry) bool {
+	if f.TransactionID != "" && e.TransactionID != f.TransactionID {
+		return false
+	}
+	if f.Account == "" {
+		return true
+	}
+	for _, l := range e.Lines {
+		if l.Account == f.Account {
+			return true
+		}
+	}
+	return false
+}
+
+// LedgerStore persists ledger entries.
+type LedgerStore interface {
+	// CreateLedgerEntry stores a new entry, assigning its ID if it is
+	// empty.
+	CreateLedgerEntry(ctx context.Context, e *LedgerEntry) error
+
+	// FindLedgerEntries returns the entries matching filter, oldest first.
+	FindLedgerEntries(ctx context.Context, filter LedgerFilter) ([]*LedgerEntry, error)
+}
+
+// LedgerService reports on and adds to the ledger of the partner account.
+type LedgerService interface {
+	// Balances returns the balance of every account in currency.
+	Balances(ctx context.Context, currency string) ([]LedgerBalance, error)
+
+	// Available returns the funds of the partner account in currency not
+	// committed to any transfer.
+	Available(ctx context.Context, currency string) (Money, error)
+
+	// FindLedgerEntries returns the entries matching filter, oldest first.
+	FindLedgerEntries(ctx context.Context, filter LedgerFilter) ([]*LedgerEntry, error)
+
+	// Deposit records funds added to the partner account.
+	Deposit(ctx context.Context, amount Money, memo string) (*LedgerEntry, error)
+}
@@ -0,0 +1,362 @@
+// Package ledger keeps a double-entry ledger of the provider partner account.
+// Transfers hold their amount and fee, which become a payout and a fee once
+// credited, and deposits fund the account.
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Config sets the fees and funding policy of a Book.
+type Config struct {
+	// Fees is the provider's fee per transfer, by rail. It is taken from
+	// the routing rules, not the configuration file.
+	Fees map[disbursement.Method]disbursement.Money `json:"-"`
+
+	// RequireFunds refuses transfers the available funds cannot cover. A
+	// new ledger has none until a deposit is recorded.
+	RequireFunds bool `json:"require_funds"`
+}
+
+// LoadConfig reads the JSON configuration file at path. RequireFunds
+// defaults to true.
+func LoadConfig(path string) (Config, error) {
+	c := Config{RequireFunds: true}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Book is a disbursement.LedgerService posting to a LedgerStore. Balances
+// are kept in memory, rebuilt from the store when the Book is created.
+type Book struct {
+	store  disbursement.LedgerStore
+	config Config
+
+	mu sync.Mutex
+	// balances are debits less credits, by currency and account.
+	balances map[string]map[disbursement.LedgerAccount]int64
+	// holds are the open or settled holds, by transaction id.
+	holds map[string]*hold
+}
+
+// hold is what a transfer committed, and how far it got.
+type hold struct {
+	amount, fee disbursement.Money
+	stage       disbursement.LedgerEntryKind
+}
+
+// NewBook returns a Book over the entries already in store.
+func NewBook(ctx context.Context, store disbursement.LedgerStore, config Config) (*Book, error) {
+	for method, fee := range config.Fees {
+		if fee.Minor < 0 {
+			return nil, fmt.Errorf("%w: negative %s fee", disbursement.ErrInvalid, method)
+		}
+	}
+	b := &Book{
+		store:    store,
+		config:   config,
+		balances: make(map[string]map[disbursement.LedgerAccount]int64),
+		holds:    make(map[string]*hold),
+	}
+	entries, err := store.FindLedgerEntries(ctx, disbursement.LedgerFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		b.apply(e)
+	}
+	return b, nil
+}
+
+// Hold commits the amount and fee of tx. If the book requires funds, it
+// returns an error matching disbursement.ErrFunding when they are not
+// available. Holding a transaction twice does nothing.
+func (b *Book) Hold(ctx context.Context, tx *disbursement.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.holds[tx.ID] != nil || tx.Request == nil {
+		return nil
+	}
+	amount := tx.Request.Details.Amount
+	fee := disbursement.Money{Currency: amount.Currency}
+	if f, ok := b.config.Fees[tx.Method]; ok && f.Currency == amount.Currency {
+		fee = f
+	}
+	total := amount.Minor + fee.Minor
+	if available := b.balances[amount.Currency][disbursement.LedgerPartner]; b.config.RequireFunds && total > available {
+		return fmt.Errorf("%w: %s %s needed including fees, %s %s available", disbursement.ErrFunding,
+			disbursement.Money{Minor: total, Currency: amount.Currency}, amount.Currency,
+			disbursement.Money{Minor: available, Currency: amount.Currency}, amount.Currency)
+	}
+
+	return b.post(ctx, &disbursement.LedgerEntry{
+		Kind:          disbursement.LedgerHold,
+		TransactionID: tx.ID,
+		Lines: lines(
+			line(disbursement.LedgerHolds, disbursement.LedgerDebit, amount),
+			line(disbursement.LedgerHolds, disbursement.LedgerDebit, fee),
+			line(disbursement.LedgerPartner, disbursement.LedgerCredit, disbursement.Money{Minor: total, Currency: amount.Currency}),
+		),
+	})
+}
+
+// Settle turns the hold of a credited transaction into a payout and a fee.
+func (b *Book) Settle(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.holds[id]
+	if h == nil || h.stage != disbursement.LedgerHold {
+		return nil
+	}
+	return b.post(ctx, &disbursement.LedgerEntry{
+		Kind:          disbursement.LedgerSettle,
+		TransactionID: id,
+		Lines: lines(
+			line(disbursement.LedgerPayouts, disbursement.LedgerDebit, h.amount),
+			line(disbursement.LedgerFees, disbursement.LedgerDebit, h.fee),
+			line(disbursement.LedgerHolds, disbursement.LedgerCredit, h.amount),
+			line(disbursement.LedgerHolds, disbursement.LedgerCredit, h.fee),
+		),
+	})
+}
+
+// Release returns the hold of a transaction that failed before it was
+// credited.
+func (b *Book) Release(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.holds[id]
+	if h == nil || h.stage != disbursement.LedgerHold {
+		return nil
+	}
+	return b.post(ctx, &disbursement.LedgerEntry{
+		Kind:          disbursement.LedgerRelease,
+		TransactionID: id,
+		Lines: lines(
+			line(disbursement.LedgerPartner, disbursement.LedgerDebit, h.amount),
+			line(disbursement.LedgerPartner, disbursement.LedgerDebit, h.fee),
+			line(disbursement.LedgerHolds, disbursement.LedgerCredit, h.amount),
+			line(disbursement.LedgerHolds, disbursement.LedgerCredit, h.fee),
+		),
+	})
+}
+
+// Return puts the amount of a returned transaction back in the partner
+// account. The fee stays charged. A transaction returned before it was
+// credited has its hold released instead.
+func (b *Book) Return(ctx context.Context, id string) error {
+	b.mu.Lock()
+	h := b.holds[id]
+	if h != nil && h.stage == disbursement.LedgerHold {
+		b.mu.Unlock()
+		return b.Release(ctx, id)
+	}
+	defer b.mu.Unlock()
+
+	if h == nil || h.stage != disbursement.LedgerSettle {
+		return nil
+	}
+	return b.post(ctx, &disbursement.LedgerEntry{
+		Kind:          disbursement.LedgerReturn,
+		TransactionID: id,
+		Lines: lines(
+			line(disbursement.LedgerPartner, disbursement.LedgerDebit, h.amount),
+			line(disbursement.LedgerPayouts, disbursement.LedgerCredit, h.amount),
+		),
+	})
+}
+
+func (b *Book) Deposit(ctx context.Context, amount disbursement.Money, memo string) (*disbursement.LedgerEntry, error) {
+	if !amount.IsPositive() {
+		return nil, &disbursement.ValidationError{Errors: []disbursement.FieldError{{Field: "amount", Message: "must be positive"}}}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := &disbursement.LedgerEntry{
+		Kind: disbursement.LedgerDeposit,
+		Memo: memo,
+		Lines: lines(
+			line(disbursement.LedgerPartner, disbursement.LedgerDebit, amount),
+			line(disbursement.LedgerFunding, disbursement.LedgerCredit, amount),
+		),
+	}
+	if err := b.post(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (b *Book) Balances(ctx context.Context, currency string) ([]disbursement.LedgerBalance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	balances := make([]disbursement.LedgerBalance, len(disbursement.LedgerAccounts))
+	for i, a := range disbursement.LedgerAccounts {
+		balances[i] = disbursement.LedgerBalance{Account: a, Balance: b.balance(currency, a)}
+	}
+	return balances, nil
+}
+
+func (b *Book) Available(ctx context.Context, currency string) (disbursement.Money, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.balance(currency, disbursement.LedgerPartner), nil
+}
+
+func (b *Book) FindLedgerEntries(ctx context.Context, filter disbursement.LedgerFilter) ([]*disbursement.LedgerEntry, error) {
+	return b.store.FindLedgerEntries(ctx, filter)
+}
+
+// balance returns the balance of account a in currency, positive on its
+// normal side. b.mu must be held.
+func (b *Book) balance(currency string, a disbursement.LedgerAccount) disbursement.Money {
+	n := b.balances[currency][a]
+	if a.CreditNormal() {
+		n = -n
+	}
+	return disbursement.Money{Minor: n, Currency: currency}
+}
+
+// post validates and stores e, then applies it. b.mu must be held.
+func (b *Book) post(ctx context.Context, e *disbursement.LedgerEntry) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if err := b.store.CreateLedgerEntry(ctx, e); err != nil {
+		return err
+	}
+	b.apply(e)
+	return nil
+}
+
+// apply adds e to the balances and holds. b.mu must be held, except while
+// the Book is being created.
+func (b *Book) apply(e *disbursement.LedgerEntry) {
+	for _, l := range e.Lines {
+		accounts := b.balances[l.Amount.Currency]
+		if accounts == nil {
+			accounts = make(map[disbursement.LedgerAccount]int64)
+			b.balances[l.Amount.Currency] = accounts
+		}
+		if l.Side == disbursement.LedgerDebit {
+			accounts[l.Account] += l.Amount.Minor
+		} else {
+			accounts[l.Account] -= l.Amount.Minor
+		}
+	}
+
+	if e.TransactionID == "" {
+		return
+	}
+	switch e.Kind {
+	case disbursement.LedgerHold:
+		h := &hold{stage: e.Kind}
+		for _, l := range e.Lines {
+			if l.Account != disbursement.LedgerHolds {
+				continue
+			} else if h.amount.IsZero() {
+				h.amount = l.Amount
+			} else {
+				h.fee = l.Amount
+			}
+		}
+		h.fee.Currency = h.amount.Currency
+		b.holds[e.TransactionID] = h
+	case disbursement.LedgerSettle, disbursement.LedgerRelease, disbursement.LedgerReturn:
+		if h := b.holds[e.TransactionID]; h != nil {
+			h.stage = e.Kind
+		}
+	}
+}
+
+// line returns a line posting amount to side of account.
+func line(account disbursement.LedgerAccount, side disbursement.LedgerSide, amount disbursement.Money) disbursement.LedgerLine {
+	return disbursement.LedgerLine{Account: account, Side: side, Amount: amount}
+}
+
+// lines returns ls without the lines of zero amounts, such as the fee of a
+// rail that charges none.
+func lines(ls ...disbursement.LedgerLine) []disbursement.LedgerLine {
+	var out []disbursement.LedgerLine
+	for _, l := range ls {
+		if !l.Amount.IsZero() {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// TransactionStore posts to Book as the transactions it stores are created
+// and change state. A transaction the book cannot fund is not created.
+type TransactionStore struct {
+	disbursement.TransactionStore
+	Book *Book
+
+	// mu serializes updates, so that each change of state is posted once.
+	mu sync.Mutex
+}
+
+func (s *TransactionStore) CreateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	if tx.ID == "" {
+		tx.ID = disbursement.NewTransactionID()
+	}
+	if err := s.Book.Hold(ctx, tx); err != nil {
+		return err
+	}
+	if err := s.TransactionStore.CreateTransaction(ctx, tx); err != nil {
+		s.post(tx.ID, s.Book.Release)
+		return err
+	}
+	return nil
+}
+
+func (s *TransactionStore) UpdateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, err := s.TransactionStore.FindTransactionByID(ctx, tx.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.TransactionStore.UpdateTransaction(ctx, tx); err != nil {
+		return err
+	}
+	if prev.State == tx.State {
+		return nil
+	}
+	switch tx.State {
+	case disbursement.TransferCredited:
+		s.post(tx.ID, s.Book.Settle)
+	case disbursement.TransferFailed:
+		s.post(tx.ID, s.Book.Release)
+	case disbursement.TransferReturned:
+		s.post(tx.ID, s.Book.Return)
+	}
+	return nil
+}
+
+// post calls fn for transaction id, logging failures rather than failing
+// the change being posted.
+func (s *TransactionStore) post(id string, fn func(context.Context, string) error) {
+	if err := fn(context.Background(), id); err != nil {
+		log.Printf("ledger: cannot post transaction %s: %s", id, err)
+	}
+}
@@ -0,0 +1,147 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func php(amount int64) disbursement.Money {
+	return disbursement.Money{Minor: amount * 100, Currency: "PHP"}
+}
+
+func transfer(method disbursement.Method, amount int64) *disbursement.Transaction {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	tx := &disbursement.Transaction{
+		Method:    method,
+		Request:   &disbursement.Disbursement{Details: disbursement.Details{Amount: php(amount)}},
+		CreatedAt: now,
+	}
+	tx.SetState(disbursement.TransferPending, "", now)
+	return tx
+}
+
+// balances returns the PHP balance of every account, by account.
+func balances(b *Book) map[disbursement.LedgerAccount]string {
+	bs, err := b.Balances(context.Background(), "PHP")
+	So(err, ShouldBeNil)
+	got := make(map[disbursement.LedgerAccount]string)
+	for _, b := range bs {
+		got[b.Account] = b.Balance.String()
+	}
+	return got
+}
+
+func TestBook(t *testing.T) {
+	Convey("given a funded book charging fees on instapay", t, func() {
+		ctx := context.Background()
+		entries := inmem.NewLedgerStore()
+		config := Config{Fees: map[disbursement.Method]disbursement.Money{disbursement.MethodInstapay: php(15)}}
+		book, err := NewBook(ctx, entries, config)
+		So(err, ShouldBeNil)
+		_, err = book.Deposit(ctx, php(10000), "opening balance")
+		So(err, ShouldBeNil)
+		store := &TransactionStore{TransactionStore: inmem.NewTransactionStore(), Book: book}
+
+		Convey("new transfers hold their amount and fee", func() {
+			tx := transfer(disbursement.MethodInstapay, 1000)
+			So(store.CreateTransaction(ctx, tx), ShouldBeNil)
+
+			So(balances(book), ShouldResemble, map[disbursement.LedgerAccount]string{
+				"partner": "8985.00", "holds": "1015.00", "payouts": "0.00", "fees": "0.00", "funding": "10000.00",
+			})
+			available, err := book.Available(ctx, "PHP")
+			So(err, ShouldBeNil)
+			So(available.String(), ShouldEqual, "8985.00")
+
+			Convey("which become a payout and a fee once credited", func() {
+				tx.SetState(disbursement.TransferCredited, "CREDITED", tx.CreatedAt.Add(time.Minute))
+				So(store.UpdateTransaction(ctx, tx), ShouldBeNil)
+				So(store.UpdateTransaction(ctx, tx), ShouldBeNil)
+
+				So(balances(book), ShouldResemble, map[disbursement.LedgerAccount]string{
+					"partner": "8985.00", "holds": "0.00", "payouts": "1000.00", "fees": "15.00", "funding": "10000.00",
+				})
+
+				Convey("and return the amount but not the fee if returned", func() {
+					tx.SetState(disbursement.TransferReturned, "RETURNED", tx.CreatedAt.Add(time.Hour))
+					So(store.UpdateTransaction(ctx, tx), ShouldBeNil)
+
+					So(balances(book), ShouldResemble, map[disbursement.LedgerAccount]string{
+						"partner": "9985.00", "holds": "0.00", "payouts": "0.00", "fees": "15.00", "funding": "10000.00",
+					})
+				})
+			})
+
+			Convey("which are released if the transfer fails", func() {
+				tx.SetState(disbursement.TransferFailed, "FAILED", tx.CreatedAt.Add(time.Minute))
+				So(store.UpdateTransaction(ctx, tx), ShouldBeNil)
+
+				So(balances(book)["partner"], ShouldEqual, "10000.00")
+				So(balances(book)["holds"], ShouldEqual, "0.00")
+
+				history, err := book.FindLedgerEntries(ctx, disbursement.LedgerFilter{TransactionID: tx.ID})
+				So(err, ShouldBeNil)
+				So(history, ShouldHaveLength, 2)
+				So(history[0].Kind, ShouldEqual, disbursement.LedgerHold)
+				So(history[1].Kind, ShouldEqual, disbursement.LedgerRelease)
+			})
+		})
+
+		Convey("rails without a fee hold the amount only", func() {
+			tx := transfer(disbursement.MethodUBP, 1000)
+			So(store.CreateTransaction(ctx, tx), ShouldBeNil)
+
+			history, err := book.FindLedgerEntries(ctx, disbursement.LedgerFilter{TransactionID: tx.ID})
+			So(err, ShouldBeNil)
+			So(history[0].Lines, ShouldHaveLength, 2)
+			So(balances(book)["holds"], ShouldEqual, "1000.00")
+		})
+
+		Convey("transfers beyond the available funds are refused if funds are required", func() {
+			book.config.RequireFunds = true
+			So(store.CreateTransaction(ctx, transfer(disbursement.MethodInstapay, 9985)), ShouldBeNil)
+
+			tx := transfer(disbursement.MethodInstapay, 1)
+			err := store.CreateTransaction(ctx, tx)
+			So(errors.Is(err, disbursement.ErrFunding), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "16.00 PHP needed")
+			_, err = store.FindTransactionByID(ctx, tx.ID)
+			So(errors.Is(err, disbursement.ErrNotFound), ShouldBeTrue)
+		})
+
+		Convey("deposits must be positive", func() {
+			_, err := book.Deposit(ctx, php(0), "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("balances and holds are rebuilt after a restart", func() {
+			tx := transfer(disbursement.MethodInstapay, 1000)
+			So(store.CreateTransaction(ctx, tx), ShouldBeNil)
+
+			restarted, err := NewBook(ctx, entries, config)
+			So(err, ShouldBeNil)
+			So(balances(restarted), ShouldResemble, balances(book))
+
+			So(restarted.Settle(ctx, tx.ID), ShouldBeNil)
+			So(balances(restarted)["fees"], ShouldEqual, "15.00")
+		})
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	Convey("the development configuration loads", t, func() {
+		config, err := LoadConfig("../ledger.dev.json")
+		So(err, ShouldBeNil)
+		So(config.Fees, ShouldBeNil)
+		So(config.RequireFunds, ShouldBeTrue)
+
+		_, err = NewBook(context.Background(), inmem.NewLedgerStore(), config)
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,107 @@
+package disbursement
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LimitScope is what a limit is counted per.
+type LimitScope string
+
+const (
+	LimitPerClient      LimitScope = "client"
+	LimitPerSender      LimitScope = "sender"
+	LimitPerBeneficiary LimitScope = "beneficiary"
+	LimitPerMethod      LimitScope = "method"
+)
+
+// LimitWindow is the rolling period a limit is counted over.
+type LimitWindow string
+
+const (
+	LimitHour  LimitWindow = "hour"
+	LimitDay   LimitWindow = "day"
+	LimitMonth LimitWindow = "month"
+)
+
+// Duration returns the length of w. A month is taken to be 30 days.
+func (w LimitWindow) Duration() time.Duration {
+	switch w {
+	case LimitHour:
+		return time.Hour
+	case LimitDay:
+		return 24 * time.Hour
+	case LimitMonth:
+		return 30 * 24 * time.Hour
+	}
+	return 0
+}
+
+func (w LimitWindow) adjective() string {
+	switch w {
+	case LimitHour:
+		return "hourly"
+	case LimitDay:
+		return "daily"
+	case LimitMonth:
+		return "monthly"
+	}
+	return string(w)
+}
+
+// Limit caps the number or amount of transfers over a rolling window.
+type Limit struct {
+	Scope LimitScope `json:"scope"`
+
+	// Key restricts the limit to one client, sender, beneficiary or rail.
+	// Empty applies it to each of them separately.
+	Key string `json:"key,omitempty"`
+
+	Window LimitWindow `json:"window"`
+
+	// MaxCount and MaxAmount cap the transfers in the window. Zero and
+	// nil mean no cap.
+	MaxCount  int    `json:"max_count,omitempty"`
+	MaxAmount *Money `json:"max_amount,omitempty"`
+}
+
+// LimitError is returned for a transfer that would exceed a limit. It
+// matches ErrLimitExceeded.
+type LimitError struct {
+	Limit Limit `json:"limit"`
+
+	// Key is the client, sender, beneficiary or rail that reached the
+	// limit.
+	Key string `json:"key"`
+
+	// Count and Amount are what was already used in the window.
+	Count  int   `json:"count"`
+	Amount Money `json:"amount"`
+}
+
+func (e *LimitError) Error() string {
+	l := e.Limit
+	if l.MaxAmount != nil && (l.MaxCount == 0 || e.Count < l.MaxCount) {
+		return fmt.Sprintf("%s: %s limit of %s %s per %s reached for %s (%s %s already sent)",
+			ErrLimitExceeded, l.Window.adjective(), l.MaxAmount, l.MaxAmount.Currency, l.Scope, e.Key, e.Amount, e.Amount.Currency)
+	}
+	return fmt.Sprintf("%s: %s limit of %d transfers per %s reached for %s",
+		ErrLimitExceeded, l.Window.adjective(), l.MaxCount, l.Scope, e.Key)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// Limiter enforces limits on transfers before they are sent. Each transfer
+// is reserved under the id of its transaction.
+type Limiter interface {
+	// Reserve counts the transfer against every limit that applies to it,
+	// or returns a *LimitError without counting it if it would exceed one.
+	Reserve(ctx context.Context, id string, method Method, d *Disbursement) error
+
+	// Release stops counting a transfer, because it failed or was
+	// returned. Unknown ids are ignored.
+	Release(ctx context.Context, id string) error
+}
@@ -0,0 +1,186 @@
+// Package limits caps how much is paid out over rolling windows of an hour,
+// a day or a month, per API client, sender, beneficiary account and rail.
+// Transfers are reserved against the limits before they are sent, under a
+// single lock, so concurrent requests cannot together exceed a limit.
+package limits
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// config is the layout of a limits configuration file.
+type config struct {
+	Limits []disbursement.Limit `json:"limits"`
+}
+
+// LoadLimits reads the limits in the JSON configuration file at path.
+func LoadLimits(path string) ([]disbursement.Limit, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c.Limits, nil
+}
+
+// Engine is a disbursement.Limiter keeping the transfers of the longest
+// window in memory. Restore rebuilds them from recorded transactions after a
+// restart.
+type Engine struct {
+	limits []disbursement.Limit
+	span   time.Duration // longest window
+
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	now func() time.Time
+}
+
+// entry is a transfer counted against the limits.
+type entry struct {
+	at     time.Time
+	keys   map[disbursement.LimitScope]string
+	amount disbursement.Money
+}
+
+// NewEngine returns an Engine enforcing limits.
+func NewEngine(limits []disbursement.Limit) (*Engine, error) {
+	e := &Engine{limits: limits, entries: make(map[string]*entry), now: time.Now}
+	for i, l := range limits {
+		switch l.Scope {
+		case disbursement.LimitPerClient, disbursement.LimitPerSender, disbursement.LimitPerBeneficiary, disbursement.LimitPerMethod:
+		default:
+			return nil, fmt.Errorf("%w: limit %d has unknown scope %q", disbursement.ErrInvalid, i, l.Scope)
+		}
+		d := l.Window.Duration()
+		if d == 0 {
+			return nil, fmt.Errorf("%w: limit %d has unknown window %q", disbursement.ErrInvalid, i, l.Window)
+		} else if l.MaxCount <= 0 && l.MaxAmount == nil {
+			return nil, fmt.Errorf("%w: limit %d caps neither count nor amount", disbursement.ErrInvalid, i)
+		}
+		if d > e.span {
+			e.span = d
+		}
+	}
+	return e, nil
+}
+
+// Restore counts the transactions recorded in store within the longest
+// window, other than failed or returned ones.
+func (e *Engine) Restore(ctx context.Context, store disbursement.TransactionStore) error {
+	txs, err := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	since := e.now().Add(-e.span)
+	for _, tx := range txs {
+		if tx.Request == nil || tx.CreatedAt.Before(since) ||
+			tx.State == disbursement.TransferFailed || tx.State == disbursement.TransferReturned {
+			continue
+		}
+		e.entries[tx.ID] = &entry{
+			at:     tx.CreatedAt,
+			keys:   keys(tx.ClientID, tx.Method, tx.Request),
+			amount: tx.Request.Details.Amount,
+		}
+	}
+	return nil
+}
+
+// Reserve counts the transfer against every limit applying to it. Limits of
+// a scope the transfer has no value for, such as the client limits of an
+// anonymous request, do not apply.
+func (e *Engine) Reserve(ctx context.Context, id string, method disbursement.Method, d *disbursement.Disbursement) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	e.prune(now)
+	n := &entry{
+		at:     now,
+		keys:   keys(disbursement.ClientIDFromContext(ctx), method, d),
+		amount: d.Details.Amount,
+	}
+	for _, l := range e.limits {
+		key := n.keys[l.Scope]
+		if key == "" || (l.Key != "" && l.Key != key) {
+			continue
+		}
+		if err := e.check(l, key, n, now); err != nil {
+			return err
+		}
+	}
+	e.entries[id] = n
+	return nil
+}
+
+// check returns a *disbursement.LimitError if n would exceed l for key.
+func (e *Engine) check(l disbursement.Limit, key string, n *entry, now time.Time) error {
+	since := now.Add(-l.Window.Duration())
+	count, used := 0, disbursement.Money{Currency: n.amount.Currency}
+	if l.MaxAmount != nil {
+		used.Currency = l.MaxAmount.Currency
+	}
+	for _, ent := range e.entries {
+		if ent.keys[l.Scope] != key || ent.at.Before(since) {
+			continue
+		}
+		count++
+		if ent.amount.Currency == used.Currency {
+			used.Minor += ent.amount.Minor
+		}
+	}
+
+	if l.MaxCount > 0 && count >= l.MaxCount {
+		return &disbursement.LimitError{Limit: l, Key: key, Count: count, Amount: used}
+	}
+	if l.MaxAmount != nil && n.amount.Currency == l.MaxAmount.Currency && used.Minor+n.amount.Minor > l.MaxAmount.Minor {
+		return &disbursement.LimitError{Limit: l, Key: key, Count: count, Amount: used}
+	}
+	return nil
+}
+
+// Release stops counting the transfer reserved as id.
+func (e *Engine) Release(ctx context.Context, id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.entries, id)
+	return nil
+}
+
+// prune forgets transfers older than the longest window. e.mu must be held.
+func (e *Engine) prune(now time.Time) {
+	since := now.Add(-e.span)
+	for id, ent := range e.entries {
+		if ent.at.Before(since) {
+			delete(e.entries, id)
+		}
+	}
+}
+
+// keys returns the value of each scope for a transfer.
+func keys(clientID string, method disbursement.Method, d *disbursement.Disbursement) map[disbursement.LimitScope]string {
+	beneficiary := d.Receiver.AccountNumber
+	if beneficiary != "" && d.Details.ReceivingBank != "" {
+		beneficiary = d.Details.ReceivingBank + ":" + beneficiary
+	}
+	return map[disbursement.LimitScope]string{
+		disbursement.LimitPerClient:      clientID,
+		disbursement.LimitPerSender:      d.SenderID,
+		disbursement.LimitPerBeneficiary: beneficiary,
+		disbursement.LimitPerMethod:      string(method),
+	}
+}
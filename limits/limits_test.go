@@ -0,0 +1,136 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func php(amount int64) *disbursement.Money {
+	return &disbursement.Money{Minor: amount * 100, Currency: "PHP"}
+}
+
+func transfer(account string, amount int64) *disbursement.Disbursement {
+	return &disbursement.Disbursement{
+		SenderID: "palngipang",
+		Receiver: disbursement.Receiver{AccountNumber: account},
+		Details:  disbursement.Details{Amount: *php(amount), ReceivingBank: "161408"},
+	}
+}
+
+func TestEngine(t *testing.T) {
+	Convey("given limits per beneficiary and client", t, func() {
+		now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+		e, err := NewEngine([]disbursement.Limit{
+			{Scope: disbursement.LimitPerBeneficiary, Window: disbursement.LimitDay, MaxCount: 2},
+			{Scope: disbursement.LimitPerClient, Window: disbursement.LimitHour, MaxAmount: php(100000)},
+			{Scope: disbursement.LimitPerMethod, Key: "pesonet", Window: disbursement.LimitMonth, MaxCount: 1},
+		})
+		So(err, ShouldBeNil)
+		e.now = func() time.Time { return now }
+		ctx := disbursement.NewContextWithClientID(context.Background(), "acme")
+
+		Convey("transfers beyond a count are refused until the window moves on", func() {
+			So(e.Reserve(ctx, "txn_1", disbursement.MethodInstapay, transfer("111", 10)), ShouldBeNil)
+			So(e.Reserve(ctx, "txn_2", disbursement.MethodInstapay, transfer("111", 10)), ShouldBeNil)
+			So(e.Reserve(ctx, "txn_3", disbursement.MethodInstapay, transfer("222", 10)), ShouldBeNil)
+
+			err := e.Reserve(ctx, "txn_4", disbursement.MethodInstapay, transfer("111", 10))
+			So(errors.Is(err, disbursement.ErrLimitExceeded), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "limit exceeded: daily limit of 2 transfers per beneficiary reached for 161408:111")
+
+			now = now.Add(24*time.Hour + time.Minute)
+			So(e.Reserve(ctx, "txn_4", disbursement.MethodInstapay, transfer("111", 10)), ShouldBeNil)
+		})
+
+		Convey("transfers beyond an amount are refused", func() {
+			So(e.Reserve(ctx, "txn_1", disbursement.MethodInstapay, transfer("111", 60000)), ShouldBeNil)
+
+			err := e.Reserve(ctx, "txn_2", disbursement.MethodInstapay, transfer("222", 50000))
+			var lerr *disbursement.LimitError
+			So(errors.As(err, &lerr), ShouldBeTrue)
+			So(lerr.Limit.Scope, ShouldEqual, disbursement.LimitPerClient)
+			So(lerr.Amount.String(), ShouldEqual, "60000.00")
+			So(err.Error(), ShouldContainSubstring, "hourly limit of 100000.00 PHP per client reached for acme")
+
+			So(e.Reserve(ctx, "txn_2", disbursement.MethodInstapay, transfer("222", 40000)), ShouldBeNil)
+			So(e.Reserve(context.Background(), "txn_3", disbursement.MethodInstapay, transfer("333", 40000)), ShouldBeNil)
+		})
+
+		Convey("limits keyed to one rail apply to it only", func() {
+			So(e.Reserve(ctx, "txn_1", disbursement.MethodPesonet, transfer("111", 10)), ShouldBeNil)
+			So(e.Reserve(ctx, "txn_2", disbursement.MethodInstapay, transfer("222", 10)), ShouldBeNil)
+			err := e.Reserve(ctx, "txn_3", disbursement.MethodPesonet, transfer("333", 10))
+			So(errors.Is(err, disbursement.ErrLimitExceeded), ShouldBeTrue)
+		})
+
+		Convey("released transfers stop counting", func() {
+			So(e.Reserve(ctx, "txn_1", disbursement.MethodInstapay, transfer("111", 90000)), ShouldBeNil)
+			So(e.Release(ctx, "txn_1"), ShouldBeNil)
+			So(e.Reserve(ctx, "txn_2", disbursement.MethodInstapay, transfer("111", 90000)), ShouldBeNil)
+		})
+
+		Convey("concurrent transfers cannot jointly exceed a limit", func() {
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			admitted := 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if e.Reserve(ctx, fmt.Sprintf("txn_%d", i), disbursement.MethodInstapay, transfer("111", 10)) == nil {
+						mu.Lock()
+						admitted++
+						mu.Unlock()
+					}
+				}(i)
+			}
+			wg.Wait()
+			So(admitted, ShouldEqual, 2)
+		})
+
+		Convey("recorded transactions count after a restart", func() {
+			store := inmem.NewTransactionStore()
+			for i, state := range []disbursement.TransferState{disbursement.TransferCredited, disbursement.TransferFailed} {
+				tx := &disbursement.Transaction{
+					ClientID:  "acme",
+					Method:    disbursement.MethodInstapay,
+					Request:   transfer("111", 10),
+					CreatedAt: now.Add(-time.Duration(i+1) * time.Hour),
+				}
+				tx.SetState(state, "", tx.CreatedAt)
+				So(store.CreateTransaction(ctx, tx), ShouldBeNil)
+			}
+			So(e.Restore(ctx, store), ShouldBeNil)
+
+			So(e.Reserve(ctx, "txn_1", disbursement.MethodInstapay, transfer("111", 10)), ShouldBeNil)
+			err := e.Reserve(ctx, "txn_2", disbursement.MethodInstapay, transfer("111", 10))
+			So(errors.Is(err, disbursement.ErrLimitExceeded), ShouldBeTrue)
+		})
+	})
+
+	Convey("malformed limits are rejected", t, func() {
+		for _, l := range []disbursement.Limit{
+			{Scope: "bank", Window: disbursement.LimitDay, MaxCount: 1},
+			{Scope: disbursement.LimitPerClient, Window: "week", MaxCount: 1},
+			{Scope: disbursement.LimitPerClient, Window: disbursement.LimitDay},
+		} {
+			_, err := NewEngine([]disbursement.Limit{l})
+			So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+		}
+	})
+
+	Convey("the development limits load", t, func() {
+		limits, err := LoadLimits("../limits.dev.json")
+		So(err, ShouldBeNil)
+		_, err = NewEngine(limits)
+		So(err, ShouldBeNil)
+	})
+}
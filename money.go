@@ -0,0 +1,220 @@
+package disbursement
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of every rail the service disburses on.
+const DefaultCurrency = "PHP"
+
+// currencyExponents maps supported ISO 4217 codes to their number of minor
+// unit digits.
+var currencyExponents = map[string]int{
+	"PHP": 2,
+	"USD": 2,
+}
+
+// maxMoneyDigits bounds the integer digits of a parsed amount so that
+// arithmetic on minor units cannot overflow.
+const maxMoneyDigits = 15
+
+var amountPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)(\.([0-9]+))?$`)
+
+// Money is an amount in the minor units of an ISO 4217 currency, e.g.
+// centavos for PHP.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// ParseMoney parses a plain decimal amount such as "2000.00". Signs,
+// exponents, separators and more fractional digits than the currency allows
+// are rejected rather than rounded. Failures are reported as a
+// *ValidationError on the "amount" or "currency" field.
+func ParseMoney(amount, currency string) (Money, error) {
+	m, fe := parseMoney(amount, currency)
+	if fe != nil {
+		return Money{}, &ValidationError{Errors: []FieldError{*fe}}
+	}
+	return m, nil
+}
+
+// parseMoney parses amount, describing a failure as a FieldError on either
+// the "amount" or "currency" field.
+func parseMoney(amount, currency string) (Money, *FieldError) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, &FieldError{Field: "currency", Message: fmt.Sprintf("unsupported currency %q", currency)}
+	}
+
+	m := amountPattern.FindStringSubmatch(amount)
+	if m == nil {
+		return Money{}, &FieldError{Field: "amount", Message: fmt.Sprintf("malformed amount %q", amount)}
+	} else if len(m[1]) > maxMoneyDigits {
+		return Money{}, &FieldError{Field: "amount", Message: fmt.Sprintf("amount %q too large", amount)}
+	} else if len(m[3]) > exp {
+		return Money{}, &FieldError{Field: "amount", Message: fmt.Sprintf("amount %q has more than %d decimal places", amount, exp)}
+	}
+
+	digits := m[1] + m[3] + strings.Repeat("0", exp-len(m[3]))
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, &FieldError{Field: "amount", Message: fmt.Sprintf("malformed amount %q", amount)}
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// String formats m as a plain decimal with the currency's number of decimal
+// places, e.g. "2000.00". This is the format UBP expects.
+func (m Money) String() string {
+	exp := currencyExponents[m.Currency]
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+
+	s := strconv.FormatInt(minor, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+
+// Add returns m+o. Both amounts must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: m.Minor + o.Minor, Currency: m.Currency}, nil
+}
+
+// Sub returns m-o. Both amounts must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: m.Minor - o.Minor, Currency: m.Currency}, nil
+}
+
+// Cmp compares m and o, returning -1, 0 or +1. Amounts in different
+// currencies are ordered by currency code.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.Currency != o.Currency:
+		return strings.Compare(m.Currency, o.Currency)
+	case m.Minor < o.Minor:
+		return -1
+	case m.Minor > o.Minor:
+		return 1
+	}
+	return 0
+}
+
+// RoundingMode decides how fractional minor units are resolved.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest minor unit, ties to even. It is
+	// the default for computed amounts because it does not bias totals.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest minor unit, ties away from zero.
+	RoundHalfUp
+	// RoundUp rounds away from zero, e.g. for fees that must never be
+	// under-collected.
+	RoundUp
+)
+
+// MulRat returns m*num/den rounded to a whole minor unit. It is used for
+// percentage fees, e.g. MulRat(25, 10000, RoundUp) for 0.25%.
+func (m Money) MulRat(num, den int64, mode RoundingMode) Money {
+	p := new(big.Int).Mul(big.NewInt(m.Minor), big.NewInt(num))
+	d := big.NewInt(den)
+	if d.Sign() < 0 {
+		p.Neg(p)
+		d.Neg(d)
+	}
+
+	// QuoRem truncates toward zero, so r has the sign of p.
+	q, r := new(big.Int).QuoRem(p, d, new(big.Int))
+	if r.Sign() != 0 {
+		twice := new(big.Int).Abs(r)
+		twice.Lsh(twice, 1)
+		c := twice.Cmp(d)
+
+		away := false
+		switch mode {
+		case RoundUp:
+			away = true
+		case RoundHalfUp:
+			away = c >= 0
+		case RoundHalfEven:
+			away = c > 0 || (c == 0 && q.Bit(0) == 1)
+		}
+		if away {
+			q.Add(q, big.NewInt(int64(p.Sign())))
+		}
+	}
+	return Money{Minor: q.Int64(), Currency: m.Currency}
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%w: currency mismatch %s and %s", ErrInvalid, m.Currency, o.Currency)
+	}
+	return nil
+}
+
+// MarshalJSON encodes m as {"amount":"2000.00","currency":"PHP"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: json.RawMessage(strconv.Quote(m.String())), Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes {"amount":"2000.00","currency":"PHP"}. The amount may
+// be a JSON string or number but must be a plain decimal.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	money, fe := unmarshalMoney(v.Amount, v.Currency)
+	if fe != nil {
+		return &ValidationError{Errors: []FieldError{*fe}}
+	}
+	*m = money
+	return nil
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// unmarshalMoney decodes a JSON amount, which may be a string or number
+// literal, in the given currency.
+func unmarshalMoney(raw json.RawMessage, currency string) (Money, *FieldError) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return Money{}, &FieldError{Field: "amount", Message: "is required"}
+	}
+
+	amount := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &amount); err != nil {
+			return Money{}, &FieldError{Field: "amount", Message: "malformed string"}
+		}
+	}
+	return parseMoney(amount, currency)
+}
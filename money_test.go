@@ -0,0 +1,105 @@
+package disbursement
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseMoney(t *testing.T) {
+	Convey("plain decimal amounts are parsed into minor units", t, func() {
+		for amount, minor := range map[string]int64{
+			"2000.00": 200000,
+			"2000":    200000,
+			"0.5":     50,
+			"10.05":   1005,
+			"0":       0,
+		} {
+			m, err := ParseMoney(amount, "PHP")
+			So(err, ShouldBeNil)
+			So(m, ShouldResemble, Money{Minor: minor, Currency: "PHP"})
+		}
+	})
+
+	Convey("malformed amounts are rejected", t, func() {
+		for _, amount := range []string{"1e3", "-5", "10.005", "+5", "1,000.00", "", ".5", "5.", "007", " 5", "1000000000000000"} {
+			_, err := ParseMoney(amount, "PHP")
+			So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+		}
+	})
+
+	Convey("unsupported currencies are rejected", t, func() {
+		_, err := ParseMoney("10.00", "XYZ")
+		So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+	})
+}
+
+func TestMoney_String(t *testing.T) {
+	Convey("amounts are formatted the way UBP expects", t, func() {
+		So(Money{Minor: 200000, Currency: "PHP"}.String(), ShouldEqual, "2000.00")
+		So(Money{Minor: 5, Currency: "PHP"}.String(), ShouldEqual, "0.05")
+		So(Money{Minor: 0, Currency: "PHP"}.String(), ShouldEqual, "0.00")
+		So(Money{Minor: -150, Currency: "PHP"}.String(), ShouldEqual, "-1.50")
+	})
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	Convey("arithmetic requires matching currencies", t, func() {
+		a := Money{Minor: 1000, Currency: "PHP"}
+		b := Money{Minor: 250, Currency: "PHP"}
+
+		sum, err := a.Add(b)
+		So(err, ShouldBeNil)
+		So(sum.Minor, ShouldEqual, 1250)
+
+		diff, err := a.Sub(b)
+		So(err, ShouldBeNil)
+		So(diff.Minor, ShouldEqual, 750)
+		So(a.Cmp(b), ShouldEqual, 1)
+		So(b.Cmp(a), ShouldEqual, -1)
+
+		_, err = a.Add(Money{Minor: 1, Currency: "USD"})
+		So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+	})
+
+	Convey("rates are rounded according to the rounding mode", t, func() {
+		m := Money{Minor: 1050, Currency: "PHP"}
+		So(m.MulRat(1, 4, RoundHalfEven).Minor, ShouldEqual, 262) // 262.5
+		So(m.MulRat(1, 4, RoundHalfUp).Minor, ShouldEqual, 263)
+		So(m.MulRat(1, 3, RoundUp).Minor, ShouldEqual, 350)
+		So(Money{Minor: 1150, Currency: "PHP"}.MulRat(1, 4, RoundHalfEven).Minor, ShouldEqual, 288) // 287.5
+		So(Money{Minor: -1050, Currency: "PHP"}.MulRat(1, 4, RoundHalfUp).Minor, ShouldEqual, -263)
+	})
+}
+
+func TestDetails_JSON(t *testing.T) {
+	Convey("details decode amount and currency strictly", t, func() {
+		var d Details
+		err := json.Unmarshal([]byte(`{"amount":"2000.00","currency":"PHP","receivingBank":"161203"}`), &d)
+		So(err, ShouldBeNil)
+		So(d.Amount, ShouldResemble, Money{Minor: 200000, Currency: "PHP"})
+		So(d.ReceivingBank, ShouldEqual, "161203")
+
+		err = json.Unmarshal([]byte(`{"amount":1500.5,"currency":"PHP"}`), &d)
+		So(err, ShouldBeNil)
+		So(d.Amount.Minor, ShouldEqual, 150050)
+
+		for _, body := range []string{
+			`{"amount":"1e3","currency":"PHP"}`,
+			`{"amount":1e3,"currency":"PHP"}`,
+			`{"amount":"-5","currency":"PHP"}`,
+			`{"amount":"10.005","currency":"PHP"}`,
+			`{"currency":"PHP"}`,
+		} {
+			So(json.Unmarshal([]byte(body), &d), ShouldNotBeNil)
+		}
+	})
+
+	Convey("details encode the amount in UBP format", t, func() {
+		b, err := json.Marshal(Details{Amount: Money{Minor: 3000, Currency: "PHP"}, ReceivingBank: "161408"})
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, `{"amount":"30.00","currency":"PHP","receivingBank":"161408","purpose":"","instructions":""}`)
+	})
+}
@@ -0,0 +1,354 @@
+package payout
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// DefaultBatchConcurrency caps the number of batch items sent at once.
+const DefaultBatchConcurrency = 8
+
+// BatchRunner is a disbursement.BatchService that sends the items of each
+// batch through its Service in the background. Batches still running when
+// the process stops are resumed by the next Run.
+type BatchRunner struct {
+	Service *Service
+	Store   disbursement.BatchStore
+
+	// Senders, if set, resolves again the senders of the batches resumed
+	// by Run. Batches are stored without their resolved senders.
+	Senders disbursement.SenderService
+
+	// Concurrency caps the number of items in flight across every batch.
+	// It is read when Run starts.
+	Concurrency int
+
+	// mu guards runs and the item states of every running batch, so an
+	// item is never both cancelled and sent.
+	mu   sync.Mutex
+	runs map[string]*disbursement.Batch
+	ctx  context.Context
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	now func() time.Time
+}
+
+func NewBatchRunner(s *Service, store disbursement.BatchStore) *BatchRunner {
+	return &BatchRunner{
+		Service:     s,
+		Store:       store,
+		Concurrency: DefaultBatchConcurrency,
+		runs:        make(map[string]*disbursement.Batch),
+		now:         time.Now,
+	}
+}
+
+// Run resumes the batches left running by a previous process and executes
+// new batches until ctx is done. Items that were being sent when the
+// previous process stopped are marked unknown rather than sent again.
+func (r *BatchRunner) Run(ctx context.Context) error {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.sem = make(chan struct{}, r.Concurrency)
+	batches, err := r.Store.FindBatches(ctx, disbursement.BatchRunning)
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	for _, b := range batches {
+		r.restore(b)
+		for _, item := range b.Items {
+			if item.State == disbursement.BatchItemSubmitting {
+				item.State = disbursement.BatchItemUnknown
+				item.Error = "interrupted while sending; check the transaction before retrying"
+				r.findTransaction(item)
+				r.saveItem(b, item)
+			}
+		}
+		r.start(b)
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	r.wg.Wait()
+	return ctx.Err()
+}
+
+// CreateBatch stores b as running on behalf of the calling client and starts
+// sending its items.
+func (r *BatchRunner) CreateBatch(ctx context.Context, b *disbursement.Batch) error {
+	now := r.now()
+	b.ClientID = disbursement.ClientIDFromContext(ctx)
+	b.State = disbursement.BatchRunning
+	b.CreatedAt, b.UpdatedAt = now, now
+	for i, item := range b.Items {
+		item.Index = i
+		item.State = disbursement.BatchItemQueued
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.Store.CreateBatch(ctx, b); err != nil {
+		return err
+	}
+	// Before Run starts, or once it stopped, the batch is left for the
+	// next Run to resume.
+	if r.ctx != nil && r.ctx.Err() == nil {
+		stored, err := r.Store.FindBatchByID(ctx, b.ID)
+		if err != nil {
+			return err
+		}
+		r.start(stored)
+	}
+	return nil
+}
+
+func (r *BatchRunner) FindBatchByID(ctx context.Context, id string) (*disbursement.Batch, error) {
+	b, err := r.Store.FindBatchByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !disbursement.CallerOwns(ctx, b.ClientID) {
+		return nil, disbursement.ErrNotFound
+	}
+	return b, nil
+}
+
+// CancelBatch cancels the queued items of a batch. Items already sent, or
+// being sent, are not affected.
+func (r *BatchRunner) CancelBatch(ctx context.Context, id string) (*disbursement.Batch, error) {
+	if _, err := r.FindBatchByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	b, ok := r.runs[id]
+	if !ok {
+		// Not started yet, or already completed.
+		var err error
+		if b, err = r.Store.FindBatchByID(ctx, id); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+	}
+	for _, item := range b.Items {
+		if item.State == disbursement.BatchItemQueued {
+			item.State = disbursement.BatchItemCancelled
+			r.saveItem(b, item)
+		}
+	}
+	r.mu.Unlock()
+
+	return r.Store.FindBatchByID(ctx, id)
+}
+
+// restore sets again what the items of the resumed batch b lost when
+// stored: their provider reference ids and resolved senders. Queued items
+// whose sender can no longer be resolved fail.
+func (r *BatchRunner) restore(b *disbursement.Batch) {
+	ctx := disbursement.NewContextWithClientID(context.Background(), b.ClientID)
+	for _, item := range b.Items {
+		d := item.Disbursement
+		if d.SenderRefID == "" {
+			d.SenderRefID = item.SenderRefID
+		}
+		if item.State != disbursement.BatchItemQueued || d.Sender != nil || r.Senders == nil {
+			continue
+		}
+		if err := disbursement.ResolveSender(ctx, r.Senders, d); err != nil {
+			item.State = disbursement.BatchItemFailed
+			item.Error = err.Error()
+			r.saveItem(b, item)
+		}
+	}
+}
+
+// start sends the items of b in the background. r.mu must be held.
+func (r *BatchRunner) start(b *disbursement.Batch) {
+	r.runs[b.ID] = b
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(r.ctx, b)
+	}()
+}
+
+// run sends every queued item of b and completes the batch once no item is
+// left, unless ctx is done first. Items held until their rail's next window
+// are sent after the others, as each window opens.
+func (r *BatchRunner) run(ctx context.Context, b *disbursement.Batch) {
+	ctx = disbursement.NewContextWithClientID(ctx, b.ClientID)
+
+	var wg sync.WaitGroup
+	var deferred []*disbursement.BatchItem
+	for _, item := range b.Items {
+		if r.deferItem(b, item) {
+			deferred = append(deferred, item)
+			continue
+		}
+		if !r.dispatch(ctx, &wg, b, item) {
+			break
+		}
+	}
+	sort.SliceStable(deferred, func(i, j int) bool {
+		return deferred[i].DeferredUntil.Before(*deferred[j].DeferredUntil)
+	})
+	for _, item := range deferred {
+		if !r.wait(ctx, *item.DeferredUntil) || !r.dispatch(ctx, &wg, b, item) {
+			break
+		}
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.runs, b.ID)
+	if ctx.Err() != nil {
+		return
+	}
+	now := r.now()
+	b.State = disbursement.BatchCompleted
+	b.UpdatedAt, b.CompletedAt = now, &now
+	if err := r.Store.UpdateBatch(context.Background(), b); err != nil {
+		log.Printf("payout: cannot complete batch %s: %s", b.ID, err)
+	}
+}
+
+// dispatch sends item in the background once a slot is free. It returns
+// false if ctx is done first.
+func (r *BatchRunner) dispatch(ctx context.Context, wg *sync.WaitGroup, b *disbursement.Batch, item *disbursement.BatchItem) bool {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	if ctx.Err() != nil {
+		<-r.sem
+		return false
+	}
+	if !r.claim(b, item) {
+		<-r.sem
+		return true
+	}
+	wg.Add(1)
+	go func() {
+		defer func() { <-r.sem; wg.Done() }()
+		r.send(ctx, b, item)
+	}()
+	return true
+}
+
+// deferItem holds the queued item until its rail's next window if it missed
+// the cutoff and b defers such items. It reports whether item was held.
+func (r *BatchRunner) deferItem(b *disbursement.Batch, item *disbursement.BatchItem) bool {
+	cal := r.Service.Calendar
+	if b.AfterCutoff != disbursement.CutoffDefer || cal == nil {
+		return false
+	}
+	st := cal.Settlement(item.Method, r.now())
+	if !st.AfterCutoff {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if item.State != disbursement.BatchItemQueued {
+		return false
+	}
+	item.DeferredUntil = &st.NextWindow
+	r.saveItem(b, item)
+	return true
+}
+
+// wait returns once t has come, or false if ctx is done first.
+func (r *BatchRunner) wait(ctx context.Context, t time.Time) bool {
+	d := t.Sub(r.now())
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// claim marks item as being sent, unless it is no longer queued.
+func (r *BatchRunner) claim(b *disbursement.Batch, item *disbursement.BatchItem) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if item.State != disbursement.BatchItemQueued {
+		return false
+	}
+	d := item.Disbursement
+	if a, ok := r.Service.Provider.(disbursement.SenderRefIDAssigner); ok && d.SenderRefID == "" {
+		d.SenderRefID = a.NewSenderRefID(d)
+	}
+	item.State = disbursement.BatchItemSubmitting
+	item.SenderRefID = d.SenderRefID
+	// Without a record of the claim, a crash could send the item twice.
+	if !r.saveItem(b, item) {
+		item.State = disbursement.BatchItemQueued
+		return false
+	}
+	return true
+}
+
+// send transfers item and records the outcome.
+func (r *BatchRunner) send(ctx context.Context, b *disbursement.Batch, item *disbursement.BatchItem) {
+	result, err := r.Service.TransferFunds(ctx, item.Method, item.Disbursement)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		item.State = disbursement.BatchItemSubmitted
+		item.TransactionID = result.TransactionID
+		item.Result = result
+	case rejected(err):
+		item.State = disbursement.BatchItemFailed
+		item.Error = err.Error()
+		r.findTransaction(item)
+	default:
+		// As with a single transfer, the provider may have acted on it;
+		// the reconciler settles its transaction.
+		item.State = disbursement.BatchItemUnknown
+		item.Error = err.Error()
+		r.findTransaction(item)
+	}
+	r.saveItem(b, item)
+}
+
+// findTransaction sets the TransactionID of item from the transaction
+// recorded for it, if any.
+func (r *BatchRunner) findTransaction(item *disbursement.BatchItem) {
+	if item.TransactionID != "" || item.SenderRefID == "" {
+		return
+	}
+	txs, err := r.Service.Transactions.FindTransactions(context.Background(), disbursement.TransactionFilter{
+		Method:      item.Method,
+		SenderRefID: item.SenderRefID,
+		Limit:       1,
+	})
+	if err == nil && len(txs) > 0 {
+		item.TransactionID = txs[0].ID
+	}
+}
+
+// saveItem records item, logging failures.
+func (r *BatchRunner) saveItem(b *disbursement.Batch, item *disbursement.BatchItem) bool {
+	if err := r.Store.UpdateBatchItem(context.Background(), b.ID, item); err != nil {
+		log.Printf("payout: cannot record item %d of batch %s: %s", item.Index, b.ID, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package payout
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/filestore"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newBatch(n int) *disbursement.Batch {
+	b := &disbursement.Batch{}
+	for i := 0; i < n; i++ {
+		b.Items = append(b.Items, &disbursement.BatchItem{
+			Index:  i,
+			Method: disbursement.MethodInstapay,
+			Disbursement: &disbursement.Disbursement{
+				ReferenceID: fmt.Sprintf("PAY-%d", i),
+				Details:     disbursement.Details{Amount: disbursement.Money{Minor: 10000, Currency: "PHP"}},
+			},
+		})
+	}
+	return b
+}
+
+// waitForBatch returns the batch once it is completed.
+func waitForBatch(store disbursement.BatchStore, id string) *disbursement.Batch {
+	for i := 0; i < 200; i++ {
+		b, _ := store.FindBatchByID(context.Background(), id)
+		if b != nil && b.State == disbursement.BatchCompleted {
+			return b
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestBatchRunner(t *testing.T) {
+	Convey("given a running batch runner", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		txs := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: txs}
+		batches := inmem.NewBatchStore()
+		r := NewBatchRunner(NewService(provider, txs), batches)
+		r.Concurrency = 1
+
+		Convey("every item of a batch is sent and recorded", func() {
+			go r.Run(ctx)
+			time.Sleep(10 * time.Millisecond)
+
+			b := newBatch(3)
+			So(r.CreateBatch(disbursement.NewContextWithClientID(ctx, "acme"), b), ShouldBeNil)
+
+			done := waitForBatch(batches, b.ID)
+			So(done, ShouldNotBeNil)
+			So(done.ClientID, ShouldEqual, "acme")
+			totals := done.Totals()
+			So(totals.Submitted, ShouldEqual, 3)
+			So(totals.SubmittedAmount.String(), ShouldEqual, "300.00")
+
+			tx, err := txs.FindTransactionByID(ctx, done.Items[2].TransactionID)
+			So(err, ShouldBeNil)
+			So(tx.ClientID, ShouldEqual, "acme")
+			So(tx.Request.ReferenceID, ShouldEqual, "PAY-2")
+		})
+
+		Convey("items past the cutoff of a deferring batch wait for the next window", func() {
+			go r.Run(ctx)
+			time.Sleep(10 * time.Millisecond)
+
+			opens := time.Now().Add(50 * time.Millisecond)
+			r.Service.Calendar = fakeCalendar{settlesIn: time.Hour, opens: opens}
+			b := newBatch(2)
+			b.AfterCutoff = disbursement.CutoffDefer
+			So(r.CreateBatch(ctx, b), ShouldBeNil)
+
+			done := waitForBatch(batches, b.ID)
+			So(done, ShouldNotBeNil)
+			So(time.Now().Before(opens), ShouldBeFalse)
+			So(done.Totals().Submitted, ShouldEqual, 2)
+			So(done.Items[0].DeferredUntil, ShouldNotBeNil)
+			So(done.Items[0].Result.Warnings, ShouldBeEmpty)
+		})
+
+		Convey("cancelling a batch skips the items not yet sent", func() {
+			provider.gate = make(chan struct{})
+			go r.Run(ctx)
+			time.Sleep(10 * time.Millisecond)
+
+			b := newBatch(3)
+			So(r.CreateBatch(ctx, b), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			got, err := r.CancelBatch(ctx, b.ID)
+			So(err, ShouldBeNil)
+			So(got.Items[0].State, ShouldEqual, disbursement.BatchItemSubmitting)
+			So(got.Items[1].State, ShouldEqual, disbursement.BatchItemCancelled)
+			close(provider.gate)
+
+			done := waitForBatch(batches, b.ID)
+			So(done, ShouldNotBeNil)
+			So(done.Items[0].State, ShouldEqual, disbursement.BatchItemSubmitted)
+			So(done.Totals().Cancelled, ShouldEqual, 2)
+			So(provider.seen, ShouldHaveLength, 1)
+		})
+
+		Convey("batches interrupted by a restart are resumed without resending", func() {
+			b := newBatch(2)
+			b.State = disbursement.BatchRunning
+			b.Items[0].State = disbursement.BatchItemSubmitting
+			b.Items[1].State = disbursement.BatchItemQueued
+			So(batches.CreateBatch(ctx, b), ShouldBeNil)
+
+			go r.Run(ctx)
+			done := waitForBatch(batches, b.ID)
+			So(done, ShouldNotBeNil)
+			So(done.Items[0].State, ShouldEqual, disbursement.BatchItemUnknown)
+			So(done.Items[1].State, ShouldEqual, disbursement.BatchItemSubmitted)
+			So(provider.seen, ShouldHaveLength, 1)
+		})
+
+		Convey("other clients cannot see a batch", func() {
+			b := newBatch(1)
+			So(r.CreateBatch(disbursement.NewContextWithClientID(ctx, "acme"), b), ShouldBeNil)
+			_, err := r.FindBatchByID(disbursement.NewContextWithClientID(ctx, "globex"), b.ID)
+			So(err, ShouldEqual, disbursement.ErrNotFound)
+		})
+	})
+}
+
+func TestBatchRunnerResume(t *testing.T) {
+	Convey("batches saved to a file are resumed with their senders and reference ids", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "batches.jsonl")
+		txs := inmem.NewTransactionStore()
+		senders, err := inmem.NewSenderService([]*disbursement.Sender{{
+			ID:      "palngipang",
+			Name:    "Palngipang Corp.",
+			Address: disbursement.Address{Line1: "Some Tower", City: "Some City", Province: "Metro Manila", Country: "PH"},
+		}}, "palngipang")
+		So(err, ShouldBeNil)
+
+		// Run the batch until its first item is with the provider.
+		batches, err := filestore.OpenBatchStore(path)
+		So(err, ShouldBeNil)
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: txs, gate: make(chan struct{})}
+		r := NewBatchRunner(NewService(provider, txs), batches)
+		r.Concurrency = 1
+		ctx, cancel := context.WithCancel(context.Background())
+		go r.Run(ctx)
+		time.Sleep(10 * time.Millisecond)
+
+		b := newBatch(2)
+		for _, item := range b.Items {
+			So(disbursement.ResolveSender(ctx, senders, item.Disbursement), ShouldBeNil)
+		}
+		So(r.CreateBatch(ctx, b), ShouldBeNil)
+		var sent []*disbursement.Transaction
+		for i := 0; i < 200 && len(sent) == 0; i++ {
+			time.Sleep(5 * time.Millisecond)
+			sent, _ = txs.FindTransactions(ctx, disbursement.TransactionFilter{})
+		}
+		So(sent, ShouldHaveLength, 1)
+
+		// The process stops here: keep the file as it is now.
+		saved, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		cancel()
+		close(provider.gate)
+		So(batches.Close(), ShouldBeNil)
+		resumedPath := filepath.Join(dir, "resumed.jsonl")
+		So(ioutil.WriteFile(resumedPath, saved, 0600), ShouldBeNil)
+
+		resumed, err := filestore.OpenBatchStore(resumedPath)
+		So(err, ShouldBeNil)
+		defer resumed.Close()
+		provider = &fakeProvider{state: disbursement.TransferProcessing, store: txs}
+		r = NewBatchRunner(NewService(provider, txs), resumed)
+		r.Senders = senders
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		go r.Run(ctx)
+
+		done := waitForBatch(resumed, b.ID)
+		So(done, ShouldNotBeNil)
+		So(done.Items[0].State, ShouldEqual, disbursement.BatchItemUnknown)
+		So(done.Items[0].TransactionID, ShouldEqual, sent[0].ID)
+		So(done.Items[1].State, ShouldEqual, disbursement.BatchItemSubmitted)
+		So(done.Items[1].SenderRefID, ShouldNotBeEmpty)
+		So(provider.senders, ShouldResemble, []string{"palngipang"})
+	})
+}
@@ -0,0 +1,331 @@
+// Package payout records disbursements around the provider that sends them.
+// Its Service wraps a provider's disbursement.DisbursementService so that
+// every attempt is stored before the provider is called and updated with the
+// outcome afterwards.
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Service is a disbursement.DisbursementService that records every transfer
+// in a TransactionStore.
+type Service struct {
+	Provider     disbursement.DisbursementService
+	Transactions disbursement.TransactionStore
+
+	// Events, if set, is told when a transfer is created and when it
+	// reaches a final state.
+	Events disbursement.EventPublisher
+
+	// Calendar, if set, gives the expected settlement time of each
+	// transfer, and a warning for those that missed their rail's cutoff.
+	Calendar disbursement.Calendar
+
+	// Limits, if set, must admit every transfer before it is recorded and
+	// sent. Transfers that fail or are returned stop counting.
+	Limits disbursement.Limiter
+
+	// Validator and Approvals, if set, must also accept the fallback of a
+	// routed transfer before it is sent through it.
+	Validator *disbursement.Validator
+	Approvals disbursement.ApprovalService
+
+	now func() time.Time
+}
+
+func NewService(provider disbursement.DisbursementService, transactions disbursement.TransactionStore) *Service {
+	return &Service{Provider: provider, Transactions: transactions, now: time.Now}
+}
+
+// TransferFunds records d as a pending transaction, sends it through the
+// provider and records the outcome. A routed transfer the provider never
+// received is sent again through the route's fallback, if checkFallback
+// allows.
+func (s *Service) TransferFunds(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) (*disbursement.TransferResult, error) {
+	tx, result, err := s.send(ctx, method, d)
+	if err == nil || d.Route == nil || d.Route.Fallback == "" || !errors.Is(err, disbursement.ErrRetryable) {
+		return result, err
+	}
+	if tx == nil || !s.notReceived(ctx, tx) {
+		return result, err
+	}
+	if ferr := s.checkFallback(ctx, method, d); ferr != nil {
+		log.Printf("payout: cannot fall back to %s for transaction %s: %s", d.Route.Fallback, tx.ID, ferr)
+		return result, err
+	}
+
+	failed, prev, uerr := s.update(context.Background(), tx.ID, func(tx *disbursement.Transaction) bool {
+		if tx.State != disbursement.TransferPending {
+			return false
+		}
+		tx.SetState(disbursement.TransferFailed, "", s.now())
+		tx.SetError(fmt.Errorf("not received by the provider, sent through %s instead: %w", d.Route.Fallback, err), s.now())
+		return true
+	})
+	if uerr != nil || failed == nil {
+		// Without a record of the first attempt failing, sending a
+		// second one could look like a double payment.
+		if uerr != nil {
+			log.Printf("payout: cannot record failure of transaction %s: %s", tx.ID, uerr)
+		}
+		return result, err
+	}
+	s.publishState(context.Background(), failed, prev)
+	s.release(failed.ID)
+
+	fallback := *d
+	fallback.SenderRefID = ""
+	fallback.Route = &disbursement.Route{
+		Method:  d.Route.Fallback,
+		Reason:  fmt.Sprintf("fallback after %s was unavailable: %s", method, err),
+		Skipped: d.Route.Skipped,
+	}
+	_, result, err = s.send(ctx, fallback.Route.Method, &fallback)
+	return result, err
+}
+
+// send makes one attempt at a transfer. The returned transaction is nil if
+// it could not be recorded.
+func (s *Service) send(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) (*disbursement.Transaction, *disbursement.TransferResult, error) {
+	if a, ok := s.Provider.(disbursement.SenderRefIDAssigner); ok && d.SenderRefID == "" {
+		d.SenderRefID = a.NewSenderRefID(d)
+	}
+
+	tx := &disbursement.Transaction{
+		ID:          disbursement.NewTransactionID(),
+		Method:      method,
+		Request:     d,
+		SenderRefID: d.SenderRefID,
+		ClientID:    disbursement.ClientIDFromContext(ctx),
+		Route:       d.Route,
+		CreatedAt:   s.now(),
+	}
+	tx.SetState(disbursement.TransferPending, "", tx.CreatedAt)
+	if s.Limits != nil {
+		if err := s.Limits.Reserve(ctx, tx.ID, method, d); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := s.Transactions.CreateTransaction(ctx, tx); err != nil {
+		s.release(tx.ID)
+		return nil, nil, err
+	}
+	created := len(tx.History)
+
+	result, err := s.Provider.TransferFunds(ctx, method, d)
+	if err != nil {
+		if rejected(err) {
+			tx.SetState(disbursement.TransferFailed, "", s.now())
+		}
+		tx.SetError(err, s.now())
+	} else {
+		if result.ReferenceID != "" {
+			tx.SenderRefID = result.ReferenceID
+		}
+		tx.ProviderTransactionID = result.ProviderTransactionID
+		tx.SetState(result.State, result.ProviderState, s.now())
+		result.TransactionID = tx.ID
+		result.Route = d.Route
+		s.expectSettlement(tx, result)
+	}
+
+	// The provider call already happened; failing the request now would
+	// invite a retry that pays out twice, so the error is only logged.
+	stored, prev, uerr := s.update(context.Background(), tx.ID, func(cur *disbursement.Transaction) bool {
+		cur.SenderRefID = tx.SenderRefID
+		cur.ProviderTransactionID = tx.ProviderTransactionID
+		cur.ExpectedSettlementAt = tx.ExpectedSettlementAt
+		// The reconciler may have seen the transfer settle meanwhile.
+		if !cur.State.Final() {
+			cur.State, cur.ProviderState, cur.Error = tx.State, tx.ProviderState, tx.Error
+			cur.History = append(cur.History, tx.History[created:]...)
+			cur.UpdatedAt = tx.UpdatedAt
+		}
+		return true
+	})
+	if uerr != nil {
+		log.Printf("payout: cannot record outcome of transaction %s: %s", tx.ID, uerr)
+	} else {
+		s.publish(context.Background(), disbursement.EventTransferCreated, stored)
+		s.publishState(context.Background(), stored, prev)
+		tx = stored
+	}
+	if tx.State == disbursement.TransferFailed {
+		s.release(tx.ID)
+	}
+	return tx, result, err
+}
+
+// expectSettlement records when the accepted transfer tx should settle.
+func (s *Service) expectSettlement(tx *disbursement.Transaction, result *disbursement.TransferResult) {
+	if s.Calendar == nil || tx.State.Final() {
+		return
+	}
+	st := s.Calendar.Settlement(tx.Method, tx.CreatedAt)
+	tx.ExpectedSettlementAt = &st.ExpectedAt
+	result.ExpectedSettlementAt = &st.ExpectedAt
+	if st.AfterCutoff {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"submitted after the %s cutoff; expected to settle on %s",
+			tx.Method, st.ExpectedAt.Format("Mon 2 Jan 2006 15:04 MST"),
+		))
+	}
+}
+
+// notReceived reports whether the provider confirms it has no record of tx.
+// A not found response from the provider's API is no confirmation, as it
+// may come from the unavailable rail rather than the lookup.
+func (s *Service) notReceived(ctx context.Context, tx *disbursement.Transaction) bool {
+	if tx.SenderRefID == "" {
+		return false
+	}
+	_, err := s.Provider.GetStatus(ctx, tx.Method, tx.SenderRefID)
+	var perr disbursement.ProviderError
+	return errors.Is(err, disbursement.ErrNotFound) && !errors.As(err, &perr)
+}
+
+// checkFallback returns an error unless d, sent through method, may be sent
+// through its route's fallback instead. Limits are checked when it is sent.
+func (s *Service) checkFallback(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) error {
+	fallback := d.Route.Fallback
+	if s.Validator != nil {
+		if err := s.Validator.Validate(ctx, fallback, d); err != nil {
+			return err
+		}
+	}
+	if s.Approvals != nil && s.Approvals.RequiresApproval(fallback, d) && !s.Approvals.RequiresApproval(method, d) {
+		return fmt.Errorf("%w: %s needs approval", disbursement.ErrForbidden, fallback)
+	}
+	return nil
+}
+
+func (s *Service) GetBanks(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	return s.Provider.GetBanks(ctx, method)
+}
+
+// GetStatus returns the provider's status of a transfer and records it on the
+// matching transaction, if any.
+func (s *Service) GetStatus(ctx context.Context, method disbursement.Method, referenceID string) (*disbursement.TransferStatus, error) {
+	status, err := s.Provider.GetStatus(ctx, method, referenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	txs, err := s.Transactions.FindTransactions(ctx, disbursement.TransactionFilter{Method: method, SenderRefID: referenceID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		if err := s.updateStatus(ctx, tx.ID, status); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// updateStatus records the provider's status of transaction id, announcing
+// it if the transfer reached a final state. A status looked up before
+// another writer recorded a final state does not undo it.
+func (s *Service) updateStatus(ctx context.Context, id string, status *disbursement.TransferStatus) error {
+	tx, prev, err := s.update(ctx, id, func(tx *disbursement.Transaction) bool {
+		if tx.State.Final() && !status.State.Final() {
+			return false
+		}
+		if status.ProviderTransactionID != "" {
+			tx.ProviderTransactionID = status.ProviderTransactionID
+		}
+		return tx.SetState(status.State, status.ProviderState, s.now())
+	})
+	if err != nil || tx == nil || tx.State == prev {
+		return err
+	}
+	s.publishState(ctx, tx, prev)
+	if tx.State == disbursement.TransferFailed || tx.State == disbursement.TransferReturned {
+		s.release(tx.ID)
+	}
+	return nil
+}
+
+// maxUpdateAttempts bounds how often update starts over after losing a race
+// with another writer.
+const maxUpdateAttempts = 5
+
+// update applies change to the stored transaction id and saves it, starting
+// over if another writer saved it first. It returns the saved transaction,
+// nil if change reported no change, and the state before.
+func (s *Service) update(ctx context.Context, id string, change func(*disbursement.Transaction) bool) (*disbursement.Transaction, disbursement.TransferState, error) {
+	for attempt := 1; ; attempt++ {
+		tx, err := s.Transactions.FindTransactionByID(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		prev := tx.State
+		if !change(tx) {
+			return nil, prev, nil
+		}
+		err = s.Transactions.UpdateTransaction(ctx, tx)
+		if err == nil {
+			return tx, prev, nil
+		} else if !errors.Is(err, disbursement.ErrConflict) || attempt == maxUpdateAttempts {
+			return nil, prev, err
+		}
+	}
+}
+
+// release stops counting transaction id against the limits, as no money
+// left the account for it.
+func (s *Service) release(id string) {
+	if s.Limits == nil {
+		return
+	}
+	if err := s.Limits.Release(context.Background(), id); err != nil {
+		log.Printf("payout: cannot release limits of transaction %s: %s", id, err)
+	}
+}
+
+// publishState announces the state of tx if it moved from prev to a final
+// state.
+func (s *Service) publishState(ctx context.Context, tx *disbursement.Transaction, prev disbursement.TransferState) {
+	if tx.State == prev {
+		return
+	}
+	if typ, ok := disbursement.StateEvent(tx.State); ok {
+		s.publish(ctx, typ, tx)
+	}
+}
+
+// publish sends an event about tx. Events are best effort here; a publisher
+// that must not lose them is expected to persist them itself.
+func (s *Service) publish(ctx context.Context, typ disbursement.EventType, tx *disbursement.Transaction) {
+	if s.Events == nil {
+		return
+	}
+	snapshot := *tx
+	snapshot.History = append([]disbursement.TransactionEvent(nil), tx.History...)
+	e := &disbursement.Event{
+		ID:          disbursement.NewEventID(),
+		Type:        typ,
+		Transaction: &snapshot,
+		OccurredAt:  s.now(),
+	}
+	if err := s.Events.PublishEvent(ctx, e); err != nil {
+		log.Printf("payout: cannot publish %s for transaction %s: %s", typ, tx.ID, err)
+	}
+}
+
+// rejected reports whether err means the transfer was refused, by the provider
+// or by a limit before reaching it, so no funds moved. Other errors, such as
+// timeouts, leave the outcome unknown.
+func rejected(err error) bool {
+	return errors.Is(err, disbursement.ErrInvalid) ||
+		errors.Is(err, disbursement.ErrFunding) ||
+		errors.Is(err, disbursement.ErrLimitExceeded) ||
+		errors.Is(err, disbursement.ErrUnauthorized)
+}
@@ -0,0 +1,412 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeProvider answers transfers with err, or the error for their method in
+// errs, or with a result in state.
+type fakeProvider struct {
+	err      error
+	errs     map[disbursement.Method]error
+	state    disbursement.TransferState
+	refIDs   int
+	statuses map[string]disbursement.TransferState
+
+	mu        sync.Mutex
+	statusErr error
+	lookups   int
+
+	// gate, if set, holds every transfer until it receives a value.
+	gate chan struct{}
+
+	// seen is the transaction state observed when the provider was called.
+	store disbursement.TransactionStore
+	seen  []*disbursement.Transaction
+
+	// senders are the ids of the resolved senders of each transfer.
+	senders []string
+}
+
+func (p *fakeProvider) NewSenderRefID(d *disbursement.Disbursement) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refIDs++
+	return fmt.Sprintf("REF%d", p.refIDs)
+}
+
+func (p *fakeProvider) TransferFunds(ctx context.Context, method disbursement.Method, d *disbursement.Disbursement) (*disbursement.TransferResult, error) {
+	if p.gate != nil {
+		<-p.gate
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	txs, _ := p.store.FindTransactions(ctx, disbursement.TransactionFilter{SenderRefID: d.SenderRefID})
+	p.seen = append(p.seen, txs...)
+	if d.Sender != nil {
+		p.senders = append(p.senders, d.Sender.ID)
+	}
+	if p.err != nil {
+		return nil, p.err
+	} else if err := p.errs[method]; err != nil {
+		return nil, err
+	}
+	return &disbursement.TransferResult{
+		Method:                method,
+		ReferenceID:           d.SenderRefID,
+		ProviderTransactionID: "UB" + d.SenderRefID,
+		State:                 p.state,
+		ProviderState:         string(p.state),
+	}, nil
+}
+
+func (p *fakeProvider) GetBanks(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) GetStatus(ctx context.Context, method disbursement.Method, referenceID string) (*disbursement.TransferStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lookups++
+	if p.statusErr != nil {
+		return nil, p.statusErr
+	}
+	state, ok := p.statuses[referenceID]
+	if !ok {
+		state = disbursement.TransferProcessing
+	}
+	return &disbursement.TransferStatus{Method: method, ReferenceID: referenceID, State: state}, nil
+}
+
+// recordingPublisher keeps every event published.
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []*disbursement.Event
+}
+
+func (p *recordingPublisher) PublishEvent(ctx context.Context, e *disbursement.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, e)
+	return nil
+}
+
+func (p *recordingPublisher) types() []disbursement.EventType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var types []disbursement.EventType
+	for _, e := range p.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+// fakeCalendar settles transfers settlesIn after submission. Transfers
+// submitted before opens miss the cutoff.
+type fakeCalendar struct {
+	settlesIn time.Duration
+	opens     time.Time
+}
+
+func (c fakeCalendar) Settlement(method disbursement.Method, t time.Time) disbursement.Settlement {
+	if t.Before(c.opens) {
+		return disbursement.Settlement{ExpectedAt: t.Add(c.settlesIn), AfterCutoff: true, NextWindow: c.opens}
+	}
+	return disbursement.Settlement{ExpectedAt: t.Add(c.settlesIn), NextWindow: t}
+}
+
+// fakeLimiter admits up to max transfers at once.
+type fakeLimiter struct {
+	max      int
+	reserved map[string]bool
+}
+
+func (l *fakeLimiter) Reserve(ctx context.Context, id string, method disbursement.Method, d *disbursement.Disbursement) error {
+	if len(l.reserved) >= l.max {
+		return &disbursement.LimitError{Limit: disbursement.Limit{Scope: disbursement.LimitPerClient, Window: disbursement.LimitDay, MaxCount: l.max}, Count: l.max}
+	}
+	l.reserved[id] = true
+	return nil
+}
+
+func (l *fakeLimiter) Release(ctx context.Context, id string) error {
+	delete(l.reserved, id)
+	return nil
+}
+
+// failingStore refuses to create transactions.
+type failingStore struct{ disbursement.TransactionStore }
+
+func (failingStore) CreateTransaction(ctx context.Context, tx *disbursement.Transaction) error {
+	return errors.New("disk full")
+}
+
+func TestService_TransferFunds(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("transfers are recorded before and after the provider call", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferCredited, store: store}
+		s := NewService(provider, store)
+
+		result, err := s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{ReferenceID: "INV-1"})
+		So(err, ShouldBeNil)
+		So(result.TransactionID, ShouldNotBeEmpty)
+
+		So(provider.seen, ShouldHaveLength, 1)
+		So(provider.seen[0].State, ShouldEqual, disbursement.TransferPending)
+		So(provider.seen[0].SenderRefID, ShouldEqual, "REF1")
+
+		tx, err := store.FindTransactionByID(ctx, result.TransactionID)
+		So(err, ShouldBeNil)
+		So(tx.State, ShouldEqual, disbursement.TransferCredited)
+		So(tx.ProviderTransactionID, ShouldEqual, "UBREF1")
+		So(tx.Request.ReferenceID, ShouldEqual, "INV-1")
+		So(tx.History, ShouldHaveLength, 2)
+	})
+
+	Convey("transfers that missed the cutoff carry their settlement and a warning", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: store}
+		s := NewService(provider, store)
+		s.Calendar = fakeCalendar{settlesIn: 26 * time.Hour, opens: time.Now().Add(9 * time.Hour)}
+
+		result, err := s.TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+		So(err, ShouldBeNil)
+		So(result.ExpectedSettlementAt, ShouldNotBeNil)
+		So(result.Warnings, ShouldHaveLength, 1)
+		So(result.Warnings[0], ShouldStartWith, "submitted after the pesonet cutoff")
+
+		tx, err := store.FindTransactionByID(ctx, result.TransactionID)
+		So(err, ShouldBeNil)
+		So(*tx.ExpectedSettlementAt, ShouldEqual, tx.CreatedAt.Add(26*time.Hour))
+	})
+
+	Convey("rejected transfers are recorded as failed", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{err: fmt.Errorf("%w: bad account", disbursement.ErrInvalid), store: store}
+		_, err := NewService(provider, store).TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs, ShouldHaveLength, 1)
+		So(txs[0].State, ShouldEqual, disbursement.TransferFailed)
+		So(txs[0].Error, ShouldContainSubstring, "bad account")
+	})
+
+	Convey("transfers with an unknown outcome stay pending", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{err: fmt.Errorf("%w: timeout", disbursement.ErrRetryable), store: store}
+		NewService(provider, store).TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs[0].State, ShouldEqual, disbursement.TransferPending)
+		So(txs[0].Error, ShouldContainSubstring, "timeout")
+		So(txs[0].SenderRefID, ShouldEqual, "REF1")
+	})
+
+	Convey("transfers over a limit are neither recorded nor sent", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: store}
+		limits := &fakeLimiter{max: 1, reserved: map[string]bool{}}
+		s := NewService(provider, store)
+		s.Limits = limits
+
+		first, err := s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{})
+		So(err, ShouldBeNil)
+		So(limits.reserved[first.TransactionID], ShouldBeTrue)
+
+		_, err = s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{})
+		So(errors.Is(err, disbursement.ErrLimitExceeded), ShouldBeTrue)
+		So(provider.seen, ShouldHaveLength, 1)
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs, ShouldHaveLength, 1)
+
+		Convey("until an earlier transfer fails", func() {
+			provider.statuses = map[string]disbursement.TransferState{first.ReferenceID: disbursement.TransferFailed}
+			_, err := s.GetStatus(ctx, disbursement.MethodInstapay, first.ReferenceID)
+			So(err, ShouldBeNil)
+			So(limits.reserved, ShouldBeEmpty)
+
+			_, err = s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{})
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("transfers are not sent if they cannot be recorded", t, func() {
+		provider := &fakeProvider{state: disbursement.TransferCredited, store: inmem.NewTransactionStore()}
+		_, err := NewService(provider, failingStore{}).TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+		So(err, ShouldNotBeNil)
+		So(provider.seen, ShouldBeEmpty)
+	})
+}
+
+func TestService_Fallback(t *testing.T) {
+	ctx := context.Background()
+	route := &disbursement.Route{Method: disbursement.MethodInstapay, Fallback: disbursement.MethodPesonet}
+
+	Convey("routed transfers fall back when the provider never received them", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{
+			state:     disbursement.TransferProcessing,
+			errs:      map[disbursement.Method]error{disbursement.MethodInstapay: disbursement.ErrRetryable},
+			statusErr: disbursement.ErrNotFound,
+			store:     store,
+		}
+
+		result, err := NewService(provider, store).TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{Route: route})
+		So(err, ShouldBeNil)
+		So(result.Method, ShouldEqual, disbursement.MethodPesonet)
+
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs, ShouldHaveLength, 2)
+		So(txs[0].State, ShouldEqual, disbursement.TransferFailed)
+		So(txs[0].Error, ShouldContainSubstring, "not received")
+		So(txs[1].Method, ShouldEqual, disbursement.MethodPesonet)
+		So(txs[1].SenderRefID, ShouldNotEqual, txs[0].SenderRefID)
+		So(txs[1].Route.Reason, ShouldContainSubstring, "instapay was unavailable")
+	})
+
+	Convey("routed transfers do not fall back if the provider may have them", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{
+			errs:  map[disbursement.Method]error{disbursement.MethodInstapay: disbursement.ErrRetryable},
+			store: store,
+		}
+
+		_, err := NewService(provider, store).TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{Route: route})
+		So(errors.Is(err, disbursement.ErrRetryable), ShouldBeTrue)
+
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs, ShouldHaveLength, 1)
+		So(txs[0].State, ShouldEqual, disbursement.TransferPending)
+	})
+
+	Convey("routed transfers do not fall back on a not found response of the provider's API", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{
+			errs:      map[disbursement.Method]error{disbursement.MethodInstapay: disbursement.ErrRetryable},
+			statusErr: providerNotFound{},
+			store:     store,
+		}
+
+		_, err := NewService(provider, store).TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{Route: route})
+		So(errors.Is(err, disbursement.ErrRetryable), ShouldBeTrue)
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs, ShouldHaveLength, 1)
+	})
+
+	Convey("routed transfers do not fall back to a rail that would not accept them", t, func() {
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{
+			state:     disbursement.TransferProcessing,
+			errs:      map[disbursement.Method]error{disbursement.MethodInstapay: disbursement.ErrRetryable},
+			statusErr: disbursement.ErrNotFound,
+			store:     store,
+		}
+		s := NewService(provider, store)
+
+		Convey("because the transfer is invalid there", func() {
+			s.Validator = &disbursement.Validator{}
+		})
+
+		Convey("or needs an approval there", func() {
+			s.Approvals = railApprovals{method: disbursement.MethodPesonet}
+		})
+
+		_, err := s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{Route: route})
+		So(errors.Is(err, disbursement.ErrRetryable), ShouldBeTrue)
+		txs, _ := store.FindTransactions(ctx, disbursement.TransactionFilter{})
+		So(txs, ShouldHaveLength, 1)
+		So(txs[0].State, ShouldEqual, disbursement.TransferPending)
+	})
+}
+
+// providerNotFound is a not found response of a provider's API.
+type providerNotFound struct{}
+
+func (providerNotFound) Error() string              { return "404 not found" }
+func (providerNotFound) Is(target error) bool       { return target == disbursement.ErrNotFound }
+func (providerNotFound) ErrorCode() string          { return "" }
+func (providerNotFound) ErrorCorrelationID() string { return "" }
+
+// railApprovals holds every transfer through method for approval.
+type railApprovals struct {
+	disbursement.ApprovalService
+	method disbursement.Method
+}
+
+func (a railApprovals) RequiresApproval(method disbursement.Method, d *disbursement.Disbursement) bool {
+	return method == a.method
+}
+
+func TestService_GetStatus(t *testing.T) {
+	Convey("status lookups update the recorded transaction", t, func() {
+		ctx := context.Background()
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: store}
+		s := NewService(provider, store)
+
+		result, err := s.TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+		So(err, ShouldBeNil)
+
+		provider.statuses = map[string]disbursement.TransferState{result.ReferenceID: disbursement.TransferReturned}
+		status, err := s.GetStatus(ctx, disbursement.MethodPesonet, result.ReferenceID)
+		So(err, ShouldBeNil)
+		So(status.State, ShouldEqual, disbursement.TransferReturned)
+
+		tx, _ := store.FindTransactionByID(ctx, result.TransactionID)
+		So(tx.State, ShouldEqual, disbursement.TransferReturned)
+		So(tx.History, ShouldHaveLength, 3)
+	})
+
+	Convey("creation and final states are announced once", t, func() {
+		ctx := context.Background()
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferCredited, store: store}
+		events := &recordingPublisher{}
+		s := NewService(provider, store)
+		s.Events = events
+
+		result, _ := s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{})
+		provider.statuses = map[string]disbursement.TransferState{result.ReferenceID: disbursement.TransferCredited}
+		s.GetStatus(ctx, disbursement.MethodInstapay, result.ReferenceID)
+		So(events.types(), ShouldResemble, []disbursement.EventType{disbursement.EventTransferCreated, disbursement.EventTransferCredited})
+	})
+
+	Convey("racing status lookups announce a final state once and never undo it", t, func() {
+		ctx := context.Background()
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: store}
+		events := &recordingPublisher{}
+		s := NewService(provider, store)
+		s.Events = events
+
+		result, _ := s.TransferFunds(ctx, disbursement.MethodInstapay, &disbursement.Disbursement{})
+		stale, _ := store.FindTransactionByID(ctx, result.TransactionID)
+		provider.statuses = map[string]disbursement.TransferState{result.ReferenceID: disbursement.TransferCredited}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.GetStatus(ctx, disbursement.MethodInstapay, result.ReferenceID)
+			}()
+		}
+		wg.Wait()
+		So(events.types(), ShouldResemble, []disbursement.EventType{disbursement.EventTransferCreated, disbursement.EventTransferCredited})
+
+		So(s.updateStatus(ctx, stale.ID, &disbursement.TransferStatus{State: disbursement.TransferProcessing}), ShouldBeNil)
+		tx, _ := store.FindTransactionByID(ctx, result.TransactionID)
+		So(tx.State, ShouldEqual, disbursement.TransferCredited)
+	})
+}
@@ -0,0 +1,225 @@
+package payout
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Reconciler defaults.
+const (
+	DefaultScanInterval   = 30 * time.Second
+	DefaultConcurrency    = 4
+	DefaultMaxPollBackoff = time.Hour
+	DefaultEscalateAfter  = 72 * time.Hour
+)
+
+// DefaultPollIntervals is how often an unsettled transfer is checked on each
+// rail. PESONet settles in batches, so polling it often is wasted effort.
+var DefaultPollIntervals = map[disbursement.Method]time.Duration{
+	disbursement.MethodInstapay: time.Minute,
+	disbursement.MethodPesonet:  15 * time.Minute,
+	disbursement.MethodUBP:      time.Minute,
+}
+
+// Reconciler polls the provider for transfers that have not reached a final
+// state and records their progress through its Service, which announces
+// final states.
+type Reconciler struct {
+	Service *Service
+
+	// ScanInterval is how often the store is scanned for transfers due
+	// for a check.
+	ScanInterval time.Duration
+
+	// PollIntervals is how long to wait between checks of one transfer,
+	// per rail.
+	PollIntervals map[disbursement.Method]time.Duration
+
+	// Concurrency caps the number of status lookups in flight.
+	Concurrency int
+
+	// MaxBackoff caps the wait after failed lookups, which doubles with
+	// each consecutive failure.
+	MaxBackoff time.Duration
+
+	// EscalateAfter is how long a transfer may stay unsettled past its
+	// expected settlement, or its creation if that is unknown, before it
+	// is escalated to an operator. Escalated transfers are still polled.
+	EscalateAfter time.Duration
+
+	mu    sync.Mutex
+	polls map[string]poll
+
+	now func() time.Time
+}
+
+// poll is the schedule of checks of one transaction.
+type poll struct {
+	next     time.Time
+	failures int
+}
+
+func NewReconciler(s *Service) *Reconciler {
+	return &Reconciler{
+		Service:       s,
+		ScanInterval:  DefaultScanInterval,
+		PollIntervals: DefaultPollIntervals,
+		Concurrency:   DefaultConcurrency,
+		MaxBackoff:    DefaultMaxPollBackoff,
+		EscalateAfter: DefaultEscalateAfter,
+		polls:         make(map[string]poll),
+		now:           time.Now,
+	}
+}
+
+// Run reconciles every ScanInterval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.ScanInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.Reconcile(ctx); err != nil {
+			log.Printf("payout: reconcile: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile checks every unsettled transfer that is due, escalating those
+// that have been unsettled for longer than EscalateAfter.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	var txs []*disbursement.Transaction
+	for _, state := range []disbursement.TransferState{disbursement.TransferPending, disbursement.TransferProcessing} {
+		found, err := r.Service.Transactions.FindTransactions(ctx, disbursement.TransactionFilter{State: state})
+		if err != nil {
+			return err
+		}
+		txs = append(txs, found...)
+	}
+
+	now := r.now()
+	due := r.schedule(txs, now)
+
+	for _, tx := range txs {
+		if tx.EscalatedAt == nil && now.Sub(dueAt(tx)) >= r.EscalateAfter {
+			r.escalate(ctx, tx, now)
+		}
+	}
+
+	sem := make(chan struct{}, r.Concurrency)
+	var wg sync.WaitGroup
+	for _, tx := range due {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(tx *disbursement.Transaction) {
+			defer func() { <-sem; wg.Done() }()
+			r.check(ctx, tx)
+		}(tx)
+	}
+	wg.Wait()
+	return nil
+}
+
+// schedule returns the transactions due for a check and forgets the
+// schedules of those that are no longer unsettled.
+func (r *Reconciler) schedule(txs []*disbursement.Transaction, now time.Time) []*disbursement.Transaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	polls := make(map[string]poll, len(txs))
+	var due []*disbursement.Transaction
+	for _, tx := range txs {
+		// Without a reference id the provider cannot be asked about it.
+		if tx.SenderRefID == "" {
+			continue
+		}
+		p, ok := r.polls[tx.ID]
+		if !ok {
+			p.next = tx.UpdatedAt.Add(r.interval(tx.Method))
+			// Polling a transfer before it can have settled is wasted
+			// effort, such as a PESONet transfer over a long weekend.
+			if exp := tx.ExpectedSettlementAt; exp != nil && p.next.Before(*exp) {
+				p.next = *exp
+			}
+		}
+		polls[tx.ID] = p
+		if !now.Before(p.next) {
+			due = append(due, tx)
+		}
+	}
+	r.polls = polls
+	return due
+}
+
+// check looks up the status of tx and schedules its next check.
+func (r *Reconciler) check(ctx context.Context, tx *disbursement.Transaction) {
+	status, err := r.Service.Provider.GetStatus(ctx, tx.Method, tx.SenderRefID)
+	if err == nil {
+		err = r.Service.updateStatus(ctx, tx.ID, status)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.polls[tx.ID]
+	wait := r.interval(tx.Method)
+	if err != nil {
+		log.Printf("payout: cannot check transaction %s: %s", tx.ID, err)
+		p.failures++
+		for i := 0; i < p.failures && wait < r.MaxBackoff; i++ {
+			wait *= 2
+		}
+		if wait > r.MaxBackoff {
+			wait = r.MaxBackoff
+		}
+	} else {
+		p.failures = 0
+	}
+	p.next = r.now().Add(wait)
+	r.polls[tx.ID] = p
+}
+
+func (r *Reconciler) interval(method disbursement.Method) time.Duration {
+	if d, ok := r.PollIntervals[method]; ok {
+		return d
+	}
+	return DefaultPollIntervals[disbursement.MethodInstapay]
+}
+
+// dueAt returns when tx was expected to settle: its expected settlement time
+// if known, otherwise its creation.
+func dueAt(tx *disbursement.Transaction) time.Time {
+	if exp := tx.ExpectedSettlementAt; exp != nil && exp.After(tx.CreatedAt) {
+		return *exp
+	}
+	return tx.CreatedAt
+}
+
+// escalate marks tx as escalated and announces it, unless it settled or was
+// escalated since it was scanned.
+func (r *Reconciler) escalate(ctx context.Context, tx *disbursement.Transaction, now time.Time) {
+	escalated, _, err := r.Service.update(ctx, tx.ID, func(tx *disbursement.Transaction) bool {
+		if tx.EscalatedAt != nil || tx.State.Final() {
+			return false
+		}
+		tx.EscalatedAt = &now
+		tx.UpdatedAt = now
+		return true
+	})
+	if err != nil {
+		log.Printf("payout: cannot escalate transaction %s: %s", tx.ID, err)
+	} else if escalated != nil {
+		r.Service.publish(ctx, disbursement.EventTransferEscalated, escalated)
+	}
+}
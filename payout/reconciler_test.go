@@ -0,0 +1,116 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReconciler(t *testing.T) {
+	Convey("given a PESONet transfer sent for processing", t, func() {
+		ctx := context.Background()
+		now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+		clock := func() time.Time { return now }
+
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: store}
+		events := &recordingPublisher{}
+		s := NewService(provider, store)
+		s.Events = events
+		s.now = clock
+
+		result, err := s.TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+		So(err, ShouldBeNil)
+
+		r := NewReconciler(s)
+		r.now = clock
+		r.PollIntervals = map[disbursement.Method]time.Duration{disbursement.MethodPesonet: 10 * time.Minute}
+		state := func() disbursement.TransferState {
+			tx, _ := store.FindTransactionByID(ctx, result.TransactionID)
+			return tx.State
+		}
+
+		Convey("it is not checked before its poll interval", func() {
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(provider.lookups, ShouldEqual, 0)
+		})
+
+		Convey("it settles once the provider credits it", func() {
+			now = now.Add(10 * time.Minute)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(provider.lookups, ShouldEqual, 1)
+			So(state(), ShouldEqual, disbursement.TransferProcessing)
+
+			provider.statuses = map[string]disbursement.TransferState{result.ReferenceID: disbursement.TransferCredited}
+			now = now.Add(10 * time.Minute)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(state(), ShouldEqual, disbursement.TransferCredited)
+			So(events.types(), ShouldResemble, []disbursement.EventType{disbursement.EventTransferCreated, disbursement.EventTransferCredited})
+
+			now = now.Add(time.Hour)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(provider.lookups, ShouldEqual, 2)
+		})
+
+		Convey("failed lookups back off", func() {
+			provider.statusErr = errors.New("unavailable")
+			now = now.Add(10 * time.Minute)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(provider.lookups, ShouldEqual, 1)
+
+			now = now.Add(10 * time.Minute)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(provider.lookups, ShouldEqual, 1)
+
+			now = now.Add(10 * time.Minute)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			So(provider.lookups, ShouldEqual, 2)
+		})
+
+		Convey("it is escalated once after the threshold", func() {
+			provider.statusErr = errors.New("unavailable")
+			now = now.Add(r.EscalateAfter)
+			So(r.Reconcile(ctx), ShouldBeNil)
+			now = now.Add(time.Hour)
+			So(r.Reconcile(ctx), ShouldBeNil)
+
+			So(events.types(), ShouldResemble, []disbursement.EventType{disbursement.EventTransferCreated, disbursement.EventTransferEscalated})
+			tx, _ := store.FindTransactionByID(ctx, result.TransactionID)
+			So(tx.EscalatedAt, ShouldNotBeNil)
+		})
+	})
+
+	Convey("a transfer settling on a later banking day is not polled before then", t, func() {
+		ctx := context.Background()
+		now := time.Date(2020, 6, 5, 16, 0, 0, 0, time.UTC)
+		clock := func() time.Time { return now }
+
+		store := inmem.NewTransactionStore()
+		provider := &fakeProvider{state: disbursement.TransferProcessing, store: store}
+		events := &recordingPublisher{}
+		s := NewService(provider, store)
+		s.Events = events
+		s.Calendar = fakeCalendar{settlesIn: 72 * time.Hour, opens: now.Add(56 * time.Hour)}
+		s.now = clock
+		_, err := s.TransferFunds(ctx, disbursement.MethodPesonet, &disbursement.Disbursement{})
+		So(err, ShouldBeNil)
+
+		r := NewReconciler(s)
+		r.now = clock
+		r.EscalateAfter = 24 * time.Hour
+
+		now = now.Add(71 * time.Hour)
+		So(r.Reconcile(ctx), ShouldBeNil)
+		So(provider.lookups, ShouldEqual, 0)
+
+		now = now.Add(time.Hour)
+		So(r.Reconcile(ctx), ShouldBeNil)
+		So(provider.lookups, ShouldEqual, 1)
+		So(events.types(), ShouldResemble, []disbursement.EventType{disbursement.EventTransferCreated})
+	})
+}
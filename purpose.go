@@ -0,0 +1,13 @@
+package disbursement
+
+// Purpose is a transfer purpose code of the partner agreement. Every InstaPay
+// and PESONet transfer must declare one.
+type Purpose struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// DefaultPurposeCode is used when neither the request nor its sender
+// specifies a purpose. It is the code every transfer was sent with before
+// the purpose could be chosen.
+const DefaultPurposeCode = "1001"
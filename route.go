@@ -0,0 +1,55 @@
+package disbursement
+
+import "context"
+
+// Urgency is how soon a routed transfer must reach the receiver.
+type Urgency string
+
+const (
+	// UrgencyStandard accepts any rail, including one that settles on the
+	// next banking day.
+	UrgencyStandard Urgency = "standard"
+
+	// UrgencySameDay requires a rail that settles today: a real-time rail,
+	// or a batch rail before its cutoff.
+	UrgencySameDay Urgency = "same_day"
+
+	// UrgencyImmediate requires a real-time rail.
+	UrgencyImmediate Urgency = "immediate"
+)
+
+// ParseUrgency returns the urgency named s. An empty s is UrgencyStandard.
+func ParseUrgency(s string) (Urgency, error) {
+	switch u := Urgency(s); u {
+	case "":
+		return UrgencyStandard, nil
+	case UrgencyStandard, UrgencySameDay, UrgencyImmediate:
+		return u, nil
+	}
+	return "", &ValidationError{Errors: []FieldError{{Field: "urgency", Message: "must be standard, same_day or immediate"}}}
+}
+
+// Route is the rail chosen for a transfer and why.
+type Route struct {
+	Method Method `json:"method"`
+	Reason string `json:"reason"`
+
+	// Fallback is the rail to try if Method turns out to be unavailable
+	// before the provider accepted the transfer. Empty if there is none.
+	Fallback Method `json:"fallback,omitempty"`
+
+	// Skipped lists the rails that could not take the transfer.
+	Skipped []SkippedRail `json:"skipped,omitempty"`
+}
+
+// SkippedRail is a rail ruled out for a transfer.
+type SkippedRail struct {
+	Method Method `json:"method"`
+	Reason string `json:"reason"`
+}
+
+// RailSelector chooses the rail of a transfer. It returns an error matching
+// ErrInvalid if no rail can send d.
+type RailSelector interface {
+	SelectRail(ctx context.Context, d *Disbursement, urgency Urgency) (*Route, error)
+}
@@ -0,0 +1,231 @@
+// Package routing chooses between rails for transfers whose caller left the
+// choice to the service. Rails are ruled out by amount caps, bank
+// participation and urgency, and the remaining ones are ranked by fee.
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// manila is the zone cutoff times are given in.
+var manila = time.FixedZone("PHT", 8*60*60)
+
+// Rail holds the rules of one rail.
+type Rail struct {
+	Method disbursement.Method `json:"method"`
+
+	// MaxAmount is the largest transfer the rail takes. Nil means no cap.
+	MaxAmount *disbursement.Money `json:"max_amount,omitempty"`
+
+	// Fee is charged for each transfer. Nil means free.
+	Fee *disbursement.Money `json:"fee,omitempty"`
+
+	// RealTime means the receiver is credited within seconds, at any
+	// time of day.
+	RealTime bool `json:"real_time"`
+
+	// Cutoff is the time of day, as "15:04" Philippine time, after which
+	// transfers settle on the next day. Ignored for real-time rails, and
+	// when the Selector has a Calendar.
+	Cutoff string `json:"cutoff,omitempty"`
+}
+
+// Rules are the rails to choose from, in order of preference between rails
+// with the same fee.
+type Rules struct {
+	Rails []Rail `json:"rails"`
+}
+
+// DefaultRules prefer PESONet, and InstaPay, capped as by the validator,
+// for transfers PESONet cannot send in time. They charge no fees; the fees
+// of the partner agreement belong in a rules file.
+var DefaultRules = Rules{Rails: []Rail{
+	{
+		Method: disbursement.MethodPesonet,
+		Cutoff: "15:00",
+	},
+	{
+		Method:    disbursement.MethodInstapay,
+		MaxAmount: maxAmount(disbursement.MethodInstapay),
+		RealTime:  true,
+	},
+}}
+
+func maxAmount(method disbursement.Method) *disbursement.Money {
+	m, ok := disbursement.DefaultMaxAmounts[method]
+	if !ok {
+		return nil
+	}
+	return &m
+}
+
+// LoadRules reads rules from the JSON file at path.
+func LoadRules(path string) (Rules, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	var rules Rules
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return Rules{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := rules.validate(); err != nil {
+		return Rules{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Fees returns the fee of each rail that charges one, so transfers are
+// booked at the fee their rail was chosen on.
+func (r Rules) Fees() map[disbursement.Method]disbursement.Money {
+	fees := make(map[disbursement.Method]disbursement.Money, len(r.Rails))
+	for _, rail := range r.Rails {
+		if _, ok := fees[rail.Method]; !ok && rail.Fee != nil {
+			fees[rail.Method] = *rail.Fee
+		}
+	}
+	return fees
+}
+
+func (r Rules) validate() error {
+	if len(r.Rails) == 0 {
+		return fmt.Errorf("%w: no rails", disbursement.ErrInvalid)
+	}
+	for _, rail := range r.Rails {
+		if _, err := disbursement.ParseMethod(string(rail.Method)); err != nil {
+			return err
+		}
+		if rail.Cutoff != "" {
+			if _, err := time.Parse("15:04", rail.Cutoff); err != nil {
+				return fmt.Errorf("%w: %s cutoff %q is not HH:MM", disbursement.ErrInvalid, rail.Method, rail.Cutoff)
+			}
+		}
+	}
+	return nil
+}
+
+// Selector is a disbursement.RailSelector applying Rules.
+type Selector struct {
+	Rules Rules
+
+	// Banks, if set, rules out rails the receiving bank does not take
+	// part in.
+	Banks disbursement.BankLookup
+
+	// Calendar, if set, decides whether a batch rail can still settle
+	// today, taking banking holidays into account. Otherwise each rail's
+	// Cutoff is used.
+	Calendar disbursement.Calendar
+
+	now func() time.Time
+}
+
+func NewSelector(rules Rules, banks disbursement.BankLookup) *Selector {
+	return &Selector{Rules: rules, Banks: banks, now: time.Now}
+}
+
+// SelectRail returns the cheapest rail that can take d with the given
+// urgency, or the preferred one of those as cheap, with the next one as its
+// fallback.
+func (s *Selector) SelectRail(ctx context.Context, d *disbursement.Disbursement, urgency disbursement.Urgency) (*disbursement.Route, error) {
+	route := &disbursement.Route{}
+	var eligible []Rail
+	for _, rail := range s.Rules.Rails {
+		reason, err := s.check(ctx, rail, d, urgency)
+		if err != nil {
+			return nil, err
+		} else if reason != "" {
+			route.Skipped = append(route.Skipped, disbursement.SkippedRail{Method: rail.Method, Reason: reason})
+			continue
+		}
+		eligible = append(eligible, rail)
+	}
+
+	if len(eligible) == 0 {
+		reasons := make([]string, len(route.Skipped))
+		for i, sk := range route.Skipped {
+			reasons[i] = string(sk.Method) + ": " + sk.Reason
+		}
+		return nil, fmt.Errorf("%w: no rail can send this transfer (%s)", disbursement.ErrInvalid, strings.Join(reasons, "; "))
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return fee(eligible[i]).Cmp(fee(eligible[j])) < 0
+	})
+	chosen := eligible[0]
+	route.Method = chosen.Method
+	if len(eligible) > 1 {
+		route.Fallback = eligible[1].Method
+		if fee(chosen).Cmp(fee(eligible[1])) < 0 {
+			route.Reason = fmt.Sprintf("lowest fee (%s) of the rails able to send it", fee(chosen))
+		} else {
+			route.Reason = "preferred of the rails able to send it"
+		}
+	} else {
+		route.Reason = "only rail able to send it"
+	}
+	return route, nil
+}
+
+// check returns why rail cannot take d, or an empty string if it can.
+func (s *Selector) check(ctx context.Context, rail Rail, d *disbursement.Disbursement, urgency disbursement.Urgency) (string, error) {
+	if rail.MaxAmount != nil && d.Details.Amount.Cmp(*rail.MaxAmount) > 0 {
+		return fmt.Sprintf("amount exceeds the limit of %s", rail.MaxAmount), nil
+	}
+
+	switch urgency {
+	case disbursement.UrgencyImmediate:
+		if !rail.RealTime {
+			return "does not credit immediately", nil
+		}
+	case disbursement.UrgencySameDay:
+		if rail.RealTime {
+			break
+		}
+		if s.Calendar != nil {
+			if st := s.Calendar.Settlement(rail.Method, s.now()); st.AfterCutoff {
+				return fmt.Sprintf("settles on %s", st.ExpectedAt.In(manila).Format("Mon 2 Jan")), nil
+			}
+		} else if s.pastCutoff(rail) {
+			return fmt.Sprintf("past its %s cutoff", rail.Cutoff), nil
+		}
+	}
+
+	if s.Banks != nil && rail.Method.Interbank() {
+		ok, err := s.Banks.HasBank(ctx, rail.Method, d.Details.ReceivingBank)
+		if err != nil {
+			return "", err
+		} else if !ok {
+			return fmt.Sprintf("bank %s does not participate", d.Details.ReceivingBank), nil
+		}
+	}
+	return "", nil
+}
+
+// pastCutoff reports whether rail's cutoff for today has passed.
+func (s *Selector) pastCutoff(rail Rail) bool {
+	if rail.Cutoff == "" {
+		return false
+	}
+	cutoff, err := time.Parse("15:04", rail.Cutoff)
+	if err != nil {
+		return true
+	}
+	now := s.now().In(manila)
+	return now.Hour()*60+now.Minute() >= cutoff.Hour()*60+cutoff.Minute()
+}
+
+func fee(rail Rail) disbursement.Money {
+	if rail.Fee == nil {
+		return disbursement.Money{Currency: disbursement.DefaultCurrency}
+	}
+	return *rail.Fee
+}
@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/calendar"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeBanks lists the banks taking part in each rail.
+type fakeBanks map[disbursement.Method][]string
+
+func (b fakeBanks) HasBank(ctx context.Context, method disbursement.Method, code string) (bool, error) {
+	for _, c := range b[method] {
+		if c == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestSelector(t *testing.T) {
+	Convey("given the default rules", t, func() {
+		ctx := context.Background()
+		banks := fakeBanks{
+			disbursement.MethodInstapay: {"161408", "010419"},
+			disbursement.MethodPesonet:  {"161408", "040015"},
+		}
+		s := NewSelector(DefaultRules, banks)
+		s.now = func() time.Time { return time.Date(2026, 10, 19, 10, 0, 0, 0, manila) }
+
+		transfer := func(amount int64, bank string) *disbursement.Disbursement {
+			return &disbursement.Disbursement{Details: disbursement.Details{
+				Amount:        disbursement.Money{Minor: amount * 100, Currency: "PHP"},
+				ReceivingBank: bank,
+			}}
+		}
+
+		Convey("the preferred rail is chosen, with the other as fallback", func() {
+			route, err := s.SelectRail(ctx, transfer(1000, "161408"), disbursement.UrgencyStandard)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodPesonet)
+			So(route.Fallback, ShouldEqual, disbursement.MethodInstapay)
+			So(route.Reason, ShouldContainSubstring, "preferred")
+		})
+
+		Convey("the cheapest rail is chosen if the rails charge fees", func() {
+			s.Rules = Rules{Rails: []Rail{
+				{Method: disbursement.MethodPesonet, Fee: &disbursement.Money{Minor: 1000, Currency: "PHP"}},
+				{Method: disbursement.MethodInstapay, Fee: &disbursement.Money{Minor: 500, Currency: "PHP"}, RealTime: true},
+			}}
+			route, err := s.SelectRail(ctx, transfer(1000, "161408"), disbursement.UrgencyStandard)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodInstapay)
+			So(route.Reason, ShouldContainSubstring, "lowest fee (5.00)")
+		})
+
+		Convey("amounts over the InstaPay cap go through PESONet", func() {
+			route, err := s.SelectRail(ctx, transfer(75000, "161408"), disbursement.UrgencyStandard)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodPesonet)
+			So(route.Fallback, ShouldBeEmpty)
+			So(route.Skipped, ShouldHaveLength, 1)
+			So(route.Skipped[0].Reason, ShouldContainSubstring, "exceeds the limit")
+		})
+
+		Convey("banks outside a rail rule it out", func() {
+			route, err := s.SelectRail(ctx, transfer(1000, "010419"), disbursement.UrgencyStandard)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodInstapay)
+		})
+
+		Convey("urgent transfers need a rail that settles in time", func() {
+			route, err := s.SelectRail(ctx, transfer(1000, "161408"), disbursement.UrgencyImmediate)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodInstapay)
+
+			route, err = s.SelectRail(ctx, transfer(1000, "161408"), disbursement.UrgencySameDay)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodPesonet)
+
+			s.now = func() time.Time { return time.Date(2026, 10, 19, 16, 0, 0, 0, manila) }
+			route, err = s.SelectRail(ctx, transfer(1000, "161408"), disbursement.UrgencySameDay)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodInstapay)
+		})
+
+		Convey("a calendar rules out batch rails on banking holidays", func() {
+			cal, err := calendar.New(calendar.Config{
+				Holidays: []calendar.Holiday{{Date: "2026-11-30", Name: "Bonifacio Day"}},
+				Rails:    map[disbursement.Method]calendar.Rail{disbursement.MethodPesonet: {Cutoff: "15:00"}},
+			})
+			So(err, ShouldBeNil)
+			s.Calendar = cal
+			s.now = func() time.Time { return time.Date(2026, 11, 30, 10, 0, 0, 0, manila) }
+
+			route, err := s.SelectRail(ctx, transfer(1000, "161408"), disbursement.UrgencySameDay)
+			So(err, ShouldBeNil)
+			So(route.Method, ShouldEqual, disbursement.MethodInstapay)
+			So(route.Skipped, ShouldResemble, []disbursement.SkippedRail{
+				{Method: disbursement.MethodPesonet, Reason: "settles on Tue 1 Dec"},
+			})
+		})
+
+		Convey("transfers no rail can send are rejected with every reason", func() {
+			_, err := s.SelectRail(ctx, transfer(75000, "040015"), disbursement.UrgencyImmediate)
+			So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "instapay: amount exceeds")
+			So(err.Error(), ShouldContainSubstring, "pesonet: does not credit immediately")
+		})
+	})
+}
+
+func TestRulesFees(t *testing.T) {
+	Convey("the fees of the rules are those rails are chosen on", t, func() {
+		rules := Rules{Rails: []Rail{
+			{Method: disbursement.MethodInstapay, Fee: &disbursement.Money{Minor: 2500, Currency: "PHP"}},
+			{Method: disbursement.MethodPesonet},
+		}}
+		fees := rules.Fees()
+		So(fees[disbursement.MethodInstapay].String(), ShouldEqual, "25.00")
+		_, ok := fees[disbursement.MethodPesonet]
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("the default rules charge no fees", t, func() {
+		So(DefaultRules.Fees(), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,71 @@
+package disbursement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSenderNotFound is returned by a SenderService for an unknown sender id.
+var ErrSenderNotFound = errors.New("sender not found")
+
+// Sender is the business unit a disbursement is made on behalf of. Its name
+// and address are sent to the provider as the originator of the transfer.
+type Sender struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Address Address `json:"address"`
+
+	// DefaultPurpose is the purpose code of this sender's transfers that
+	// omit one. If empty the server default applies.
+	DefaultPurpose string `json:"defaultPurpose,omitempty"`
+
+	// Clients lists the API clients allowed to disburse as this sender. If
+	// empty, any client may use it.
+	Clients []string `json:"clients,omitempty"`
+}
+
+// Authorize returns ErrForbidden unless clientID may disburse as s.
+func (s *Sender) Authorize(clientID string) error {
+	if len(s.Clients) == 0 {
+		return nil
+	}
+	for _, c := range s.Clients {
+		if c == clientID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: client %q may not disburse as sender %q", ErrForbidden, clientID, s.ID)
+}
+
+// SenderService looks up sender profiles.
+type SenderService interface {
+	// FindSender returns the sender with the given id, or the default
+	// sender if id is empty. It returns ErrSenderNotFound if there is no
+	// such sender.
+	FindSender(ctx context.Context, id string) (*Sender, error)
+}
+
+// ResolveSender looks up the sender named by d.SenderID, checks that the
+// client calling with ctx may use it and attaches it to d.
+func ResolveSender(ctx context.Context, senders SenderService, d *Disbursement) error {
+	s, err := senders.FindSender(ctx, d.SenderID)
+	if errors.Is(err, ErrSenderNotFound) {
+		verr := &ValidationError{}
+		if d.SenderID == "" {
+			verr.add("sender_id", "is required")
+		} else {
+			verr.add("sender_id", "unknown sender %q", d.SenderID)
+		}
+		return verr
+	} else if err != nil {
+		return err
+	}
+
+	if err := s.Authorize(ClientIDFromContext(ctx)); err != nil {
+		return err
+	}
+	d.SenderID = s.ID
+	d.Sender = s
+	return nil
+}
@@ -0,0 +1,155 @@
+package disbursement
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Transaction records a disbursement attempt from before it is sent to the
+// provider until its transfer reaches a final state.
+type Transaction struct {
+	ID      string        `json:"id"`
+	Method  Method        `json:"method"`
+	Request *Disbursement `json:"request"`
+
+	// ClientID is the API client that requested the transfer.
+	ClientID string `json:"client_id,omitempty"`
+
+	// SenderRefID is the reference id sent to the provider. It is known
+	// before the provider is called when the provider implements
+	// SenderRefIDAssigner.
+	SenderRefID string `json:"sender_ref_id,omitempty"`
+
+	// ProviderTransactionID is the provider's own transaction id, known
+	// once the provider accepted the transfer.
+	ProviderTransactionID string `json:"provider_transaction_id,omitempty"`
+
+	State         TransferState `json:"state"`
+	ProviderState string        `json:"provider_state,omitempty"`
+
+	// Error is the last error returned while sending or following the
+	// transfer. A pending transaction with an error may or may not have
+	// reached the provider.
+	Error string `json:"error,omitempty"`
+
+	History []TransactionEvent `json:"history"`
+
+	// Route records why the transfer was sent through Method, if its rail
+	// was chosen automatically.
+	Route *Route `json:"route,omitempty"`
+
+	// ExpectedSettlementAt is when the receiver should be credited,
+	// according to the calendar of the rail at submission.
+	ExpectedSettlementAt *time.Time `json:"expected_settlement_at,omitempty"`
+
+	// EscalatedAt is when the transfer was handed to an operator for
+	// taking too long to settle.
+	EscalatedAt *time.Time `json:"escalated_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Version counts the updates of the transaction.
+	Version int `json:"version"`
+}
+
+// TransactionEvent is an entry in the state history of a Transaction.
+type TransactionEvent struct {
+	State         TransferState `json:"state"`
+	ProviderState string        `json:"provider_state,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	At            time.Time     `json:"at"`
+}
+
+// SetState moves tx to state at the given time, recording the change in its
+// history. It reports whether anything changed.
+func (tx *Transaction) SetState(state TransferState, providerState string, at time.Time) bool {
+	if state == tx.State && providerState == tx.ProviderState && tx.Error == "" && len(tx.History) > 0 {
+		return false
+	}
+	tx.State, tx.ProviderState, tx.Error = state, providerState, ""
+	tx.History = append(tx.History, TransactionEvent{State: state, ProviderState: providerState, At: at})
+	tx.UpdatedAt = at
+	return true
+}
+
+// SetError records err against tx without changing its state.
+func (tx *Transaction) SetError(err error, at time.Time) {
+	tx.Error = err.Error()
+	tx.History = append(tx.History, TransactionEvent{State: tx.State, ProviderState: tx.ProviderState, Error: tx.Error, At: at})
+	tx.UpdatedAt = at
+}
+
+// TransactionFilter selects transactions. Zero fields match everything.
+type TransactionFilter struct {
+	Method      Method
+	State       TransferState
+	ReferenceID string
+	SenderRefID string
+
+	// ClientID selects the transactions requested by one client.
+	ClientID string
+
+	// Limit caps the number of transactions returned. Zero means no limit.
+	Limit int
+}
+
+// Match reports whether tx is selected by f, ignoring Limit.
+func (f TransactionFilter) Match(tx *Transaction) bool {
+	switch {
+	case f.Method != "" && tx.Method != f.Method:
+		return false
+	case f.State != "" && tx.State != f.State:
+		return false
+	case f.SenderRefID != "" && tx.SenderRefID != f.SenderRefID:
+		return false
+	case f.ClientID != "" && tx.ClientID != f.ClientID:
+		return false
+	case f.ReferenceID != "" && (tx.Request == nil || tx.Request.ReferenceID != f.ReferenceID):
+		return false
+	}
+	return true
+}
+
+// TransactionStore persists transactions.
+type TransactionStore interface {
+	// CreateTransaction stores a new transaction, assigning its ID if it
+	// is empty.
+	CreateTransaction(ctx context.Context, tx *Transaction) error
+
+	// UpdateTransaction replaces a stored transaction and increments its
+	// Version. It returns ErrNotFound if tx was never created, and
+	// ErrConflict if tx is not the stored Version.
+	UpdateTransaction(ctx context.Context, tx *Transaction) error
+
+	// FindTransactionByID returns ErrNotFound if there is no such
+	// transaction.
+	FindTransactionByID(ctx context.Context, id string) (*Transaction, error)
+
+	// FindTransactions returns the transactions matching filter, oldest
+	// first.
+	FindTransactions(ctx context.Context, filter TransactionFilter) ([]*Transaction, error)
+}
+
+// SenderRefIDAssigner is implemented by a DisbursementService that can issue
+// the provider reference id of a transfer before sending it, so the id can be
+// recorded first. The id is passed back in Disbursement.SenderRefID.
+type SenderRefIDAssigner interface {
+	NewSenderRefID(d *Disbursement) string
+}
+
+// NewTransactionID returns a random transaction id.
+func NewTransactionID() string {
+	return newID("txn_")
+}
+
+// newID returns prefix followed by 24 random hex digits.
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,119 @@
+package disbursement
+
+import (
+	"fmt"
+	"time"
+)
+
+// Method is the rail a transfer is sent over.
+type Method string
+
+const (
+	// MethodInstapay sends real-time interbank transfers, capped per
+	// transaction.
+	MethodInstapay Method = "instapay"
+
+	// MethodPesonet sends batched interbank transfers settled within the
+	// banking day.
+	MethodPesonet Method = "pesonet"
+
+	// MethodUBP sends intrabank transfers between UnionBank accounts.
+	MethodUBP Method = "ubp"
+)
+
+// Methods lists every supported rail.
+var Methods = []Method{MethodInstapay, MethodPesonet, MethodUBP}
+
+// ParseMethod returns the Method named s, or an error matching ErrInvalid.
+func ParseMethod(s string) (Method, error) {
+	for _, m := range Methods {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unsupported disbursement method %q", ErrInvalid, s)
+}
+
+// Interbank reports whether m moves funds to other banks.
+func (m Method) Interbank() bool {
+	return m == MethodInstapay || m == MethodPesonet
+}
+
+// TransferState is the provider-neutral state of a transfer.
+type TransferState string
+
+const (
+	// TransferPending means the provider accepted the transfer but has not
+	// started processing it.
+	TransferPending TransferState = "pending"
+
+	// TransferProcessing means the transfer is on its way to the receiving
+	// bank.
+	TransferProcessing TransferState = "processing"
+
+	// TransferCredited means the receiver's account was credited.
+	TransferCredited TransferState = "credited"
+
+	// TransferFailed means the transfer was rejected and no funds moved.
+	TransferFailed TransferState = "failed"
+
+	// TransferReturned means the receiving bank sent the funds back after
+	// the transfer was accepted.
+	TransferReturned TransferState = "returned"
+)
+
+// Final reports whether s can no longer change.
+func (s TransferState) Final() bool {
+	return s == TransferCredited || s == TransferFailed || s == TransferReturned
+}
+
+// TransferResult is the outcome of submitting a transfer.
+type TransferResult struct {
+	// TransactionID identifies the recorded transaction, if the transfer
+	// was recorded.
+	TransactionID string `json:"transaction_id,omitempty"`
+
+	Method Method `json:"method"`
+
+	// ReferenceID identifies the transfer with the provider. Pass it to
+	// GetStatus to follow the transfer.
+	ReferenceID string `json:"reference_id"`
+
+	// ProviderTransactionID is the provider's own transaction id.
+	ProviderTransactionID string `json:"provider_transaction_id,omitempty"`
+
+	State TransferState `json:"state"`
+
+	// ProviderState is the state as reported by the provider, for support.
+	ProviderState string `json:"provider_state,omitempty"`
+
+	// Route is why the rail was chosen, if it was chosen automatically.
+	Route *Route `json:"route,omitempty"`
+
+	// ExpectedSettlementAt is when the receiver should be credited, if a
+	// calendar is configured.
+	ExpectedSettlementAt *time.Time `json:"expected_settlement_at,omitempty"`
+
+	// Warnings describe anything about the transfer the caller may not
+	// expect, such as missing the rail's cutoff.
+	Warnings []string `json:"warnings,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransferStatus is the current state of a previously submitted transfer.
+type TransferStatus struct {
+	Method                Method        `json:"method"`
+	ReferenceID           string        `json:"reference_id"`
+	ProviderTransactionID string        `json:"provider_transaction_id,omitempty"`
+	State                 TransferState `json:"state"`
+	ProviderState         string        `json:"provider_state,omitempty"`
+	CreatedAt             time.Time     `json:"created_at"`
+	UpdatedAt             time.Time     `json:"updated_at"`
+}
+
+// Bank is a bank that can receive transfers over a rail.
+type Bank struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
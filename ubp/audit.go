@@ -0,0 +1,117 @@
+package ubp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// redactedFields are removed from audited request and response bodies:
+// credentials, tokens and the personal details of senders and receivers.
+var redactedFields = map[string]bool{
+	"username":      true,
+	"password":      true,
+	"client_secret": true,
+	"access_token":  true,
+	"refresh_token": true,
+	"name":          true,
+	"address":       true,
+	"info":          true,
+	"remarks":       true,
+	"particulars":   true,
+}
+
+// maskedFields keep only their last four characters when audited.
+var maskedFields = map[string]bool{
+	"accountNumber": true,
+	"accountNo":     true,
+}
+
+// audit records a round trip to the UBP API in c.Audit.
+func (c *Client) audit(ctx context.Context, api *ApiCall, body, resBody []byte, err error, start time.Time) {
+	if c.Audit == nil {
+		return
+	}
+	path := api.Url
+	if u, perr := url.Parse(api.Url); perr == nil {
+		path = u.Path
+	}
+	details := map[string]interface{}{
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	if req := redact(body); req != nil {
+		details["request"] = req
+	}
+	if res := redact(resBody); res != nil {
+		details["response"] = res
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode != 0 {
+		details["status"] = apiErr.StatusCode
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	rerr := c.Audit.Record(ctx, &disbursement.AuditEntry{
+		At:      start,
+		Kind:    disbursement.AuditProviderCall,
+		Subject: path,
+		Action:  api.Method,
+		Details: disbursement.AuditDetails(details),
+	})
+	if rerr != nil {
+		// Auditing must not change the outcome of the call.
+		log.Printf("ubp: cannot audit call to %s: %s", path, rerr)
+	}
+}
+
+// redact returns a JSON or form encoded body with its sensitive fields
+// removed, or nil if it is empty.
+func redact(body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		return redactValue("", v)
+	}
+	if values, err := url.ParseQuery(string(body)); err == nil {
+		form := make(map[string]interface{}, len(values))
+		for k, vs := range values {
+			form[k] = redactValue(k, strings.Join(vs, ","))
+		}
+		return form
+	}
+	return "[unreadable body]"
+}
+
+// redactValue returns v, the value of field key, with sensitive fields
+// redacted at any depth.
+func redactValue(key string, v interface{}) interface{} {
+	switch {
+	case redactedFields[key]:
+		return "[redacted]"
+	case maskedFields[key]:
+		if s, ok := v.(string); ok && len(s) > 4 {
+			return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+		}
+		return "[redacted]"
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = redactValue(k, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue("", item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,190 @@
+package ubp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+	defaultMaxRetries     = 3
+	defaultMinBackoff     = 200 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+type ApiCall struct {
+	Url               string
+	Method            string
+	Body              io.Reader
+	AdditionalHeaders map[string]string
+
+	// Timeout overrides the client's per-attempt timeout when non-zero.
+	Timeout time.Duration
+}
+
+// Client sends requests to the UBP API. Each attempt is bounded by a timeout
+// and idempotent requests are retried with jittered exponential backoff on
+// errors matching disbursement.ErrRetryable. Requests that move money are
+// never retried.
+type Client struct {
+	HTTPClient *http.Client
+	Config     *Config
+
+	Timeout    time.Duration
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Audit, if set, records every attempt with credentials and personal
+	// data redacted.
+	Audit disbursement.AuditLog
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a Client using the timeouts and retry policy in conf.
+func NewClient(conf *Config) *Client {
+	c := &Client{
+		HTTPClient: &http.Client{},
+		Config:     conf,
+		Timeout:    defaultRequestTimeout,
+		MaxRetries: defaultMaxRetries,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		sleep:      sleepContext,
+	}
+	if conf.RequestTimeoutMs > 0 {
+		c.Timeout = time.Duration(conf.RequestTimeoutMs) * time.Millisecond
+	}
+	if conf.MaxRetries != 0 {
+		c.MaxRetries = conf.MaxRetries
+	}
+	return c
+}
+
+// Do sends api and decodes the JSON response body.
+func (c *Client) Do(ctx context.Context, api *ApiCall) (interface{}, error) {
+	var body []byte
+	if api.Body != nil {
+		b, err := ioutil.ReadAll(api.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	retries := 0
+	if isIdempotent(api.Method) && c.MaxRetries > 0 {
+		retries = c.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resBody, err := c.attempt(ctx, api, body)
+		c.audit(ctx, api, body, resBody, err, start)
+		if err == nil {
+			var response interface{}
+			if err := json.Unmarshal(resBody, &response); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal response body: %w", err)
+			}
+			return response, nil
+		}
+
+		if attempt >= retries || ctx.Err() != nil || !errors.Is(err, disbursement.ErrRetryable) {
+			return nil, err
+		}
+		if err := c.sleep(ctx, c.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt performs a single round trip. Failures are returned as an
+// *APIError unless ctx is done.
+func (c *Client) attempt(ctx context.Context, api *ApiCall, body []byte) ([]byte, error) {
+	timeout := c.Timeout
+	if api.Timeout > 0 {
+		timeout = api.Timeout
+	}
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(api.Method, api.Url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range api.AdditionalHeaders {
+		req.Header.Add(k, v)
+	}
+
+	c.Config.SetHeaders(req)
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
+		return nil, newTransportError(err)
+	}
+	defer res.Body.Close()
+
+	// Responses carry tokens and beneficiary details, so they are only
+	// recorded, redacted, by the audit log.
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, newTransportError(err)
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return nil, newAPIError(res, resBody)
+	}
+
+	return resBody, nil
+}
+
+// backoff returns a random delay between zero and the capped exponential
+// backoff for the given attempt.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.MinBackoff << uint(attempt)
+	if d <= 0 || d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
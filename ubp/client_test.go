@@ -0,0 +1,146 @@
+package ubp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestClient(url string) *Client {
+	conf := testConfig
+	conf.BaseUrl = url
+	conf.MaxRetries = 3
+	c := NewClient(&conf)
+	c.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	return c
+}
+
+func TestClient_Do(t *testing.T) {
+	Convey("idempotent requests are retried on 5xx responses", t, func() {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"totalRecords":0}`))
+		}))
+		defer srv.Close()
+
+		c := newTestClient(srv.URL)
+		resp, err := c.Do(context.Background(), &ApiCall{Method: http.MethodGet, Url: srv.URL})
+		So(err, ShouldBeNil)
+		So(resp, ShouldNotBeNil)
+		So(atomic.LoadInt32(&calls), ShouldEqual, 3)
+	})
+
+	Convey("retries give up after MaxRetries", t, func() {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		c := newTestClient(srv.URL)
+		_, err := c.Do(context.Background(), &ApiCall{Method: http.MethodGet, Url: srv.URL})
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(&calls), ShouldEqual, 4)
+	})
+
+	Convey("client errors are not retried", t, func() {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		c := newTestClient(srv.URL)
+		_, err := c.Do(context.Background(), &ApiCall{Method: http.MethodGet, Url: srv.URL})
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+	})
+
+	Convey("transfers are never retried", t, func() {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		c := newTestClient(srv.URL)
+		_, err := c.Do(context.Background(), &ApiCall{Method: http.MethodPost, Url: srv.URL, Body: strings.NewReader(`{}`)})
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+	})
+
+	Convey("each attempt is bounded by the call timeout", t, func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer srv.Close()
+
+		c := newTestClient(srv.URL)
+		c.MaxRetries = -1
+		start := time.Now()
+		_, err := c.Do(context.Background(), &ApiCall{Method: http.MethodGet, Url: srv.URL, Timeout: 50 * time.Millisecond})
+		So(err, ShouldNotBeNil)
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+	})
+
+	Convey("calls are audited without credentials or personal data", t, func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"access_token":"secret-token","state":"RECEIVED"}`))
+		}))
+		defer srv.Close()
+
+		log := inmem.NewAuditLog()
+		c := newTestClient(srv.URL)
+		c.Audit = log
+		body := `{"senderRefId":"REF1","beneficiary":{"accountNumber":"109453095653","name":"Rachelle","address":{"line1":"241 A.Del Mundo St"}},"remittance":{"amount":"30.00"}}`
+		_, err := c.Do(context.Background(), &ApiCall{Method: http.MethodPost, Url: srv.URL + "/partners/v3/instapay/transfers/single", Body: strings.NewReader(body)})
+		So(err, ShouldBeNil)
+
+		entries := log.Entries()
+		So(entries, ShouldHaveLength, 1)
+		So(entries[0].Kind, ShouldEqual, disbursement.AuditProviderCall)
+		So(entries[0].Subject, ShouldEqual, "/partners/v3/instapay/transfers/single")
+		details := string(entries[0].Details)
+		So(details, ShouldContainSubstring, `"senderRefId":"REF1"`)
+		So(details, ShouldContainSubstring, `"accountNumber":"********5653"`)
+		So(details, ShouldContainSubstring, `"state":"RECEIVED"`)
+		for _, secret := range []string{"109453095653", "Rachelle", "Del Mundo", "secret-token"} {
+			So(details, ShouldNotContainSubstring, secret)
+		}
+	})
+
+	Convey("form encoded credentials are redacted", t, func() {
+		So(redact([]byte("grant_type=password&username=partner&password=hunter2")), ShouldResemble, map[string]interface{}{
+			"grant_type": "password",
+			"username":   "[redacted]",
+			"password":   "[redacted]",
+		})
+	})
+
+	Convey("backoff is capped and jittered", t, func() {
+		c := newTestClient("")
+		for attempt := 0; attempt < 10; attempt++ {
+			d := c.backoff(attempt)
+			So(d, ShouldBeGreaterThanOrEqualTo, 0)
+			So(d, ShouldBeLessThanOrEqualTo, c.MaxBackoff)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"strconv"
 )
 
 // var UbpConfig Config = LoadConfiguration("config.dev.json")
@@ -16,15 +17,29 @@ type Config struct {
 	PesonetGetBanksPath   string `json:"pesonetGetBanksPath"`
 	GetTransferStatusPath string `json:"getTransferStatusPath"`
 	PesonetPath           string `json:"pesonetPath"`
+	UbpToUbpPath          string `json:"ubpToUbpPath"`
+	UbpToUbpStatusPath    string `json:"ubpToUbpStatusPath"`
 	ClientId              string `json:"clientId"`
 	ClientSecret          string `json:"clientSecret"`
 	PartnerId             string `json:"partnerId"`
 	Username              string `json:"username"`
 	Password              string `json:"password"`
 	Scope                 string `json:"scope"`
+
+	// RequestTimeoutMs bounds each attempt of an API call. MaxRetries is the
+	// number of times an idempotent call is retried; a negative value disables
+	// retries. Zero values use the client defaults.
+	RequestTimeoutMs int `json:"requestTimeoutMs"`
+	MaxRetries       int `json:"maxRetries"`
+
+	// RefIDNodeID distinguishes the sender reference ids issued by each
+	// instance sharing the partner account (0-99). It has no default, as
+	// two instances with the same node id issue the same ids.
+	RefIDNodeID *int `json:"refIdNodeId"`
 }
 
-// LoadConfiguration to load json config file
+// LoadConfiguration to load json config file. The UBP_REF_ID_NODE_ID
+// environment variable, if set, overrides RefIDNodeID.
 func (c *Config) LoadConfiguration(file string) {
 	configFile, err := os.Open(file)
 	defer configFile.Close()
@@ -34,5 +49,12 @@ func (c *Config) LoadConfiguration(file string) {
 	jsonParser := json.NewDecoder(configFile)
 	jsonParser.Decode(&c)
 
+	if v := os.Getenv("UBP_REF_ID_NODE_ID"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("UBP_REF_ID_NODE_ID: %s", err)
+		}
+		c.RefIDNodeID = &id
+	}
 	return
 }
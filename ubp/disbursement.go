@@ -1,6 +1,12 @@
 package ubp
 
-import disbursement "github.com/jfpalngipang/fund-disbursement"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
 
 type DisbursementService struct {
 	ubp *UBP
@@ -10,24 +16,108 @@ func NewDisbursementService(ubp *UBP) *DisbursementService {
 	return &DisbursementService{ubp: ubp}
 }
 
-func (s *DisbursementService) TransferFunds(method string, transferRequest *disbursement.Disbursement) (interface{}, error) {
-	authResponse, _ := s.ubp.AuthenticatePartner()
-	fundTransferResponse, _ := s.ubp.TransferFundsFromPartnerAccount(authResponse.AccessToken, method, transferRequest)
-	return fundTransferResponse, nil
+func (s *DisbursementService) TransferFunds(ctx context.Context, method disbursement.Method, transferRequest *disbursement.Disbursement) (*disbursement.TransferResult, error) {
+	token, err := s.ubp.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result *disbursement.TransferResult
+	if method == DisbursementMethodUbptoUbp {
+		var resp UbpTransferResponse
+		resp, err = s.ubp.TransferFundsToUbpAccount(ctx, token, transferRequest)
+		result = &disbursement.TransferResult{
+			ReferenceID:           resp.SenderTransferId,
+			ProviderTransactionID: resp.UbpTranId,
+			ProviderState:         resp.State,
+			CreatedAt:             parseTime(resp.TranRequestDate),
+		}
+	} else {
+		var resp FundTransferResponse
+		resp, err = s.ubp.TransferFundsFromPartnerAccount(ctx, token, method, transferRequest)
+		result = &disbursement.TransferResult{
+			ReferenceID:           resp.SenderRefId,
+			ProviderTransactionID: resp.TranId,
+			ProviderState:         resp.State,
+			CreatedAt:             parseTime(resp.CreatedAt),
+		}
+		if result.ProviderTransactionID == "" {
+			result.ProviderTransactionID = resp.UbpTranId
+		}
+	}
+	if errors.Is(err, disbursement.ErrUnauthorized) {
+		s.ubp.tokens.Invalidate()
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Method = method
+	result.State = transferState(result.ProviderState)
+	return result, nil
+}
+
+// NewSenderRefID issues the reference id d will be sent with.
+func (s *DisbursementService) NewSenderRefID(d *disbursement.Disbursement) string {
+	return s.ubp.RefIDGenerator.NewRefID(d.ReferenceID)
 }
 
-func (s *DisbursementService) GetBanks(method string) (interface{}, error) {
-	banksResponse, _ := s.ubp.GetBanksForTransfer(method)
-	return banksResponse, nil
+func (s *DisbursementService) GetBanks(ctx context.Context, method disbursement.Method) ([]disbursement.Bank, error) {
+	banksResponse, err := s.ubp.GetBanksForTransfer(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	banks := make([]disbursement.Bank, len(banksResponse.Records))
+	for i, b := range banksResponse.Records {
+		banks[i] = disbursement.Bank{Code: b.Code, Name: b.Bank}
+	}
+	return banks, nil
 }
 
-func (s *DisbursementService) GetStatus(method string, referenceID string) (interface{}, error) {
-	var statusResponse interface{}
-	if method == "instapay" {
-		statusResponse, _ = s.ubp.GetInstapayTransferStatus(referenceID)
-	} else if method == "pesonet" {
-		statusResponse, _ = s.ubp.GetPesonetTransferStatus(referenceID)
+func (s *DisbursementService) GetStatus(ctx context.Context, method disbursement.Method, referenceID string) (*disbursement.TransferStatus, error) {
+	status := &disbursement.TransferStatus{Method: method, ReferenceID: referenceID}
+	switch method {
+	case DisbursementMethodInstapay:
+		resp, err := s.ubp.GetInstapayTransferStatus(ctx, referenceID)
+		if err != nil {
+			return nil, err
+		} else if len(resp.Records) == 0 {
+			return nil, transferNotFound(method, referenceID)
+		}
+		rec := resp.Records[0]
+		status.ProviderTransactionID = rec.UbpTranID
+		status.ProviderState = rec.State
+		status.CreatedAt = parseTime(rec.CreatedAt)
+	case DisbursementMethodPesonet:
+		resp, err := s.ubp.GetPesonetTransferStatus(ctx, referenceID)
+		if err != nil {
+			return nil, err
+		} else if len(resp.Records) == 0 {
+			return nil, transferNotFound(method, referenceID)
+		}
+		rec := resp.Records[0]
+		status.ProviderTransactionID = rec.UbpTranID
+		status.ProviderState = rec.State
+		status.CreatedAt = parseTime(rec.CreatedAt)
+		status.UpdatedAt = parseTime(rec.UpdatedAt)
+	case DisbursementMethodUbptoUbp:
+		resp, err := s.ubp.GetUbpTransferStatus(ctx, referenceID)
+		if err != nil {
+			return nil, err
+		}
+		status.ProviderTransactionID = resp.UbpTranId
+		status.ProviderState = resp.State
+		status.CreatedAt = parseTime(resp.TranRequestDate)
+		status.UpdatedAt = parseTime(resp.TranFinacleDate)
+	default:
+		return nil, unsupportedMethod(method)
 	}
 
-	return statusResponse, nil
+	status.State = transferState(status.ProviderState)
+	if status.UpdatedAt.IsZero() {
+		status.UpdatedAt = status.CreatedAt
+	}
+	return status, nil
+}
+
+func transferNotFound(method disbursement.Method, referenceID string) error {
+	return fmt.Errorf("%w: no %s transfer with reference id %s", disbursement.ErrNotFound, method, referenceID)
 }
@@ -1,17 +1,22 @@
 package ubp
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
 	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
-func TestDisbursement(m *testing.M) {
-
-}
-
 func Test_TransferFundsSuccess(t *testing.T) {
 	Convey("testing transfer transfer funds ", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		s := NewDisbursementService(newTestUBP(fake))
+
 		addr := disbursement.Address{
 			Line1:    "Unit 11C15 Fort Victoria",
 			Line2:    "23rd street Fort Bonifacio",
@@ -27,8 +32,7 @@ func Test_TransferFundsSuccess(t *testing.T) {
 		}
 
 		det := disbursement.Details{
-			Amount:        "100.00",
-			Currncy:       "PHP",
+			Amount:        disbursement.Money{Minor: 10000, Currency: "PHP"},
 			ReceivingBank: "161312",
 			Purpose:       "Fund Transfer",
 			Instructions:  "Test Instruction",
@@ -37,6 +41,145 @@ func Test_TransferFundsSuccess(t *testing.T) {
 		d := disbursement.Disbursement{
 			Receiver: r,
 			Details:  det,
+			Sender:   &disbursement.Sender{ID: "corp", Name: "Palngipang Corp.", Address: addr},
+		}
+
+		resp, err := s.TransferFunds(context.Background(), DisbursementMethodInstapay, &d)
+		So(err, ShouldBeNil)
+		So(resp.Method, ShouldEqual, disbursement.MethodInstapay)
+		So(resp.ReferenceID, ShouldEqual, fake.transfers[0].SenderRefId)
+		So(resp.ProviderTransactionID, ShouldEqual, "UB"+resp.ReferenceID)
+		So(resp.State, ShouldEqual, disbursement.TransferCredited)
+		So(resp.CreatedAt.IsZero(), ShouldBeFalse)
+		So(fake.transfers, ShouldHaveLength, 1)
+		So(fake.transfers[0].Beneficiary.Name, ShouldEqual, "Juan Dela Cruz")
+	})
+
+	Convey("pesonet and intrabank results are normalized the same way", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		s := NewDisbursementService(newTestUBP(fake))
+
+		resp, err := s.TransferFunds(context.Background(), DisbursementMethodPesonet, testDisbursement("109453095653", "161408"))
+		So(err, ShouldBeNil)
+		So(resp.ProviderTransactionID, ShouldEqual, "UB"+resp.ReferenceID)
+		So(resp.State, ShouldEqual, disbursement.TransferProcessing)
+
+		resp, err = s.TransferFunds(context.Background(), DisbursementMethodUbptoUbp, testDisbursement("109453095653", ""))
+		So(err, ShouldBeNil)
+		So(resp.ReferenceID, ShouldEqual, fake.ubpTransfers[0].SenderTransferId)
+		So(resp.State, ShouldEqual, disbursement.TransferCredited)
+	})
+
+	Convey("a sender ref id issued before the transfer is the one sent", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		s := NewDisbursementService(newTestUBP(fake))
+
+		d := testDisbursement("109453095653", "161408")
+		d.SenderRefID = s.NewSenderRefID(d)
+		resp, err := s.TransferFunds(context.Background(), DisbursementMethodInstapay, d)
+		So(err, ShouldBeNil)
+		So(fake.transfers[0].SenderRefId, ShouldEqual, d.SenderRefID)
+		So(resp.ReferenceID, ShouldEqual, d.SenderRefID)
+	})
+}
+
+func Test_GetStatus(t *testing.T) {
+	Convey("transfer statuses are normalized for every rail", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		s := NewDisbursementService(newTestUBP(fake))
+		ctx := context.Background()
+
+		status, err := s.GetStatus(ctx, DisbursementMethodInstapay, "REF1")
+		So(err, ShouldBeNil)
+		So(status.ReferenceID, ShouldEqual, "REF1")
+		So(status.ProviderTransactionID, ShouldEqual, "UBREF1")
+		So(status.State, ShouldEqual, disbursement.TransferCredited)
+
+		status, err = s.GetStatus(ctx, DisbursementMethodPesonet, "REF2")
+		So(err, ShouldBeNil)
+		So(status.State, ShouldEqual, disbursement.TransferReturned)
+		So(status.UpdatedAt.After(status.CreatedAt), ShouldBeTrue)
+
+		status, err = s.GetStatus(ctx, DisbursementMethodUbptoUbp, "REF3")
+		So(err, ShouldBeNil)
+		So(status.State, ShouldEqual, disbursement.TransferCredited)
+
+		_, err = s.GetStatus(ctx, DisbursementMethodInstapay, "unknown")
+		So(errors.Is(err, disbursement.ErrNotFound), ShouldBeTrue)
+	})
+
+	Convey("banks are listed by code and name", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		s := NewDisbursementService(newTestUBP(fake))
+
+		banks, err := s.GetBanks(context.Background(), DisbursementMethodPesonet)
+		So(err, ShouldBeNil)
+		So(banks, ShouldContain, disbursement.Bank{Code: "161408", Name: "Metrobank"})
+	})
+}
+
+func Test_transferState(t *testing.T) {
+	Convey("UBP states map to normalized states", t, func() {
+		for state, want := range map[string]disbursement.TransferState{
+			"":                                     disbursement.TransferPending,
+			"Pending":                              disbursement.TransferPending,
+			"Sent for Processing":                  disbursement.TransferProcessing,
+			"Received by Beneficiary Bank":         disbursement.TransferProcessing,
+			"Credited Beneficiary Account":         disbursement.TransferCredited,
+			"Failed to Credit Beneficiary Account": disbursement.TransferFailed,
+			"Rejected":                             disbursement.TransferFailed,
+			"Returned":                             disbursement.TransferReturned,
+		} {
+			So(transferState(state), ShouldEqual, want)
+		}
+	})
+}
+
+func Test_TransferFundsConcurrently(t *testing.T) {
+	Convey("parallel transfers through one UBP each send their own payload", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		s := NewDisbursementService(newTestUBP(fake))
+
+		const n = 300
+		type result struct {
+			d    *disbursement.Disbursement
+			resp *disbursement.TransferResult
+			err  error
+		}
+		results := make([]result, n)
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				d := testDisbursement(fmt.Sprintf("1000%08d", i), "161408")
+				d.Receiver.Name = fmt.Sprintf("Beneficiary %d", i)
+				d.Details.Amount.Minor = int64(i+1) * 100
+				resp, err := s.TransferFunds(context.Background(), DisbursementMethodInstapay, d)
+				results[i].d, results[i].resp, results[i].err = d, resp, err
+			}(i)
+		}
+		wg.Wait()
+
+		sent := make(map[string]FundTransferRequest)
+		for _, req := range fake.transfers {
+			sent[req.SenderRefId] = req
+		}
+		So(sent, ShouldHaveLength, n)
+
+		for _, r := range results {
+			So(r.err, ShouldBeNil)
+			req, ok := sent[r.resp.ReferenceID]
+			So(ok, ShouldBeTrue)
+			So(req.Beneficiary.AccountNumber, ShouldEqual, r.d.Receiver.AccountNumber)
+			So(req.Beneficiary.Name, ShouldEqual, r.d.Receiver.Name)
+			So(req.Remittance.Amount, ShouldEqual, r.d.Details.Amount.String())
 		}
 	})
 }
@@ -0,0 +1,150 @@
+package ubp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// APIError is a failed call to the UBP API. A zero StatusCode means no
+// response was received.
+type APIError struct {
+	StatusCode    int
+	Code          string
+	Message       string
+	CorrelationID string
+
+	// Err is the transport error when no response was received.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString("ubp: ")
+	if e.StatusCode != 0 {
+		b.WriteString(strconv.Itoa(e.StatusCode))
+		b.WriteString(" ")
+	}
+	if e.Code != "" {
+		b.WriteString("[" + e.Code + "] ")
+	}
+	b.WriteString(e.Message)
+	if e.CorrelationID != "" {
+		b.WriteString(" (correlation id " + e.CorrelationID + ")")
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// Is matches e against the disbursement error categories.
+func (e *APIError) Is(target error) bool {
+	return target == e.category()
+}
+
+func (e *APIError) ErrorCode() string          { return e.Code }
+func (e *APIError) ErrorCorrelationID() string { return e.CorrelationID }
+
+// authCodes are OAuth and gateway error codes for rejected credentials.
+var authCodes = map[string]bool{
+	"invalid_grant":  true,
+	"invalid_client": true,
+	"invalid_token":  true,
+	"unauthorized":   true,
+}
+
+func (e *APIError) category() error {
+	switch {
+	case e.StatusCode == 0:
+		return disbursement.ErrRetryable
+	case isFundingMessage(e.Message):
+		return disbursement.ErrFunding
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden || authCodes[e.Code]:
+		return disbursement.ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500:
+		return disbursement.ErrRetryable
+	case e.StatusCode == http.StatusNotFound:
+		return disbursement.ErrNotFound
+	case e.StatusCode >= 400:
+		return disbursement.ErrInvalid
+	}
+	return nil
+}
+
+// ubpErrorBody covers the error shapes returned by UBP: the partner API's
+// errors list, the API gateway's httpCode/moreInformation body and OAuth
+// error responses.
+type ubpErrorBody struct {
+	Errors []struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+		Message     string `json:"message"`
+	} `json:"errors"`
+	Code             string `json:"code"`
+	Message          string `json:"message"`
+	Description      string `json:"description"`
+	HTTPMessage      string `json:"httpMessage"`
+	MoreInformation  string `json:"moreInformation"`
+	OAuthError       string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// newAPIError builds an APIError from a non-successful UBP response.
+func newAPIError(res *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode:    res.StatusCode,
+		CorrelationID: correlationID(res.Header),
+	}
+
+	var eb ubpErrorBody
+	if err := json.Unmarshal(body, &eb); err != nil {
+		e.Message = strings.TrimSpace(string(body))
+	} else if len(eb.Errors) > 0 {
+		e.Code = eb.Errors[0].Code
+		e.Message = firstNonEmpty(eb.Errors[0].Description, eb.Errors[0].Message)
+	} else if eb.OAuthError != "" {
+		e.Code = eb.OAuthError
+		e.Message = eb.ErrorDescription
+	} else {
+		e.Code = eb.Code
+		e.Message = firstNonEmpty(eb.Message, eb.Description, eb.MoreInformation, eb.HTTPMessage)
+	}
+
+	if e.Message == "" {
+		e.Message = http.StatusText(res.StatusCode)
+	}
+	return e
+}
+
+// newTransportError wraps an error that prevented a response from being
+// received.
+func newTransportError(err error) *APIError {
+	return &APIError{Message: fmt.Sprintf("request failed: %s", err), Err: err}
+}
+
+func isFundingMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "insufficient") || strings.Contains(msg, "not enough funds")
+}
+
+func correlationID(h http.Header) string {
+	for _, k := range []string{"X-Correlation-Id", "X-Global-Transaction-Id", "X-Request-Id"} {
+		if v := h.Get(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
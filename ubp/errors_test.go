@@ -0,0 +1,74 @@
+package ubp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAPIError(t *testing.T) {
+	respond := func(status int, body string, header http.Header) error {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		defer srv.Close()
+
+		c := newTestClient(srv.URL)
+		_, err := c.Do(context.Background(), &ApiCall{Method: http.MethodPost, Url: srv.URL})
+		return err
+	}
+
+	Convey("partner API errors are parsed and categorized", t, func() {
+		err := respond(http.StatusBadRequest, `{"errors":[{"code":"TX","description":"Insufficient funds in account"}]}`,
+			http.Header{"X-Global-Transaction-Id": {"abc123"}})
+
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.StatusCode, ShouldEqual, http.StatusBadRequest)
+		So(apiErr.Code, ShouldEqual, "TX")
+		So(apiErr.Message, ShouldEqual, "Insufficient funds in account")
+		So(apiErr.CorrelationID, ShouldEqual, "abc123")
+		So(errors.Is(err, disbursement.ErrFunding), ShouldBeTrue)
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeFalse)
+	})
+
+	Convey("gateway errors are categorized as auth failures", t, func() {
+		err := respond(http.StatusUnauthorized, `{"httpCode":"401","httpMessage":"Unauthorized","moreInformation":"Client id not registered."}`, nil)
+		So(errors.Is(err, disbursement.ErrUnauthorized), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "Client id not registered.")
+	})
+
+	Convey("OAuth errors are categorized as auth failures", t, func() {
+		err := respond(http.StatusBadRequest, `{"error":"invalid_grant","error_description":"Refresh token expired"}`, nil)
+		var apiErr *APIError
+		So(errors.As(err, &apiErr), ShouldBeTrue)
+		So(apiErr.Code, ShouldEqual, "invalid_grant")
+		So(errors.Is(err, disbursement.ErrUnauthorized), ShouldBeTrue)
+	})
+
+	Convey("rate limiting and downtime are retryable", t, func() {
+		So(errors.Is(respond(http.StatusTooManyRequests, ``, nil), disbursement.ErrRetryable), ShouldBeTrue)
+		So(errors.Is(respond(http.StatusServiceUnavailable, `<html>down</html>`, nil), disbursement.ErrRetryable), ShouldBeTrue)
+	})
+
+	Convey("unknown records are not found", t, func() {
+		err := respond(http.StatusNotFound, `{"errors":[{"code":"NF","description":"Transaction not found"}]}`, nil)
+		So(errors.Is(err, disbursement.ErrNotFound), ShouldBeTrue)
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeFalse)
+	})
+
+	Convey("other client errors are invalid requests", t, func() {
+		err := respond(http.StatusUnprocessableEntity, `{"code":"V001","message":"Invalid account number"}`, nil)
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+		So(errors.Is(err, disbursement.ErrRetryable), ShouldBeFalse)
+	})
+}
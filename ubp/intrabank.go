@@ -0,0 +1,148 @@
+package ubp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/mitchellh/mapstructure"
+)
+
+// UbpTransferRequest is the body of a UBP-to-UBP (intrabank) transfer. Funds
+// move between UnionBank accounts without going through InstaPay or PESONet,
+// so no interbank fees apply.
+type UbpTransferRequest struct {
+	SenderTransferId    string            `json:"senderTransferId"`
+	TransferRequestDate string            `json:"transferRequestDate"`
+	AccountNo           string            `json:"accountNo"`
+	Amount              UbpTransferAmount `json:"amount"`
+	Remarks             string            `json:"remarks"`
+	Particulars         string            `json:"particulars"`
+	Info                []UbpTransferInfo `json:"info"`
+}
+
+type UbpTransferAmount struct {
+	Currency string `json:"currency"`
+	Value    string `json:"value"`
+}
+
+// UbpTransferInfo is a free-form name/value pair attached to an intrabank
+// transfer.
+type UbpTransferInfo struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type UbpTransferResponse struct {
+	Code             string `json:"code"`
+	SenderTransferId string `json:"senderTransferId"`
+	State            string `json:"state"`
+	Uuid             string `json:"uuid"`
+	UbpTranId        string `json:"ubpTranId"`
+	TranRequestDate  string `json:"tranRequestDate"`
+	TranFinacleDate  string `json:"tranFinacleDate"`
+}
+
+type UbpTransferStatusResponse struct {
+	Code             string `json:"code"`
+	SenderTransferId string `json:"senderTransferId"`
+	State            string `json:"state"`
+	UbpTranId        string `json:"ubpTranId"`
+	Type             string `json:"type"`
+	Amount           string `json:"amount"`
+	TranRequestDate  string `json:"tranRequestDate"`
+	TranFinacleDate  string `json:"tranFinacleDate"`
+}
+
+// TransferFundsToUbpAccount sends funds from the partner account to another
+// UnionBank account.
+func (u *UBP) TransferFundsToUbpAccount(ctx context.Context, token string, transferRequest *disbursement.Disbursement) (UbpTransferResponse, error) {
+	reqDate := time.Now().Format("2006-01-02T15:04:05.000")
+
+	req := UbpTransferRequest{
+		SenderTransferId:    u.senderRefID(transferRequest),
+		TransferRequestDate: reqDate[0:23],
+		AccountNo:           transferRequest.Receiver.AccountNumber,
+		Amount: UbpTransferAmount{
+			Currency: transferRequest.Details.Amount.Currency,
+			Value:    transferRequest.Details.Amount.String(),
+		},
+		Remarks:     instructions(DisbursementMethodUbptoUbp, transferRequest.Details.Instructions),
+		Particulars: transferRequest.Details.Purpose,
+		Info: []UbpTransferInfo{
+			{Index: 1, Name: "Recipient", Value: transferRequest.Receiver.Name},
+		},
+	}
+	if s := transferRequest.Sender; s != nil {
+		req.Info = append(req.Info, UbpTransferInfo{Index: 2, Name: "Sender", Value: s.Name})
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fmt.Printf("Marshal Error: %s\n", err)
+		return UbpTransferResponse{}, err
+	}
+
+	apiCall := ApiCall{
+		Method: http.MethodPost,
+		Url:    u.Config.BaseUrl + u.Config.UbpToUbpPath,
+		Body:   bytes.NewBuffer(b),
+		AdditionalHeaders: map[string]string{
+			"Content-Type":  "application/json",
+			"Accept":        "application/json",
+			"Authorization": "Bearer " + token,
+		},
+	}
+
+	response, err := u.client.Do(ctx, &apiCall)
+	if err != nil {
+		fmt.Printf("Error in UBP to UBP Request: %s\n", err)
+		return UbpTransferResponse{}, err
+	}
+
+	var ubpTransferResponse UbpTransferResponse
+
+	err = mapstructure.Decode(response, &ubpTransferResponse)
+	if err != nil {
+		fmt.Printf("Error decoding response: %s\n", err)
+		return UbpTransferResponse{}, err
+	}
+
+	return ubpTransferResponse, nil
+}
+
+// GetUbpTransferStatus looks up an intrabank transfer by its sender transfer id.
+func (u *UBP) GetUbpTransferStatus(ctx context.Context, referenceId string) (UbpTransferStatusResponse, error) {
+	apiPath := strings.Replace(u.Config.UbpToUbpStatusPath, "{referenceId}", referenceId, -1)
+	apiCall := ApiCall{
+		Method: http.MethodGet,
+		Url:    u.Config.BaseUrl + apiPath,
+		Body:   nil,
+		AdditionalHeaders: map[string]string{
+			"Content-Type": "application/json",
+			"Accept":       "application/json",
+		},
+	}
+
+	response, err := u.client.Do(ctx, &apiCall)
+	if err != nil {
+		fmt.Printf("Error in retrieving transfer status: %s\n", err)
+		return UbpTransferStatusResponse{}, err
+	}
+
+	var ubpTransferStatusResponse UbpTransferStatusResponse
+
+	err = mapstructure.Decode(response, &ubpTransferStatusResponse)
+	if err != nil {
+		fmt.Printf("Error decoding response: %s\n", err)
+		return UbpTransferStatusResponse{}, err
+	}
+
+	return ubpTransferStatusResponse, nil
+}
@@ -0,0 +1,144 @@
+package ubp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// maxRefIDLength is the longest sender reference id UBP accepts.
+const maxRefIDLength = 30
+
+// RefIDGenerator produces the sender reference ids that identify our
+// transfers to UBP.
+type RefIDGenerator interface {
+	// NewRefID returns a reference id that has not been returned before. A
+	// non-empty clientRef is embedded in the id so the UBP transaction can be
+	// traced back to the caller's own reference.
+	NewRefID(clientRef string) string
+}
+
+// TimeRefIDGenerator generates ids of the form
+//
+//	[client ref]YYMMDDhhmmss<node:2><counter:4>
+//
+// The timestamp is in UTC. Up to 10000 ids are issued per second per node;
+// beyond that the generator borrows from the following second.
+//
+// Ids are unique only among generators with distinct NodeIDs, so each process
+// sharing a UBP partner account needs its own. A generator keeps its ids
+// increasing while the clock stalls or steps backwards, but remembers its
+// last id only in memory: after a restart it must Resume from the ids it
+// issued before, or a clock that went back repeats them.
+type TimeRefIDGenerator struct {
+	NodeID int
+
+	mu      sync.Mutex
+	lastSec int64
+	counter int
+
+	now func() time.Time
+}
+
+const (
+	refIDLength     = len(refIDTimeLayout) + 2 + 4
+	refIDTimeLayout = "060102150405"
+	refIDCounterMax = 10000
+	refIDMaxNodeID  = 99
+)
+
+// NewTimeRefIDGenerator returns a generator for the given node id (0-99).
+func NewTimeRefIDGenerator(nodeID int) *TimeRefIDGenerator {
+	if nodeID < 0 || nodeID > refIDMaxNodeID {
+		panic(fmt.Sprintf("ubp: ref id node id %d out of range", nodeID))
+	}
+	return &TimeRefIDGenerator{NodeID: nodeID, now: time.Now}
+}
+
+func (g *TimeRefIDGenerator) NewRefID(clientRef string) string {
+	g.mu.Lock()
+	sec := g.now().Unix()
+	if sec > g.lastSec {
+		g.lastSec, g.counter = sec, 0
+	} else if g.counter++; g.counter >= refIDCounterMax {
+		g.lastSec, g.counter = g.lastSec+1, 0
+	}
+	sec, counter := g.lastSec, g.counter
+	g.mu.Unlock()
+
+	id := fmt.Sprintf("%s%02d%04d", time.Unix(sec, 0).UTC().Format(refIDTimeLayout), g.NodeID, counter)
+	return clientRefPrefix(clientRef, maxRefIDLength-len(id)) + id
+}
+
+// Resume makes g issue only ids after refID, which it may have issued before
+// a restart. Ids of other nodes or of another form are ignored.
+func (g *TimeRefIDGenerator) Resume(refID string) {
+	if len(refID) < refIDLength {
+		return
+	}
+	suffix := refID[len(refID)-refIDLength:]
+	t, err := time.Parse(refIDTimeLayout, suffix[:len(refIDTimeLayout)])
+	if err != nil {
+		return
+	}
+	node, err := strconv.ParseUint(suffix[len(refIDTimeLayout):len(refIDTimeLayout)+2], 10, 8)
+	if err != nil || int(node) != g.NodeID {
+		return
+	}
+	counter, err := strconv.ParseUint(suffix[len(refIDTimeLayout)+2:], 10, 16)
+	if err != nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if sec := t.Unix(); sec > g.lastSec || (sec == g.lastSec && int(counter) > g.counter) {
+		g.lastSec, g.counter = sec, int(counter)
+	}
+}
+
+// ResumeRefIDs resumes the generator of u, if it is a TimeRefIDGenerator,
+// from the ids of the recorded transactions and of the items of unfinished
+// batches. It must be called before any transfer is sent.
+func (u *UBP) ResumeRefIDs(ctx context.Context, txs disbursement.TransactionStore, batches disbursement.BatchStore) error {
+	g, ok := u.RefIDGenerator.(*TimeRefIDGenerator)
+	if !ok {
+		return nil
+	}
+	recorded, err := txs.FindTransactions(ctx, disbursement.TransactionFilter{})
+	if err != nil {
+		return err
+	}
+	for _, tx := range recorded {
+		g.Resume(tx.SenderRefID)
+	}
+	running, err := batches.FindBatches(ctx, disbursement.BatchRunning)
+	if err != nil {
+		return err
+	}
+	for _, b := range running {
+		for _, item := range b.Items {
+			g.Resume(item.SenderRefID)
+		}
+	}
+	return nil
+}
+
+// clientRefPrefix reduces ref to at most n upper-case alphanumerics.
+func clientRefPrefix(ref string, n int) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(ref) {
+		if b.Len() == n {
+			break
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
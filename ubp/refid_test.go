@@ -0,0 +1,85 @@
+package ubp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimeRefIDGenerator(t *testing.T) {
+	Convey("ref ids are unique across goroutines", t, func() {
+		g := NewTimeRefIDGenerator(7)
+
+		const workers, perWorker = 50, 1000
+		ids := make(chan string, workers*perWorker)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < perWorker; j++ {
+					ids <- g.NewRefID("")
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := make(map[string]bool)
+		for id := range ids {
+			So(len(id), ShouldBeLessThanOrEqualTo, maxRefIDLength)
+			So(seen[id], ShouldBeFalse)
+			seen[id] = true
+		}
+		So(seen, ShouldHaveLength, workers*perWorker)
+	})
+
+	Convey("ref ids increase even when the clock stalls or steps back", t, func() {
+		now := time.Date(2019, 8, 1, 9, 0, 0, 0, time.UTC)
+		g := NewTimeRefIDGenerator(1)
+		g.now = func() time.Time { return now }
+
+		first := g.NewRefID("")
+		So(first, ShouldEqual, "190801090000010000")
+
+		prev := first
+		for i := 0; i < 2*refIDCounterMax; i++ {
+			if i == refIDCounterMax/2 {
+				now = now.Add(-time.Minute)
+			}
+			id := g.NewRefID("")
+			So(id > prev, ShouldBeTrue)
+			prev = id
+		}
+	})
+
+	Convey("ref ids resumed after a restart are not issued again", t, func() {
+		now := time.Date(2019, 8, 1, 9, 0, 0, 0, time.UTC)
+		g := NewTimeRefIDGenerator(1)
+		g.now = func() time.Time { return now }
+		issued := g.NewRefID("order-42")
+		g.NewRefID("")
+
+		restarted := NewTimeRefIDGenerator(1)
+		restarted.now = func() time.Time { return now.Add(-time.Minute) }
+		restarted.Resume(issued)
+		restarted.Resume("190801090000010000")
+		restarted.Resume("190801100000020000")
+		restarted.Resume("not a ref id")
+		So(restarted.NewRefID(""), ShouldEqual, "190801090000010001")
+	})
+
+	Convey("client references are embedded and truncated to fit", t, func() {
+		g := NewTimeRefIDGenerator(0)
+
+		id := g.NewRefID("order-42")
+		So(id, ShouldStartWith, "ORDER42")
+		So(len(id), ShouldEqual, len("ORDER42")+18)
+
+		id = g.NewRefID("payroll/2019-08/employee-000123")
+		So(len(id), ShouldEqual, maxRefIDLength)
+		So(id[:maxRefIDLength-18], ShouldEqual, "PAYROLL20190")
+	})
+}
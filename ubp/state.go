@@ -0,0 +1,60 @@
+package ubp
+
+import (
+	"strings"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// stateKeywords map words found in UBP transfer states to normalized states.
+// UBP reports states as free text ("Credited Beneficiary Account", "Sent for
+// Processing", "Failed to Credit Beneficiary Account", ...), so they are
+// matched in order: failure wording is checked before "credit".
+var stateKeywords = []struct {
+	keyword string
+	state   disbursement.TransferState
+}{
+	{"fail", disbursement.TransferFailed},
+	{"reject", disbursement.TransferFailed},
+	{"cancel", disbursement.TransferFailed},
+	{"return", disbursement.TransferReturned},
+	{"revers", disbursement.TransferReturned},
+	{"credited", disbursement.TransferCredited},
+	{"success", disbursement.TransferCredited},
+	{"completed", disbursement.TransferCredited},
+	{"pending", disbursement.TransferPending},
+	{"queued", disbursement.TransferPending},
+}
+
+// transferState maps a UBP transfer state to a normalized state. States it
+// does not recognize are treated as still processing, never as final.
+func transferState(state string) disbursement.TransferState {
+	s := strings.ToLower(state)
+	if strings.TrimSpace(s) == "" {
+		return disbursement.TransferPending
+	}
+	for _, k := range stateKeywords {
+		if strings.Contains(s, k.keyword) {
+			return k.state
+		}
+	}
+	return disbursement.TransferProcessing
+}
+
+// manila is the zone of UBP timestamps that carry no offset.
+var manila = time.FixedZone("PHT", 8*60*60)
+
+// parseTime parses a UBP timestamp. It returns the zero time if s is empty or
+// not in a known layout.
+func parseTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.000", "2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, manila); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
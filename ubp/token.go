@@ -0,0 +1,117 @@
+package ubp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultRefreshMargin is how long before expiry a cached token is renewed.
+const defaultRefreshMargin = time.Minute
+
+// tokenFetcher obtains partner tokens from the UBP OAuth endpoint.
+type tokenFetcher interface {
+	AuthenticatePartner(ctx context.Context) (AuthTokenResponse, error)
+	RefreshPartnerToken(ctx context.Context, refreshToken string) (AuthTokenResponse, error)
+}
+
+// tokenCall is an in-flight token request that concurrent callers wait on.
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenManager caches the partner access token and renews it shortly before
+// it expires. Renewal uses the refresh_token grant and falls back to the
+// password grant when that fails. Concurrent callers share a single renewal,
+// which runs detached from any one caller's context so that a cancelled
+// request does not fail the others waiting on it.
+type tokenManager struct {
+	fetcher       tokenFetcher
+	refreshMargin time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	token     AuthTokenResponse
+	expiresAt time.Time
+	inflight  *tokenCall
+}
+
+func newTokenManager(fetcher tokenFetcher) *tokenManager {
+	return &tokenManager{
+		fetcher:       fetcher,
+		refreshMargin: defaultRefreshMargin,
+		now:           time.Now,
+	}
+}
+
+// AccessToken returns a valid access token, renewing it if necessary.
+func (m *tokenManager) AccessToken(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	if m.token.AccessToken != "" && m.now().Before(m.expiresAt) {
+		token := m.token.AccessToken
+		m.mu.Unlock()
+		return token, nil
+	}
+
+	c := m.inflight
+	if c == nil {
+		c = &tokenCall{done: make(chan struct{})}
+		m.inflight = c
+		go m.renew(c, m.token.RefreshToken)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-c.done:
+		return c.token, c.err
+	}
+}
+
+func (m *tokenManager) renew(c *tokenCall, refreshToken string) {
+	resp, err := m.fetch(context.Background(), refreshToken)
+
+	m.mu.Lock()
+	if err == nil {
+		m.store(resp)
+		c.token = resp.AccessToken
+	}
+	c.err = err
+	m.inflight = nil
+	m.mu.Unlock()
+	close(c.done)
+}
+
+// Invalidate drops the cached access token so the next call renews it.
+func (m *tokenManager) Invalidate() {
+	m.mu.Lock()
+	m.expiresAt = time.Time{}
+	m.mu.Unlock()
+}
+
+func (m *tokenManager) fetch(ctx context.Context, refreshToken string) (AuthTokenResponse, error) {
+	if refreshToken != "" {
+		resp, err := m.fetcher.RefreshPartnerToken(ctx, refreshToken)
+		if err == nil && resp.AccessToken != "" {
+			return resp, nil
+		}
+	}
+	return m.fetcher.AuthenticatePartner(ctx)
+}
+
+// store caches resp. The caller must hold m.mu.
+func (m *tokenManager) store(resp AuthTokenResponse) {
+	ttl := time.Duration(resp.ExpiresIn) * time.Second
+	margin := m.refreshMargin
+	if ttl < 2*margin {
+		margin = ttl / 2
+	}
+	if resp.RefreshToken == "" {
+		resp.RefreshToken = m.token.RefreshToken
+	}
+	m.token = resp
+	m.expiresAt = m.now().Add(ttl - margin)
+}
@@ -0,0 +1,114 @@
+package ubp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeTokenFetcher struct {
+	mu            sync.Mutex
+	passwordCalls int
+	refreshCalls  int
+	refreshErr    error
+	expiresIn     int
+	delay         time.Duration
+}
+
+func (f *fakeTokenFetcher) AuthenticatePartner(ctx context.Context) (AuthTokenResponse, error) {
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.passwordCalls++
+	return AuthTokenResponse{
+		AccessToken:  "password-" + strconv.Itoa(f.passwordCalls),
+		RefreshToken: "refresh",
+		ExpiresIn:    f.expiresIn,
+	}, nil
+}
+
+func (f *fakeTokenFetcher) RefreshPartnerToken(ctx context.Context, refreshToken string) (AuthTokenResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshCalls++
+	if f.refreshErr != nil {
+		return AuthTokenResponse{}, f.refreshErr
+	}
+	return AuthTokenResponse{
+		AccessToken:  "refreshed-" + strconv.Itoa(f.refreshCalls),
+		RefreshToken: refreshToken,
+		ExpiresIn:    f.expiresIn,
+	}, nil
+}
+
+func TestTokenManager_AccessToken(t *testing.T) {
+	Convey("token manager caches and renews partner tokens", t, func() {
+		now := time.Date(2019, 8, 1, 9, 0, 0, 0, time.UTC)
+		fetcher := &fakeTokenFetcher{expiresIn: 3600}
+		m := newTokenManager(fetcher)
+		m.now = func() time.Time { return now }
+
+		token, err := m.AccessToken(context.Background())
+		So(err, ShouldBeNil)
+		So(token, ShouldEqual, "password-1")
+
+		Convey("the cached token is reused before expiry", func() {
+			now = now.Add(30 * time.Minute)
+			token, err := m.AccessToken(context.Background())
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "password-1")
+			So(fetcher.passwordCalls, ShouldEqual, 1)
+			So(fetcher.refreshCalls, ShouldEqual, 0)
+		})
+
+		Convey("the token is refreshed shortly before expiry", func() {
+			now = now.Add(time.Hour - 30*time.Second)
+			token, err := m.AccessToken(context.Background())
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "refreshed-1")
+			So(fetcher.passwordCalls, ShouldEqual, 1)
+		})
+
+		Convey("a failed refresh falls back to the password grant", func() {
+			fetcher.refreshErr = errors.New("invalid_grant")
+			now = now.Add(2 * time.Hour)
+			token, err := m.AccessToken(context.Background())
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "password-2")
+			So(fetcher.refreshCalls, ShouldEqual, 1)
+		})
+
+		Convey("an invalidated token is renewed", func() {
+			m.Invalidate()
+			token, err := m.AccessToken(context.Background())
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "refreshed-1")
+		})
+	})
+
+	Convey("concurrent callers share a single renewal", t, func() {
+		fetcher := &fakeTokenFetcher{expiresIn: 3600, delay: 50 * time.Millisecond}
+		m := newTokenManager(fetcher)
+
+		var wg sync.WaitGroup
+		tokens := make([]string, 50)
+		for i := range tokens {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				tokens[i], _ = m.AccessToken(context.Background())
+			}(i)
+		}
+		wg.Wait()
+
+		So(fetcher.passwordCalls, ShouldEqual, 1)
+		for _, token := range tokens {
+			So(token, ShouldEqual, "password-1")
+		}
+	})
+}
@@ -2,15 +2,12 @@ package ubp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"math/rand"
+	"log"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 
@@ -18,22 +15,23 @@ import (
 	"github.com/mitchellh/mapstructure"
 )
 
+// UBP is a client for the UnionBank partner API. It holds only configuration
+// and clients shared by all requests, so a single UBP is safe for concurrent
+// use once initialized.
 type UBP struct {
-	Config              Config
-	FundTransferRequest FundTransferRequest
-}
+	Config Config
+
+	// RefIDGenerator issues the sender reference id of each transfer.
+	RefIDGenerator RefIDGenerator
 
-type ApiCall struct {
-	Url               string
-	Method            string
-	Body              io.Reader
-	AdditionalHeaders map[string]string
+	client *Client
+	tokens *tokenManager
 }
 
 const (
-	DisbursementMethodInstapay = "instapay"
-	DisbursementMethodPesonet  = "pesonet"
-	DisbursementMethodUbptoUbp = "ubp"
+	DisbursementMethodInstapay = disbursement.MethodInstapay
+	DisbursementMethodPesonet  = disbursement.MethodPesonet
+	DisbursementMethodUbptoUbp = disbursement.MethodUBP
 )
 
 type AuthTokenResponse struct {
@@ -72,32 +70,28 @@ type Sender struct {
 	Address Address `json:"address"`
 }
 
-var PartnerAddress = &Address{
-	Line1:    "Some Tower",
-	Line2:    "Some Barangay",
-	City:     "Some City",
-	Province: "Metro Manila",
-	ZipCode:  "4024",
-	Country:  "Philippines",
-}
-
-var PartnerSender = &Sender{
-	Name:    "Palngipang Corp.",
-	Address: *PartnerAddress,
-}
-
-var ClientBeneficary = &Beneficiary{
-	AccountNumber: "107324511489",
-	Name:          "Rachelle",
-	Address:       *PartnerAddress,
+// senderRefID returns the reference id assigned to d, issuing a new one if it
+// has none.
+func (u *UBP) senderRefID(d *disbursement.Disbursement) string {
+	if d.SenderRefID != "" {
+		return d.SenderRefID
+	}
+	return u.RefIDGenerator.NewRefID(d.ReferenceID)
 }
 
-var SampleRemittance = &Remittance{
-	Amount:        "2000.00",
-	Currency:      "PHP",
-	ReceivingBank: "161203",
-	Purpose:       "5 632",
-	Instructions:  "Test Pesonet",
+// newSender returns the wire form of a sender profile.
+func newSender(s *disbursement.Sender) Sender {
+	return Sender{
+		Name: s.Name,
+		Address: Address{
+			Line1:    s.Address.Line1,
+			Line2:    s.Address.Line2,
+			City:     s.Address.City,
+			Province: s.Address.Province,
+			ZipCode:  s.Address.ZipCode,
+			Country:  s.Address.Country,
+		},
+	}
 }
 
 type FundTransferRequest struct {
@@ -160,13 +154,43 @@ type PesonetStatusResponse struct {
 	TotalRecords uint32               `json:"totalRecords"`
 }
 
+// NewUBP returns a UBP client for the given configuration. It panics if the
+// configuration has no RefIDNodeID.
+func NewUBP(config Config) *UBP {
+	if config.RefIDNodeID == nil {
+		panic("ubp: no ref id node id configured")
+	}
+	u := &UBP{Config: config}
+	u.RefIDGenerator = NewTimeRefIDGenerator(*config.RefIDNodeID)
+	u.client = NewClient(&u.Config)
+	u.tokens = newTokenManager(u)
+	return u
+}
+
 func (u *UBP) Init() {
 	// u.Config.LoadConfiguration("/Users/jfpalngipang/fund-disbursement/ubp/config.dev.json")
 	u.Config.LoadConfiguration("/app/ubp/config.dev.json")
+	if u.Config.RefIDNodeID == nil {
+		log.Fatalln("ubp: set refIdNodeId or UBP_REF_ID_NODE_ID to a node id (0-99) unique among the instances sharing the partner account")
+	}
+	u.RefIDGenerator = NewTimeRefIDGenerator(*u.Config.RefIDNodeID)
+	u.client = NewClient(&u.Config)
+	u.tokens = newTokenManager(u)
+}
+
+// SetAuditLog records every call to the UBP API in l.
+func (u *UBP) SetAuditLog(l disbursement.AuditLog) {
+	u.client.Audit = l
+}
+
+// AccessToken returns a cached partner access token, renewing it when it is
+// about to expire.
+func (u *UBP) AccessToken(ctx context.Context) (string, error) {
+	return u.tokens.AccessToken(ctx)
 }
 
 // AuthenticatePartner to get token from UBP API
-func (u *UBP) AuthenticatePartner() (AuthTokenResponse, error) {
+func (u *UBP) AuthenticatePartner(ctx context.Context) (AuthTokenResponse, error) {
 	values := url.Values{}
 	values.Add("client_id", u.Config.ClientId)
 	values.Add("username", u.Config.Username)
@@ -174,6 +198,20 @@ func (u *UBP) AuthenticatePartner() (AuthTokenResponse, error) {
 	values.Add("grant_type", "password")
 	values.Add("scope", u.Config.Scope)
 
+	return u.requestToken(ctx, values)
+}
+
+// RefreshPartnerToken to exchange a refresh token for a new token from UBP API
+func (u *UBP) RefreshPartnerToken(ctx context.Context, refreshToken string) (AuthTokenResponse, error) {
+	values := url.Values{}
+	values.Add("client_id", u.Config.ClientId)
+	values.Add("refresh_token", refreshToken)
+	values.Add("grant_type", "refresh_token")
+
+	return u.requestToken(ctx, values)
+}
+
+func (u *UBP) requestToken(ctx context.Context, values url.Values) (AuthTokenResponse, error) {
 	apiCall := ApiCall{
 		Method: http.MethodPost,
 		Url:    u.Config.BaseUrl + u.Config.PartnerAuthPath,
@@ -183,7 +221,7 @@ func (u *UBP) AuthenticatePartner() (AuthTokenResponse, error) {
 		},
 	}
 
-	response, err := ApiRequest(&apiCall, &u.Config)
+	response, err := u.client.Do(ctx, &apiCall)
 	if err != nil {
 		fmt.Printf("Partner Auth API call error: %s\n", err)
 		return AuthTokenResponse{}, err
@@ -200,10 +238,11 @@ func (u *UBP) AuthenticatePartner() (AuthTokenResponse, error) {
 	return authResponse, nil
 }
 
-func (u *UBP) TransferFundsFromPartnerAccount(token string, method string, transferRequest *disbursement.Disbursement) (FundTransferResponse, error) {
+func (u *UBP) TransferFundsFromPartnerAccount(ctx context.Context, token string, method disbursement.Method, transferRequest *disbursement.Disbursement) (FundTransferResponse, error) {
+	if transferRequest.Sender == nil {
+		return FundTransferResponse{}, fmt.Errorf("%w: disbursement has no sender", disbursement.ErrInvalid)
+	}
 	reqDate := time.Now().Format("2006-01-02T15:04:05.000")
-	u.FundTransferRequest.RequestDate = reqDate[0:23] // max 23 chars oly
-	u.FundTransferRequest.Sender = *PartnerSender
 
 	addr := Address{
 		Line1:    transferRequest.Receiver.Address.Line1,
@@ -221,19 +260,22 @@ func (u *UBP) TransferFundsFromPartnerAccount(token string, method string, trans
 	}
 
 	var r = &Remittance{
-		Amount:        transferRequest.Details.Amount,
-		Currency:      transferRequest.Details.Currency,
+		Amount:        transferRequest.Details.Amount.String(),
+		Currency:      transferRequest.Details.Amount.Currency,
 		ReceivingBank: transferRequest.Details.ReceivingBank,
-		Purpose:       "1001",
-		Instructions:  "Fund Transfer via Instapay",
+		Purpose:       transferRequest.Details.Purpose,
+		Instructions:  instructions(method, transferRequest.Details.Instructions),
 	}
 
-	u.FundTransferRequest.Beneficiary = *ben
-	u.FundTransferRequest.Remittance = *r
-	refID := u.GenerateRefId()
-	u.FundTransferRequest.SenderRefId = refID
+	fundTransferRequest := FundTransferRequest{
+		SenderRefId: u.senderRefID(transferRequest),
+		RequestDate: reqDate[0:23], // max 23 chars oly
+		Sender:      newSender(transferRequest.Sender),
+		Beneficiary: *ben,
+		Remittance:  *r,
+	}
 
-	b, err := json.Marshal(u.FundTransferRequest)
+	b, err := json.Marshal(fundTransferRequest)
 	if err != nil {
 		fmt.Printf("Unmarshal Error: %s\n", err)
 		return FundTransferResponse{}, err
@@ -244,6 +286,8 @@ func (u *UBP) TransferFundsFromPartnerAccount(token string, method string, trans
 		apiPath = u.Config.InstapayPath
 	} else if method == DisbursementMethodPesonet {
 		apiPath = u.Config.PesonetPath
+	} else {
+		return FundTransferResponse{}, unsupportedMethod(method)
 	}
 
 	apiCall := ApiCall{
@@ -257,7 +301,7 @@ func (u *UBP) TransferFundsFromPartnerAccount(token string, method string, trans
 		},
 	}
 
-	response, err := ApiRequest(&apiCall, &u.Config)
+	response, err := u.client.Do(ctx, &apiCall)
 	if err != nil {
 		fmt.Printf("Error in Instapay Request: %s\n", err)
 		return FundTransferResponse{}, err
@@ -274,12 +318,14 @@ func (u *UBP) TransferFundsFromPartnerAccount(token string, method string, trans
 	return fundTransferResponse, nil
 }
 
-func (u *UBP) GetBanksForTransfer(method string) (GetBanksResponse, error) {
+func (u *UBP) GetBanksForTransfer(ctx context.Context, method disbursement.Method) (GetBanksResponse, error) {
 	var apiPath string
 	if method == DisbursementMethodInstapay {
 		apiPath = u.Config.InstapayGetBanksPath
 	} else if method == DisbursementMethodPesonet {
 		apiPath = u.Config.PesonetGetBanksPath
+	} else {
+		return GetBanksResponse{}, unsupportedMethod(method)
 	}
 
 	apiCall := ApiCall{
@@ -292,7 +338,7 @@ func (u *UBP) GetBanksForTransfer(method string) (GetBanksResponse, error) {
 		},
 	}
 
-	response, err := ApiRequest(&apiCall, &u.Config)
+	response, err := u.client.Do(ctx, &apiCall)
 	if err != nil {
 		fmt.Printf("Error in retrieving bank list: %s\n", err)
 		return GetBanksResponse{}, err
@@ -309,7 +355,7 @@ func (u *UBP) GetBanksForTransfer(method string) (GetBanksResponse, error) {
 	return getBanksResponse, nil
 }
 
-func (u *UBP) GetPesonetTransferStatus(referenceId string) (PesonetStatusResponse, error) {
+func (u *UBP) GetPesonetTransferStatus(ctx context.Context, referenceId string) (PesonetStatusResponse, error) {
 	apiPath := strings.Replace(u.Config.GetTransferStatusPath, "{referenceId}", referenceId, -1)
 	apiPath = strings.Replace(apiPath, "{method}", "pesonet", -1)
 	apiCall := ApiCall{
@@ -322,7 +368,7 @@ func (u *UBP) GetPesonetTransferStatus(referenceId string) (PesonetStatusRespons
 		},
 	}
 
-	response, err := ApiRequest(&apiCall, &u.Config)
+	response, err := u.client.Do(ctx, &apiCall)
 	if err != nil {
 		fmt.Printf("Error in retrieving bank list: %s\n", err)
 		return PesonetStatusResponse{}, err
@@ -339,7 +385,7 @@ func (u *UBP) GetPesonetTransferStatus(referenceId string) (PesonetStatusRespons
 	return pesonetStatusResponse, nil
 }
 
-func (u *UBP) GetInstapayTransferStatus(referenceId string) (InstapayStatusResponse, error) {
+func (u *UBP) GetInstapayTransferStatus(ctx context.Context, referenceId string) (InstapayStatusResponse, error) {
 	apiPath := strings.Replace(u.Config.GetTransferStatusPath, "{referenceId}", referenceId, -1)
 	apiPath = strings.Replace(apiPath, "{method}", "instapay", -1)
 	apiCall := ApiCall{
@@ -352,7 +398,7 @@ func (u *UBP) GetInstapayTransferStatus(referenceId string) (InstapayStatusRespo
 		},
 	}
 
-	response, err := ApiRequest(&apiCall, &u.Config)
+	response, err := u.client.Do(ctx, &apiCall)
 	if err != nil {
 		fmt.Printf("Error in retrieving bank list: %s\n", err)
 		return InstapayStatusResponse{}, err
@@ -369,50 +415,22 @@ func (u *UBP) GetInstapayTransferStatus(referenceId string) (InstapayStatusRespo
 	return instapayStatusResponse, nil
 }
 
-// ApiRequest for forming and sending request to UBP API
-func ApiRequest(api *ApiCall, conf *Config) (interface{}, error) {
-	req, _ := http.NewRequest(api.Method, api.Url, api.Body)
-
-	for k, v := range api.AdditionalHeaders {
-		req.Header.Add(k, v)
-	}
-
-	conf.SetHeaders(req)
-
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error: %s\n", err)
-		return nil, err
-	}
-
-	fmt.Println("RESPONSE:")
-	fmt.Println("For api call: " + req.URL.String())
-	fmt.Println("Status: ", res.Status)
-	fmt.Println()
-
-	defer res.Body.Close()
-	resBody, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		fmt.Printf("Error in reading response body: %s\n", err)
-		return nil, err
-	}
-
-	fmt.Println("Response Body: ", string(resBody))
-
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		fmt.Println("response error: ", string(resBody))
-		return nil, errors.New(string(resBody))
-	}
+// defaultInstructions describe a transfer whose request carries none.
+var defaultInstructions = map[disbursement.Method]string{
+	DisbursementMethodInstapay: "Fund Transfer via InstaPay",
+	DisbursementMethodPesonet:  "Fund Transfer via PESONet",
+	DisbursementMethodUbptoUbp: "Fund Transfer via UnionBank",
+}
 
-	var response interface{}
-	err = json.Unmarshal(resBody, &response)
-	if err != nil {
-		fmt.Printf("Error in unmarmshaling response body: %s\n", err)
-		return nil, err
+func instructions(method disbursement.Method, requested string) string {
+	if requested != "" {
+		return requested
 	}
+	return defaultInstructions[method]
+}
 
-	return response, nil
+func unsupportedMethod(method disbursement.Method) error {
+	return fmt.Errorf("%w: unsupported disbursement method %q", disbursement.ErrInvalid, method)
 }
 
 func (c *Config) SetHeaders(req *http.Request) {
@@ -422,9 +440,3 @@ func (c *Config) SetHeaders(req *http.Request) {
 	req.Header.Add("x-client-id", c.ClientId)
 	req.Header.Add("x-client-secret", c.ClientSecret)
 }
-
-func (u *UBP) GenerateRefId() string {
-	rand.Seed(time.Now().UnixNano())
-	sRef := strconv.Itoa(rand.Int())
-	return sRef[0:8]
-}
@@ -1,64 +1,186 @@
 package ubp
 
 import (
-	"testing"
-
-	"math/rand"
-	"strconv"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
-	"time"
+	"sync"
+	"testing"
 
+	disbursement "github.com/jfpalngipang/fund-disbursement"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-func TestUBP_AuthenticatePartner(t *testing.T) {
-	Convey("test getting partner access token", t, func() {
-		ubp := UBP{}
-		ubp.Init()
-		response, err := ubp.AuthenticatePartner()
-		So(err, ShouldBeNil)
-		So(response.TokenType, ShouldNotBeEmpty)
-		So(response.Scope, ShouldNotBeEmpty)
-		So(response.AccessToken, ShouldNotBeEmpty)
-		So(response.ExpiresIn, ShouldNotBeEmpty)
-		So(response.MetaData, ShouldNotBeEmpty)
-		So(response.RefreshToken, ShouldNotBeEmpty)
+var testConfig = Config{
+	PartnerAuthPath:       "/partners/v1/oauth2/token",
+	InstapayPath:          "/partners/v2/instapay/transfers/single",
+	PesonetPath:           "/partners/v2/pesonet/transfers/single",
+	GetTransferStatusPath: "/partners/v2/{method}/transfers/single/{referenceId}",
+	InstapayGetBanksPath:  "/partners/v2/instapay/banks",
+	PesonetGetBanksPath:   "/partners/v2/pesonet/banks",
+	UbpToUbpPath:          "/partners/v3/transfers/single",
+	UbpToUbpStatusPath:    "/partners/v3/transfers/single/{referenceId}",
+	ClientId:              "client-id",
+	ClientSecret:          "client-secret",
+	PartnerId:             "partner-id",
+	Username:              "partner_sb",
+	Password:              "p@ssw0rd",
+	Scope:                 "transfers payments instapay transfers_pesonet",
+	MaxRetries:            -1,
+	RefIDNodeID:           new(int),
+}
+
+// fakeUBP is an in-process stand-in for the UBP partner API.
+type fakeUBP struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	transfers    []FundTransferRequest
+	ubpTransfers []UbpTransferRequest
+}
+
+func newFakeUBP() *fakeUBP {
+	f := &fakeUBP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(testConfig.PartnerAuthPath, f.handleToken)
+	mux.HandleFunc(testConfig.InstapayPath, f.handleTransfer("tranId", "Credited Beneficiary Account"))
+	mux.HandleFunc(testConfig.PesonetPath, f.handleTransfer("ubpTranId", "Sent for Processing"))
+	mux.HandleFunc(testConfig.UbpToUbpPath, f.handleUbpTransfer)
+	mux.HandleFunc(testConfig.UbpToUbpPath+"/", f.handleUbpTransferStatus)
+	mux.HandleFunc("/partners/v2/instapay/transfers/single/", f.handleTransferStatus("Credited Beneficiary Account"))
+	mux.HandleFunc("/partners/v2/pesonet/transfers/single/", f.handleTransferStatus("Returned"))
+	mux.HandleFunc(testConfig.InstapayGetBanksPath, f.handleBanks)
+	mux.HandleFunc(testConfig.PesonetGetBanksPath, f.handleBanks)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// newTestUBP returns a UBP client pointed at f.
+func newTestUBP(f *fakeUBP) *UBP {
+	conf := testConfig
+	conf.BaseUrl = f.URL
+	return NewUBP(conf)
+}
+
+func (f *fakeUBP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-ibm-client-id") != testConfig.ClientId || r.FormValue("client_id") != testConfig.ClientId {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, AuthTokenResponse{
+		AccessToken:  "access-token",
+		ExpiresIn:    3600,
+		MetaData:     "a:metadata",
+		RefreshToken: "refresh-token",
+		Scope:        testConfig.Scope,
+		TokenType:    "bearer",
 	})
 }
 
-func TestInstaPay_TransferFundsFromPartnerAccount(t *testing.T) {
-	Convey("test instapay fund transfer", t, func() {
-		ubp := UBP{}
-		ubp.Init()
-		response, err := ubp.AuthenticatePartner()
-		So(err, ShouldBeNil)
-		So(response.AccessToken, ShouldNotEqual, "")
+func (f *fakeUBP) handleTransfer(idKey, state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req FundTransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.transfers = append(f.transfers, req)
+		f.mu.Unlock()
 
-		companyName := "Palngipang Corp."
-		companyPrimaryAddress := "Palngipang Tower"
-		companySecondaryAddress := "Fort Bonifacio"
-		companyPrimaryCity := "Taguig"
-		companyPrimaryState := "Metro Manila"
-		companyPrimaryZip := "2634"
-		companyPrimaryCountry := "Philippines"
-
-		sender := Sender{
-			Name: companyName,
-			Address: Address{
-				Line1:    companyPrimaryAddress,
-				Line2:    companySecondaryAddress,
-				City:     companyPrimaryCity,
-				Province: companyPrimaryState,
-				ZipCode:  companyPrimaryZip,
-				Country:  companyPrimaryCountry,
-			},
+		writeJSON(w, http.StatusOK, map[string]string{
+			idKey:         "UB" + req.SenderRefId,
+			"createdAt":   req.RequestDate,
+			"state":       state,
+			"senderRefId": req.SenderRefId,
+		})
+	}
+}
+
+func (f *fakeUBP) handleUbpTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req UbpTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.ubpTransfers = append(f.ubpTransfers, req)
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, UbpTransferResponse{
+		Code:             "TS",
+		SenderTransferId: req.SenderTransferId,
+		State:            "Credited Beneficiary Account",
+		Uuid:             "uuid-" + req.SenderTransferId,
+		UbpTranId:        "UB" + req.SenderTransferId,
+		TranRequestDate:  req.TransferRequestDate,
+	})
+}
+
+func (f *fakeUBP) handleUbpTransferStatus(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, testConfig.UbpToUbpPath+"/")
+	writeJSON(w, http.StatusOK, UbpTransferStatusResponse{
+		Code:             "TS",
+		SenderTransferId: id,
+		State:            "Credited Beneficiary Account",
+		UbpTranId:        "UB" + id,
+		Type:             "Partner Fund Transfer",
+	})
+}
+
+// handleTransferStatus reports every transfer except "unknown" as being in
+// the given state.
+func (f *fakeUBP) handleTransferStatus(state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if id == "unknown" {
+			writeJSON(w, http.StatusOK, PesonetStatusResponse{})
+			return
 		}
+		writeJSON(w, http.StatusOK, PesonetStatusResponse{
+			Records: []PesonetTransaction{{
+				UbpTranID:   "UB" + id,
+				Type:        "Partner Fund Transfer",
+				CreatedAt:   "2020-06-01T10:00:00.000",
+				UpdatedAt:   "2020-06-01T15:30:00.000",
+				State:       state,
+				SenderRefID: id,
+			}},
+			TotalRecords: 1,
+		})
+	}
+}
 
-		// NOTE: This should be from the profile service
-		beneficiary := Beneficiary{
-			AccountNumber: "109453095653", // NOTE:: Should be from the request
+func (f *fakeUBP) handleBanks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, GetBanksResponse{
+		Records:      []Bank{{Code: "161203", Bank: "BDO Unibank"}, {Code: "161408", Bank: "Metrobank"}},
+		TotalRecords: 2,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func testDisbursement(accountNumber, receivingBank string) *disbursement.Disbursement {
+	return &disbursement.Disbursement{
+		Receiver: disbursement.Receiver{
+			AccountNumber: accountNumber,
 			Name:          "Rachelle",
-			Address: Address{
+			Address: disbursement.Address{
 				Line1:    "241 A.DEL MUNDO ST BET. 5TH 6TH AVE GRACE",
 				Line2:    "PARK CALOOCAN CITY",
 				City:     "Caloocan",
@@ -66,76 +188,157 @@ func TestInstaPay_TransferFundsFromPartnerAccount(t *testing.T) {
 				ZipCode:  "1900",
 				Country:  "PH",
 			},
-		}
-
-		// NOTE:: Should all be from the request other than currency
-		remittance := Remittance{
-			Amount:        "30.00",
-			Currency:      "PHP",
-			ReceivingBank: "161408",
+		},
+		Details: disbursement.Details{
+			Amount:        disbursement.Money{Minor: 3000, Currency: "PHP"},
+			ReceivingBank: receivingBank,
 			Purpose:       "1001",
 			Instructions:  "Just a test case",
-		}
-		rand.Seed(time.Now().UnixNano())
-		refID := strconv.Itoa(rand.Int())
+		},
+		Sender: &disbursement.Sender{
+			ID:   "test",
+			Name: "Palngipang Corp.",
+			Address: disbursement.Address{
+				Line1:    "Some Tower",
+				City:     "Some City",
+				Province: "Metro Manila",
+				ZipCode:  "4024",
+				Country:  "Philippines",
+			},
+		},
+	}
+}
 
-		ubp.FundTransferRequest.Sender = sender
-		ubp.FundTransferRequest.SenderRefId = refID[0:8]
-		ubp.FundTransferRequest.Beneficiary = beneficiary
-		ubp.FundTransferRequest.Remittance = remittance
-		fundResponse, err := ubp.TransferFundsFromPartnerAccount(response.AccessToken, "instapay", ApiPathPartnerInstaPayFundTransfer)
+func TestUBP_AuthenticatePartner(t *testing.T) {
+	Convey("test getting partner access token", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+		response, err := ubp.AuthenticatePartner(context.Background())
+		So(err, ShouldBeNil)
+		So(response.TokenType, ShouldNotBeEmpty)
+		So(response.Scope, ShouldNotBeEmpty)
+		So(response.AccessToken, ShouldNotBeEmpty)
+		So(response.ExpiresIn, ShouldNotBeEmpty)
+		So(response.MetaData, ShouldNotBeEmpty)
+		So(response.RefreshToken, ShouldNotBeEmpty)
+	})
+}
 
+func TestInstaPay_TransferFundsFromPartnerAccount(t *testing.T) {
+	Convey("test instapay fund transfer", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+		ctx := context.Background()
+		response, err := ubp.AuthenticatePartner(ctx)
 		So(err, ShouldBeNil)
-		So(fundResponse.SenderRefId, ShouldEqual, ubp.FundTransferRequest.SenderRefId)
+		So(response.AccessToken, ShouldNotEqual, "")
+
+		d := testDisbursement("109453095653", "161408")
+		fundResponse, err := ubp.TransferFundsFromPartnerAccount(ctx, response.AccessToken, DisbursementMethodInstapay, d)
+
+		So(err, ShouldBeNil)
+		So(fake.transfers, ShouldHaveLength, 1)
+		So(fundResponse.SenderRefId, ShouldEqual, fake.transfers[0].SenderRefId)
 		So(fundResponse.CreatedAt, ShouldNotBeEmpty)
 		So(fundResponse.TranId, ShouldNotBeEmpty)
 		So(strings.ToLower(fundResponse.State), ShouldContainSubstring, strings.ToLower("Credited Beneficiary Account"))
+		So(fake.transfers[0].Beneficiary.AccountNumber, ShouldEqual, "109453095653")
+		So(fake.transfers[0].Remittance.Amount, ShouldEqual, "30.00")
+		So(fake.transfers[0].Sender.Name, ShouldEqual, "Palngipang Corp.")
+		So(fake.transfers[0].Sender.Address.City, ShouldEqual, "Some City")
+	})
+
+	Convey("test fund transfer without a sender", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+
+		d := testDisbursement("109453095653", "161408")
+		d.Sender = nil
+		_, err := ubp.TransferFundsFromPartnerAccount(context.Background(), "access-token", DisbursementMethodInstapay, d)
+
+		So(errors.Is(err, disbursement.ErrInvalid), ShouldBeTrue)
+		So(fake.transfers, ShouldBeEmpty)
+	})
+
+	Convey("test instapay fund transfer without instructions", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+
+		d := testDisbursement("109453095653", "161408")
+		d.Details.Instructions = ""
+		_, err := ubp.TransferFundsFromPartnerAccount(context.Background(), "access-token", DisbursementMethodInstapay, d)
+
+		So(err, ShouldBeNil)
+		So(fake.transfers[0].Remittance.Purpose, ShouldEqual, "1001")
+		So(fake.transfers[0].Remittance.Instructions, ShouldEqual, "Fund Transfer via InstaPay")
 	})
 }
 
 func TestPesoNet_TransferFundsFromPartnerAccount(t *testing.T) {
 	Convey("test pesonet fund transfer", t, func() {
-		ubp := UBP{}
-		ubp.Init()
-		response, err := ubp.AuthenticatePartner()
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+		ctx := context.Background()
+		response, err := ubp.AuthenticatePartner(ctx)
 		So(err, ShouldBeNil)
 		So(response.AccessToken, ShouldNotEqual, "")
 
-		// NOTE: This should be from the profile service
-		beneficiary := Beneficiary{
-			AccountNumber: "107324511489", // NOTE:: Should be from the request
-			Name:          "Rachel",
-			Address: Address{
-				Line1:    "241 A.DEL MUNDO ST BET. 5TH 6TH AVE GRACE",
-				Line2:    "PARK CALOOCAN CITY",
-				City:     "Caloocan",
-				Province: "Manila",
-				ZipCode:  "1900",
-				Country:  "PH",
-			},
-		}
-
-		// NOTE:: Should all be from the request other than currency
-		remittance := Remittance{
-			Amount:        "30.00",
-			Currency:      "PHP",
-			ReceivingBank: "161203",
-			Purpose:       "1001",
-			Instructions:  "Just a test case",
-		}
-
-		rand.Seed(time.Now().UnixNano())
-		refID := strconv.Itoa(rand.Int())
-		ubp.FundTransferRequest.SenderRefId = refID[0:8]
-		ubp.FundTransferRequest.Beneficiary = beneficiary
-		ubp.FundTransferRequest.Remittance = remittance
-		fundResponse, err := ubp.TransferFundsFromPartnerAccount(&ubp.Config, response.AccessToken, ApiPathPartnerPESONetFundTransfer)
+		d := testDisbursement("107324511489", "161203")
+		fundResponse, err := ubp.TransferFundsFromPartnerAccount(ctx, response.AccessToken, DisbursementMethodPesonet, d)
 
 		So(err, ShouldBeNil)
-		So(fundResponse.SenderRefId, ShouldEqual, ubp.FundTransferRequest.SenderRefId)
+		So(fake.transfers, ShouldHaveLength, 1)
+		So(fundResponse.SenderRefId, ShouldEqual, fake.transfers[0].SenderRefId)
 		So(fundResponse.CreatedAt, ShouldNotBeEmpty)
 		So(fundResponse.UbpTranId, ShouldNotBeEmpty)
 		So(strings.ToLower(fundResponse.State), ShouldContainSubstring, "sent for processing")
-		//So(strings.ToLower(fundResponse.State), ShouldContainSubstring, strings.ToLower("Credited Beneficiary Account"))
+		So(fake.transfers[0].Remittance.Purpose, ShouldEqual, "1001")
+		So(fake.transfers[0].Remittance.Instructions, ShouldEqual, "Just a test case")
+	})
+}
+
+func TestUBP_GetBanksForTransfer(t *testing.T) {
+	Convey("test getting banks for instapay", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+		response, err := ubp.GetBanksForTransfer(context.Background(), DisbursementMethodInstapay)
+		So(err, ShouldBeNil)
+		So(response.Records, ShouldHaveLength, 2)
+		So(response.Records[0].Code, ShouldEqual, "161203")
+	})
+}
+
+func TestUbpToUbp_TransferFundsToUbpAccount(t *testing.T) {
+	Convey("test ubp to ubp fund transfer", t, func() {
+		fake := newFakeUBP()
+		defer fake.Close()
+		ubp := newTestUBP(fake)
+		ctx := context.Background()
+		response, err := ubp.AuthenticatePartner(ctx)
+		So(err, ShouldBeNil)
+
+		d := testDisbursement("109453095653", "")
+		fundResponse, err := ubp.TransferFundsToUbpAccount(ctx, response.AccessToken, d)
+
+		So(err, ShouldBeNil)
+		So(fake.ubpTransfers, ShouldHaveLength, 1)
+		req := fake.ubpTransfers[0]
+		So(req.AccountNo, ShouldEqual, "109453095653")
+		So(req.Amount.Value, ShouldEqual, "30.00")
+		So(req.Amount.Currency, ShouldEqual, "PHP")
+		So(fundResponse.SenderTransferId, ShouldEqual, req.SenderTransferId)
+		So(fundResponse.UbpTranId, ShouldNotBeEmpty)
+		So(strings.ToLower(fundResponse.State), ShouldContainSubstring, "credited beneficiary account")
+
+		status, err := ubp.GetUbpTransferStatus(ctx, req.SenderTransferId)
+		So(err, ShouldBeNil)
+		So(status.SenderTransferId, ShouldEqual, req.SenderTransferId)
+		So(status.UbpTranId, ShouldEqual, fundResponse.UbpTranId)
 	})
 }
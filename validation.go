@@ -0,0 +1,245 @@
+package disbursement
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field lengths enforced by UBP on beneficiary details.
+const (
+	MaxNameLen         = 50
+	MaxAddressLineLen  = 50
+	MaxCityLen         = 30
+	MaxProvinceLen     = 30
+	MaxZipCodeLen      = 10
+	MaxCountryLen      = 30
+	MaxInstructionsLen = 140
+)
+
+// Account number lengths. UnionBank accounts are always 12 digits; other
+// banks' account numbers vary in length.
+const (
+	UBPAccountNumberLen = 12
+	MinAccountNumberLen = 6
+	MaxAccountNumberLen = 19
+)
+
+// DefaultMaxAmounts are the per-transaction caps of each rail. InstaPay is
+// capped by the clearing switch; PESONet and intrabank transfers have no
+// rail-level cap.
+var DefaultMaxAmounts = map[Method]Money{
+	MethodInstapay: {Minor: 50000 * 100, Currency: DefaultCurrency},
+}
+
+// FieldError describes an invalid field of a request. Field is the JSON path
+// of the field, e.g. "receiver.accountNumber", or the column of an uploaded
+// file.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+
+	// Line is the line of an uploaded file the field is on, if any.
+	Line int `json:"line,omitempty"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Line > 0 {
+		return "line " + strconv.Itoa(e.Line) + ": " + e.Field + ": " + e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every invalid field of a request. It matches
+// ErrInvalid.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrInvalid }
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// BankLookup reports whether a bank participates in a rail.
+type BankLookup interface {
+	HasBank(ctx context.Context, method Method, code string) (bool, error)
+}
+
+// Validator checks a Disbursement against what the provider will accept
+// before it is sent.
+type Validator struct {
+	// Banks checks receiving bank codes. If nil, codes are not checked
+	// against the rail's bank list.
+	Banks BankLookup
+
+	// MaxAmounts caps the amount of a single transfer per rail.
+	MaxAmounts map[Method]Money
+
+	// Purposes, if set, are the purpose codes of the partner agreement and
+	// other codes are refused. Otherwise any code is sent, for UBP to accept
+	// or reject.
+	Purposes []Purpose
+}
+
+// NewValidator returns a Validator using the default rail caps.
+func NewValidator(banks BankLookup) *Validator {
+	return &Validator{Banks: banks, MaxAmounts: DefaultMaxAmounts}
+}
+
+// Validate returns a *ValidationError listing every problem with d for the
+// given rail, or nil if d is valid. Other errors come from the bank lookup.
+func (v *Validator) Validate(ctx context.Context, method Method, d *Disbursement) error {
+	verr := &ValidationError{}
+	interbank := method.Interbank()
+
+	r := d.Receiver
+	checkAccountNumber(verr, method, r.AccountNumber)
+	checkText(verr, "receiver.name", r.Name, MaxNameLen, true)
+	checkText(verr, "receiver.address.line1", r.Address.Line1, MaxAddressLineLen, interbank)
+	checkText(verr, "receiver.address.line2", r.Address.Line2, MaxAddressLineLen, false)
+	checkText(verr, "receiver.address.city", r.Address.City, MaxCityLen, interbank)
+	checkText(verr, "receiver.address.province", r.Address.Province, MaxProvinceLen, interbank)
+	checkText(verr, "receiver.address.zipCode", r.Address.ZipCode, MaxZipCodeLen, false)
+	checkText(verr, "receiver.address.country", r.Address.Country, MaxCountryLen, interbank)
+
+	det := d.Details
+	if det.Amount.Currency != DefaultCurrency {
+		verr.add("transfer_details.currency", "must be %s", DefaultCurrency)
+	} else if !det.Amount.IsPositive() {
+		verr.add("transfer_details.amount", "must be greater than zero")
+	} else if max, ok := v.MaxAmounts[method]; ok && det.Amount.Cmp(max) > 0 {
+		verr.add("transfer_details.amount", "exceeds the %s limit of %s per transfer", method, max)
+	}
+	checkText(verr, "transfer_details.instructions", det.Instructions, MaxInstructionsLen, false)
+	if det.Purpose == "" {
+		verr.add("transfer_details.purpose", "is required")
+	} else if !v.knownPurpose(det.Purpose) {
+		verr.add("transfer_details.purpose", "unknown purpose code %q", det.Purpose)
+	}
+
+	if interbank {
+		if det.ReceivingBank == "" {
+			verr.add("transfer_details.receivingBank", "is required")
+		} else if v.Banks != nil {
+			ok, err := v.Banks.HasBank(ctx, method, det.ReceivingBank)
+			if err != nil {
+				return err
+			} else if !ok {
+				verr.add("transfer_details.receivingBank", "bank %s does not participate in %s", det.ReceivingBank, method)
+			}
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// knownPurpose reports whether code may be sent as a purpose.
+func (v *Validator) knownPurpose(code string) bool {
+	if len(v.Purposes) == 0 {
+		return true
+	}
+	for _, p := range v.Purposes {
+		if p.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSender returns a *ValidationError listing every problem with a
+// sender profile, or nil if it can be sent as the originator of a transfer.
+func ValidateSender(s *Sender) error {
+	verr := &ValidationError{}
+	checkText(verr, "id", s.ID, MaxNameLen, true)
+	checkText(verr, "name", s.Name, MaxNameLen, true)
+	checkText(verr, "address.line1", s.Address.Line1, MaxAddressLineLen, true)
+	checkText(verr, "address.line2", s.Address.Line2, MaxAddressLineLen, false)
+	checkText(verr, "address.city", s.Address.City, MaxCityLen, true)
+	checkText(verr, "address.province", s.Address.Province, MaxProvinceLen, true)
+	checkText(verr, "address.zipCode", s.Address.ZipCode, MaxZipCodeLen, false)
+	checkText(verr, "address.country", s.Address.Country, MaxCountryLen, true)
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// ValidateClient checks that c has an id, known scopes and at least one
+// way to authenticate.
+func ValidateClient(c *Client) error {
+	verr := &ValidationError{}
+	if c.ID == "" {
+		verr.add("id", "is required")
+	}
+	for _, s := range c.Scopes {
+		switch s {
+		case ScopeReadBanks, ScopeReadStatus, ScopeCreateTransfer, ScopeApprove, ScopeManageWebhooks,
+			ScopeReadLedger, ScopeWriteLedger:
+		default:
+			verr.add("scopes", "unknown scope %q", s)
+		}
+	}
+	for _, h := range c.APIKeyHashes {
+		if b, err := hex.DecodeString(h); err != nil || len(b) != sha256.Size {
+			verr.add("api_key_hashes", "must be hex SHA-256 digests")
+			break
+		}
+	}
+	if len(c.APIKeyHashes) == 0 && c.SigningSecret == "" {
+		verr.add("api_key_hashes", "or a signing_secret is required")
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func checkAccountNumber(verr *ValidationError, method Method, acct string) {
+	const field = "receiver.accountNumber"
+	switch {
+	case acct == "":
+		verr.add(field, "is required")
+	case !isDigits(acct):
+		verr.add(field, "must contain digits only")
+	case method == MethodUBP && len(acct) != UBPAccountNumberLen:
+		verr.add(field, "must be %d digits for UnionBank accounts", UBPAccountNumberLen)
+	case len(acct) < MinAccountNumberLen || len(acct) > MaxAccountNumberLen:
+		verr.add(field, "must be %d to %d digits", MinAccountNumberLen, MaxAccountNumberLen)
+	}
+}
+
+func checkText(verr *ValidationError, field, s string, max int, required bool) {
+	if strings.TrimSpace(s) == "" {
+		if required {
+			verr.add(field, "is required")
+		}
+	} else if len([]rune(s)) > max {
+		verr.add(field, "must be at most %d characters", max)
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package disbursement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeBankLookup map[Method][]string
+
+func (f fakeBankLookup) HasBank(ctx context.Context, method Method, code string) (bool, error) {
+	for _, c := range f[method] {
+		if c == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func validDisbursement() *Disbursement {
+	return &Disbursement{
+		Receiver: Receiver{
+			AccountNumber: "109453095653",
+			Name:          "Juan Dela Cruz",
+			Address: Address{
+				Line1:    "Unit 11C15 Fort Victoria",
+				City:     "Taguig",
+				Province: "Metro Manila",
+				ZipCode:  "1630",
+				Country:  "PH",
+			},
+		},
+		Details: Details{
+			Amount:        Money{Minor: 10000, Currency: "PHP"},
+			ReceivingBank: "161203",
+			Purpose:       "1003",
+		},
+	}
+}
+
+func fields(err error) []string {
+	var verr *ValidationError
+	So(errors.As(err, &verr), ShouldBeTrue)
+	var out []string
+	for _, fe := range verr.Errors {
+		out = append(out, fe.Field)
+	}
+	return out
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := NewValidator(fakeBankLookup{MethodInstapay: {"161203"}, MethodPesonet: {"161203", "161408"}})
+	ctx := context.Background()
+
+	Convey("a complete disbursement is valid", t, func() {
+		So(v.Validate(ctx, MethodInstapay, validDisbursement()), ShouldBeNil)
+	})
+
+	Convey("every invalid field is reported", t, func() {
+		d := validDisbursement()
+		d.Receiver.AccountNumber = "10945-3095"
+		d.Receiver.Name = ""
+		d.Receiver.Address.City = strings.Repeat("x", MaxCityLen+1)
+		d.Details.Amount = Money{}
+
+		err := v.Validate(ctx, MethodInstapay, d)
+		So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+		So(fields(err), ShouldResemble, []string{
+			"receiver.accountNumber",
+			"receiver.name",
+			"receiver.address.city",
+			"transfer_details.currency",
+		})
+	})
+
+	Convey("purpose codes are passed on unless there is a purpose code table", t, func() {
+		d := validDisbursement()
+		d.Details.Purpose = "5 632"
+		So(v.Validate(ctx, MethodPesonet, d), ShouldBeNil)
+
+		table := *v
+		table.Purposes = []Purpose{{Code: "1003", Description: "Fund Transfer"}}
+		So(fields(table.Validate(ctx, MethodPesonet, d)), ShouldResemble, []string{"transfer_details.purpose"})
+		So(table.Validate(ctx, MethodPesonet, validDisbursement()), ShouldBeNil)
+	})
+
+	Convey("receiving banks must participate in the rail", t, func() {
+		d := validDisbursement()
+		d.Details.ReceivingBank = "161408"
+		So(fields(v.Validate(ctx, MethodInstapay, d)), ShouldResemble, []string{"transfer_details.receivingBank"})
+		So(v.Validate(ctx, MethodPesonet, d), ShouldBeNil)
+	})
+
+	Convey("rail amount caps are enforced", t, func() {
+		d := validDisbursement()
+		d.Details.Amount = Money{Minor: 50000*100 + 1, Currency: "PHP"}
+		So(fields(v.Validate(ctx, MethodInstapay, d)), ShouldResemble, []string{"transfer_details.amount"})
+		So(v.Validate(ctx, MethodPesonet, d), ShouldBeNil)
+	})
+
+	Convey("intrabank transfers need a UnionBank account but no bank or address", t, func() {
+		d := validDisbursement()
+		d.Details.ReceivingBank = ""
+		d.Receiver.Address = Address{}
+		So(v.Validate(ctx, MethodUBP, d), ShouldBeNil)
+
+		d.Receiver.AccountNumber = "1094530956"
+		So(fields(v.Validate(ctx, MethodUBP, d)), ShouldResemble, []string{"receiver.accountNumber"})
+	})
+}
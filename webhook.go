@@ -0,0 +1,165 @@
+package disbursement
+
+import (
+	"context"
+	"time"
+)
+
+// WebhookEndpoint is a URL a client registered to receive events.
+type WebhookEndpoint struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+
+	// Secret signs every delivery to the endpoint. It is only shown to the
+	// client when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+
+	// Events lists the event types sent to the endpoint. If empty, every
+	// event is sent.
+	Events []EventType `json:"events,omitempty"`
+
+	// ClientID is the API client that registered the endpoint. Endpoints
+	// only receive events about that client's transactions; endpoints
+	// registered anonymously receive every event.
+	ClientID string `json:"client_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether e should be sent to the endpoint.
+func (w *WebhookEndpoint) Wants(e *Event) bool {
+	if w.ClientID != "" && (e.Transaction == nil || e.Transaction.ClientID != w.ClientID) {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, typ := range w.Events {
+		if typ == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWebhookEndpointID returns a random endpoint id.
+func NewWebhookEndpointID() string {
+	return newID("wh_")
+}
+
+// NewWebhookSecret returns a random signing secret.
+func NewWebhookSecret() string {
+	return newID("whsec_")
+}
+
+// WebhookDeliveryState is the progress of delivering one event to one
+// endpoint.
+type WebhookDeliveryState string
+
+const (
+	// WebhookDeliveryPending means the event is waiting for its next
+	// delivery attempt.
+	WebhookDeliveryPending WebhookDeliveryState = "pending"
+
+	// WebhookDeliveryDelivered means the endpoint acknowledged the event.
+	WebhookDeliveryDelivered WebhookDeliveryState = "delivered"
+
+	// WebhookDeliveryDead means every attempt failed. Dead deliveries are
+	// kept so the event can be replayed.
+	WebhookDeliveryDead WebhookDeliveryState = "dead"
+)
+
+// WebhookDelivery tracks the attempts to deliver an event to an endpoint.
+type WebhookDelivery struct {
+	ID         string               `json:"id"`
+	EndpointID string               `json:"endpoint_id"`
+	EventID    string               `json:"event_id"`
+	State      WebhookDeliveryState `json:"state"`
+
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+
+	// LastStatus and LastError describe the last failed attempt.
+	LastStatus int    `json:"last_status,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// NewWebhookDeliveryID returns a random delivery id.
+func NewWebhookDeliveryID() string {
+	return newID("whd_")
+}
+
+// WebhookDeliveryFilter selects deliveries. Zero fields match everything.
+type WebhookDeliveryFilter struct {
+	EndpointID string
+	EventID    string
+	State      WebhookDeliveryState
+
+	// DueBy selects deliveries whose next attempt is at or before it.
+	DueBy time.Time
+
+	// Limit caps the number of deliveries returned. Zero means no limit.
+	Limit int
+}
+
+// Match reports whether d is selected by f, ignoring Limit.
+func (f WebhookDeliveryFilter) Match(d *WebhookDelivery) bool {
+	switch {
+	case f.EndpointID != "" && d.EndpointID != f.EndpointID:
+		return false
+	case f.EventID != "" && d.EventID != f.EventID:
+		return false
+	case f.State != "" && d.State != f.State:
+		return false
+	case !f.DueBy.IsZero() && d.NextAttemptAt.After(f.DueBy):
+		return false
+	}
+	return true
+}
+
+// WebhookStore persists webhook endpoints, the events sent to them and the
+// state of each delivery.
+type WebhookStore interface {
+	CreateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error
+
+	// DeleteWebhookEndpoint returns ErrNotFound if there is no such
+	// endpoint.
+	DeleteWebhookEndpoint(ctx context.Context, id string) error
+
+	FindWebhookEndpoints(ctx context.Context) ([]*WebhookEndpoint, error)
+
+	CreateEvent(ctx context.Context, e *Event) error
+
+	// FindEventByID returns ErrNotFound if there is no such event.
+	FindEventByID(ctx context.Context, id string) (*Event, error)
+
+	CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+
+	// UpdateWebhookDelivery returns ErrNotFound if d was never created.
+	UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+
+	// FindWebhookDeliveries returns the deliveries matching filter, oldest
+	// first.
+	FindWebhookDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*WebhookDelivery, error)
+}
+
+// WebhookService manages webhook endpoints on behalf of API clients.
+type WebhookService interface {
+	// CreateWebhookEndpoint registers e, assigning its ID and Secret.
+	CreateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error
+
+	DeleteWebhookEndpoint(ctx context.Context, id string) error
+
+	// FindWebhookEndpoints returns the endpoints visible to the calling
+	// client, without their secrets.
+	FindWebhookEndpoints(ctx context.Context) ([]*WebhookEndpoint, error)
+
+	FindWebhookDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*WebhookDelivery, error)
+
+	// ReplayEvent delivers a stored event again, to the endpoint with id
+	// endpointID or, if it is empty, to every endpoint that wants it.
+	ReplayEvent(ctx context.Context, eventID, endpointID string) ([]*WebhookDelivery, error)
+}
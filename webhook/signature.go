@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the signature of every delivery, in the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256>". The HMAC is computed with the
+// endpoint's secret over the timestamp, a period and the raw request body.
+const SignatureHeader = "Disbursement-Signature"
+
+// DefaultTolerance is how old a signature Verify accepts by default.
+const DefaultTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned by Verify for a missing, malformed, stale
+// or mismatched signature.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks a SignatureHeader value against body. Signatures made more
+// than tolerance before or after now are rejected to prevent replays.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	want := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,386 @@
+// Package webhook delivers disbursement events to endpoints registered by API
+// clients. Events and deliveries are written to a WebhookStore before any
+// attempt is made, so they survive restarts; failed deliveries are retried
+// with exponential backoff and kept as dead letters once retries run out.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+)
+
+// Dispatcher defaults.
+const (
+	DefaultMaxAttempts  = 8
+	DefaultMinBackoff   = 30 * time.Second
+	DefaultMaxBackoff   = 6 * time.Hour
+	DefaultPollInterval = 10 * time.Second
+	DefaultConcurrency  = 4
+	DefaultTimeout      = 10 * time.Second
+)
+
+// Headers sent with every delivery besides SignatureHeader.
+const (
+	EventIDHeader   = "Disbursement-Event-Id"
+	EventTypeHeader = "Disbursement-Event-Type"
+)
+
+// Dispatcher is a disbursement.EventPublisher that delivers events to webhook
+// endpoints, and a disbursement.WebhookService that manages them.
+type Dispatcher struct {
+	Store      disbursement.WebhookStore
+	HTTPClient *http.Client
+
+	// MaxAttempts is how many times a delivery is tried before it is
+	// declared dead.
+	MaxAttempts int
+
+	// MinBackoff is the wait after the first failed attempt. It doubles
+	// with every further failure, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// PollInterval is how often Run looks for deliveries that are due.
+	PollInterval time.Duration
+
+	// Concurrency caps the number of deliveries in flight.
+	Concurrency int
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+func NewDispatcher(store disbursement.WebhookStore) *Dispatcher {
+	return &Dispatcher{
+		Store:        store,
+		HTTPClient:   &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts:  DefaultMaxAttempts,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		PollInterval: DefaultPollInterval,
+		Concurrency:  DefaultConcurrency,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// PublishEvent stores e and schedules its delivery to every endpoint that
+// wants it.
+func (d *Dispatcher) PublishEvent(ctx context.Context, e *disbursement.Event) error {
+	if err := d.Store.CreateEvent(ctx, e); err != nil {
+		return err
+	}
+	endpoints, err := d.Store.FindWebhookEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ep := range endpoints {
+		if !ep.Wants(e) {
+			continue
+		}
+		if _, err := d.schedule(ctx, e, ep); err != nil {
+			return err
+		}
+	}
+	d.notify()
+	return nil
+}
+
+// schedule creates a delivery of e to ep, due immediately.
+func (d *Dispatcher) schedule(ctx context.Context, e *disbursement.Event, ep *disbursement.WebhookEndpoint) (*disbursement.WebhookDelivery, error) {
+	now := d.now()
+	delivery := &disbursement.WebhookDelivery{
+		ID:            disbursement.NewWebhookDeliveryID(),
+		EndpointID:    ep.ID,
+		EventID:       e.ID,
+		State:         disbursement.WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := d.Store.CreateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// notify wakes Run without blocking.
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.Deliver(ctx); err != nil {
+			log.Printf("webhook: deliver: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Deliver makes one attempt at every delivery that is due.
+func (d *Dispatcher) Deliver(ctx context.Context) error {
+	due, err := d.Store.FindWebhookDeliveries(ctx, disbursement.WebhookDeliveryFilter{
+		State: disbursement.WebhookDeliveryPending,
+		DueBy: d.now(),
+	})
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	endpoints, err := d.Store.FindWebhookEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*disbursement.WebhookEndpoint, len(endpoints))
+	for _, ep := range endpoints {
+		byID[ep.ID] = ep
+	}
+
+	sem := make(chan struct{}, d.Concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *disbursement.WebhookDelivery) {
+			defer func() { <-sem; wg.Done() }()
+			d.attempt(ctx, delivery, byID[delivery.EndpointID])
+		}(delivery)
+	}
+	wg.Wait()
+	return nil
+}
+
+// attempt sends a delivery once and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *disbursement.WebhookDelivery, ep *disbursement.WebhookEndpoint) {
+	var status int
+	var err error
+	if ep == nil {
+		err = fmt.Errorf("endpoint %s was deleted", delivery.EndpointID)
+		delivery.Attempts = d.MaxAttempts - 1
+	} else {
+		status, err = d.send(ctx, delivery, ep)
+	}
+
+	now := d.now()
+	delivery.Attempts++
+	if err == nil {
+		delivery.State = disbursement.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastStatus, delivery.LastError = 0, ""
+	} else {
+		delivery.LastStatus, delivery.LastError = status, err.Error()
+		if delivery.Attempts >= d.MaxAttempts {
+			delivery.State = disbursement.WebhookDeliveryDead
+		} else {
+			delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+		}
+	}
+	if err := d.Store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		log.Printf("webhook: cannot record delivery %s: %s", delivery.ID, err)
+	}
+}
+
+// send posts the delivery's event to ep. It returns the response status and
+// an error unless the endpoint answered 2xx.
+func (d *Dispatcher) send(ctx context.Context, delivery *disbursement.WebhookDelivery, ep *disbursement.WebhookEndpoint) (int, error) {
+	e, err := d.Store.FindEventByID(ctx, delivery.EventID)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, e.ID)
+	req.Header.Set(EventTypeHeader, string(e.Type))
+	req.Header.Set(SignatureHeader, Sign(ep.Secret, d.now(), body))
+
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.MinBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
+
+// CreateWebhookEndpoint registers e for the calling client, assigning its ID
+// and signing secret.
+func (d *Dispatcher) CreateWebhookEndpoint(ctx context.Context, e *disbursement.WebhookEndpoint) error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: webhook url must be an absolute http or https url", disbursement.ErrInvalid)
+	}
+	for _, typ := range e.Events {
+		if !knownEvent(typ) {
+			return fmt.Errorf("%w: unknown event type %q", disbursement.ErrInvalid, typ)
+		}
+	}
+
+	e.ID = disbursement.NewWebhookEndpointID()
+	e.Secret = disbursement.NewWebhookSecret()
+	e.ClientID = disbursement.ClientIDFromContext(ctx)
+	e.CreatedAt = d.now()
+	return d.Store.CreateWebhookEndpoint(ctx, e)
+}
+
+// DeleteWebhookEndpoint removes one of the calling client's endpoints.
+// Pending deliveries to it are declared dead on their next attempt.
+func (d *Dispatcher) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	if _, err := d.findEndpoint(ctx, id); err != nil {
+		return err
+	}
+	return d.Store.DeleteWebhookEndpoint(ctx, id)
+}
+
+// FindWebhookEndpoints returns the calling client's endpoints without their
+// secrets.
+func (d *Dispatcher) FindWebhookEndpoints(ctx context.Context) ([]*disbursement.WebhookEndpoint, error) {
+	endpoints, err := d.Store.FindWebhookEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]*disbursement.WebhookEndpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if disbursement.CallerOwns(ctx, ep.ClientID) {
+			ep.Secret = ""
+			visible = append(visible, ep)
+		}
+	}
+	return visible, nil
+}
+
+// FindWebhookDeliveries returns the deliveries to the calling client's
+// endpoints that match filter.
+func (d *Dispatcher) FindWebhookDeliveries(ctx context.Context, filter disbursement.WebhookDeliveryFilter) ([]*disbursement.WebhookDelivery, error) {
+	endpoints, err := d.FindWebhookEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		visible[ep.ID] = true
+	}
+
+	limit := filter.Limit
+	filter.Limit = 0
+	deliveries, err := d.Store.FindWebhookDeliveries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*disbursement.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if visible[delivery.EndpointID] {
+			out = append(out, delivery)
+		}
+	}
+	return out, nil
+}
+
+// ReplayEvent schedules a new delivery of a stored event, to the endpoint with
+// id endpointID or, if it is empty, to every endpoint of the calling client
+// that wants the event.
+func (d *Dispatcher) ReplayEvent(ctx context.Context, eventID, endpointID string) ([]*disbursement.WebhookDelivery, error) {
+	e, err := d.Store.FindEventByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []*disbursement.WebhookEndpoint
+	if endpointID != "" {
+		ep, err := d.findEndpoint(ctx, endpointID)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, ep)
+	} else if endpoints, err = d.Store.FindWebhookEndpoints(ctx); err != nil {
+		return nil, err
+	}
+
+	deliveries := []*disbursement.WebhookDelivery{}
+	for _, ep := range endpoints {
+		if !disbursement.CallerOwns(ctx, ep.ClientID) || !ep.Wants(e) {
+			continue
+		}
+		delivery, err := d.schedule(ctx, e, ep)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%w: no endpoint wants event %s", disbursement.ErrNotFound, eventID)
+	}
+	d.notify()
+	return deliveries, nil
+}
+
+// findEndpoint returns the calling client's endpoint with the given id.
+func (d *Dispatcher) findEndpoint(ctx context.Context, id string) (*disbursement.WebhookEndpoint, error) {
+	endpoints, err := d.Store.FindWebhookEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range endpoints {
+		if ep.ID == id && disbursement.CallerOwns(ctx, ep.ClientID) {
+			return ep, nil
+		}
+	}
+	return nil, disbursement.ErrNotFound
+}
+
+func knownEvent(typ disbursement.EventType) bool {
+	switch typ {
+	case disbursement.EventTransferCreated, disbursement.EventTransferCredited,
+		disbursement.EventTransferFailed, disbursement.EventTransferReturned,
+		disbursement.EventTransferEscalated:
+		return true
+	}
+	return false
+}
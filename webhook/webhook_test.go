@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	disbursement "github.com/jfpalngipang/fund-disbursement"
+	"github.com/jfpalngipang/fund-disbursement/inmem"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSignature(t *testing.T) {
+	Convey("signatures verify only with the right secret, body and time", t, func() {
+		now := time.Unix(1590000000, 0)
+		body := []byte(`{"id":"evt_1"}`)
+		sig := Sign("whsec_1", now, body)
+
+		So(Verify("whsec_1", sig, body, DefaultTolerance, now.Add(time.Minute)), ShouldBeNil)
+		So(Verify("whsec_2", sig, body, DefaultTolerance, now), ShouldEqual, ErrInvalidSignature)
+		So(Verify("whsec_1", sig, []byte(`{"id":"evt_2"}`), DefaultTolerance, now), ShouldEqual, ErrInvalidSignature)
+		So(Verify("whsec_1", sig, body, DefaultTolerance, now.Add(time.Hour)), ShouldNotBeNil)
+		So(Verify("whsec_1", "garbage", body, DefaultTolerance, now), ShouldEqual, ErrInvalidSignature)
+	})
+}
+
+// receiver is a webhook endpoint answering with status.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(status int) *receiver {
+	rc := &receiver{status: status}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		rc.received = append(rc.received, r)
+		rc.bodies = append(rc.bodies, b)
+		w.WriteHeader(rc.status)
+	}))
+	return rc
+}
+
+func TestDispatcher(t *testing.T) {
+	Convey("given a registered endpoint", t, func() {
+		ctx := context.Background()
+		now := time.Now()
+		store := inmem.NewWebhookStore()
+		d := NewDispatcher(store)
+		d.now = func() time.Time { return now }
+		d.MaxAttempts = 3
+
+		rc := newReceiver(http.StatusOK)
+		defer rc.Close()
+		ep := &disbursement.WebhookEndpoint{URL: rc.URL, Events: []disbursement.EventType{disbursement.EventTransferCredited}}
+		So(d.CreateWebhookEndpoint(ctx, ep), ShouldBeNil)
+		So(ep.Secret, ShouldNotBeEmpty)
+
+		event := &disbursement.Event{
+			ID:          "evt_1",
+			Type:        disbursement.EventTransferCredited,
+			Transaction: &disbursement.Transaction{ID: "txn_1", State: disbursement.TransferCredited},
+			OccurredAt:  now,
+		}
+
+		Convey("events are delivered signed", func() {
+			So(d.PublishEvent(ctx, event), ShouldBeNil)
+			So(d.Deliver(ctx), ShouldBeNil)
+
+			So(rc.received, ShouldHaveLength, 1)
+			So(rc.received[0].Header.Get(EventTypeHeader), ShouldEqual, "transfer.credited")
+			So(Verify(ep.Secret, rc.received[0].Header.Get(SignatureHeader), rc.bodies[0], DefaultTolerance, now), ShouldBeNil)
+
+			deliveries, _ := d.FindWebhookDeliveries(ctx, disbursement.WebhookDeliveryFilter{})
+			So(deliveries[0].State, ShouldEqual, disbursement.WebhookDeliveryDelivered)
+		})
+
+		Convey("events the endpoint did not ask for are not delivered", func() {
+			event.Type = disbursement.EventTransferCreated
+			So(d.PublishEvent(ctx, event), ShouldBeNil)
+			So(d.Deliver(ctx), ShouldBeNil)
+			So(rc.received, ShouldBeEmpty)
+		})
+
+		Convey("failed deliveries are retried with backoff and then dead-lettered", func() {
+			rc.status = http.StatusInternalServerError
+			So(d.PublishEvent(ctx, event), ShouldBeNil)
+
+			So(d.Deliver(ctx), ShouldBeNil)
+			So(d.Deliver(ctx), ShouldBeNil)
+			So(rc.received, ShouldHaveLength, 1)
+
+			now = now.Add(d.MinBackoff)
+			So(d.Deliver(ctx), ShouldBeNil)
+			So(rc.received, ShouldHaveLength, 2)
+
+			now = now.Add(2 * d.MinBackoff)
+			So(d.Deliver(ctx), ShouldBeNil)
+			So(rc.received, ShouldHaveLength, 3)
+
+			dead, _ := d.FindWebhookDeliveries(ctx, disbursement.WebhookDeliveryFilter{State: disbursement.WebhookDeliveryDead})
+			So(dead, ShouldHaveLength, 1)
+			So(dead[0].LastStatus, ShouldEqual, http.StatusInternalServerError)
+
+			Convey("and replayed", func() {
+				rc.status = http.StatusOK
+				deliveries, err := d.ReplayEvent(ctx, event.ID, "")
+				So(err, ShouldBeNil)
+				So(deliveries, ShouldHaveLength, 1)
+				So(d.Deliver(ctx), ShouldBeNil)
+				So(rc.received, ShouldHaveLength, 4)
+			})
+		})
+
+		Convey("clients only see and receive their own endpoints and events", func() {
+			acme := disbursement.NewContextWithClientID(ctx, "acme")
+			own := &disbursement.WebhookEndpoint{URL: rc.URL}
+			So(d.CreateWebhookEndpoint(acme, own), ShouldBeNil)
+
+			endpoints, _ := d.FindWebhookEndpoints(acme)
+			So(endpoints, ShouldHaveLength, 1)
+			So(endpoints[0].Secret, ShouldBeEmpty)
+			So(d.DeleteWebhookEndpoint(acme, ep.ID), ShouldEqual, disbursement.ErrNotFound)
+
+			So(d.PublishEvent(ctx, event), ShouldBeNil)
+			deliveries, _ := d.FindWebhookDeliveries(acme, disbursement.WebhookDeliveryFilter{})
+			So(deliveries, ShouldBeEmpty)
+		})
+
+		Convey("endpoints must have an http url", func() {
+			err := d.CreateWebhookEndpoint(ctx, &disbursement.WebhookEndpoint{URL: "ftp://example.com"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}